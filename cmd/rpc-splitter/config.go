@@ -0,0 +1,131 @@
+//  Copyright (C) 2021-2023 Chronicle Labs, Inc.
+//
+//  This program is free software: you can redistribute it and/or modify
+//  it under the terms of the GNU Affero General Public License as
+//  published by the Free Software Foundation, either version 3 of the
+//  License, or (at your option) any later version.
+//
+//  This program is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of
+//  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU Affero General Public License for more details.
+//
+//  You should have received a copy of the GNU Affero General Public License
+//  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/chronicleprotocol/go-utils/httpserver"
+	"github.com/chronicleprotocol/go-utils/httpserver/middleware"
+	"github.com/chronicleprotocol/go-utils/log"
+	"github.com/chronicleprotocol/go-utils/rpcsplitter"
+	"github.com/chronicleprotocol/go-utils/supervisor"
+)
+
+// config is the configuration of the rpc-splitter command. The "logger"
+// block is handled by supervisor.Bootstrap.
+type config struct {
+	RPCSplitter rpcSplitterConfig `hcl:"rpc_splitter,block"`
+}
+
+type rpcSplitterConfig struct {
+	// ListenAddr is the address on which the HTTP server listens.
+	ListenAddr string `hcl:"listen_addr"`
+
+	// Endpoints is a list of Ethereum RPC endpoints.
+	Endpoints []string `hcl:"endpoints"`
+
+	// MinResponses and MaxBlocksBehind are described in the
+	// rpcsplitter.WithRequirements option.
+	MinResponses    int `hcl:"min_responses"`
+	MaxBlocksBehind int `hcl:"max_blocks_behind,optional"`
+
+	// TotalTimeout and GracefulTimeout are timeouts in seconds, described
+	// in the rpcsplitter.WithTotalTimeout and rpcsplitter.WithGracefulTimeout
+	// options.
+	TotalTimeout    float64 `hcl:"total_timeout,optional"`
+	GracefulTimeout float64 `hcl:"graceful_timeout,optional"`
+
+	// Weights and Quorum are described in the rpcsplitter.WithWeights option.
+	Weights map[string]int `hcl:"weights,optional"`
+	Quorum  int            `hcl:"quorum,optional"`
+
+	// TrustedEndpoint and MinCorroborating configure the
+	// rpcsplitter.TrustedEndpointPolicy conflict policy.
+	TrustedEndpoint  string `hcl:"trusted_endpoint,optional"`
+	MinCorroborating int    `hcl:"min_corroborating,optional"`
+
+	// CORSOrigin is the origin allowed to send cross-origin requests, or
+	// "*" to allow all origins. If empty, CORS headers are not sent.
+	CORSOrigin string `hcl:"cors_origin,optional"`
+
+	// HealthCheckPath is a path of the health check endpoint.
+	HealthCheckPath string `hcl:"health_check_path,optional"`
+}
+
+// Services implements the supervisor.Config interface.
+func (c *config) Services(logger log.Logger, _ string, _ string) (supervisor.Service, error) {
+	cfg := c.RPCSplitter
+	if len(cfg.Endpoints) == 0 {
+		return nil, errors.New("at least one endpoint must be provided")
+	}
+	opts := []rpcsplitter.Option{
+		rpcsplitter.WithEndpoints(cfg.Endpoints),
+		rpcsplitter.WithRequirements(cfg.MinResponses, cfg.MaxBlocksBehind),
+		rpcsplitter.WithLogger(logger),
+	}
+	if cfg.TotalTimeout > 0 {
+		opts = append(opts, rpcsplitter.WithTotalTimeout(secondsToDuration(cfg.TotalTimeout)))
+	}
+	if cfg.GracefulTimeout > 0 {
+		opts = append(opts, rpcsplitter.WithGracefulTimeout(secondsToDuration(cfg.GracefulTimeout)))
+	}
+	if len(cfg.Weights) > 0 || cfg.Quorum > 0 {
+		opts = append(opts, rpcsplitter.WithWeights(cfg.Weights, cfg.Quorum))
+	}
+	if cfg.TrustedEndpoint != "" {
+		opts = append(opts, rpcsplitter.WithConflictPolicy(
+			rpcsplitter.TrustedEndpointPolicy(cfg.TrustedEndpoint, cfg.MinCorroborating),
+		))
+	}
+	handler, err := rpcsplitter.NewServer(opts...)
+	if err != nil {
+		return nil, err
+	}
+	srv := httpserver.New(&http.Server{
+		Addr:              cfg.ListenAddr,
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	})
+	srv.Use(
+		&middleware.Recover{Recover: func(err any) {
+			logger.
+				WithError(fmt.Errorf("panic: %v", err)).
+				Error("Recovered from panic")
+		}},
+		&middleware.Logger{Log: logger},
+	)
+	if cfg.CORSOrigin != "" {
+		srv.Use(&middleware.CORS{
+			AllowedOrigins: []string{cfg.CORSOrigin},
+			AllowedMethods: []string{http.MethodPost},
+			AllowedHeaders: []string{"Content-Type"},
+		})
+	}
+	healthCheckPath := cfg.HealthCheckPath
+	if healthCheckPath == "" {
+		healthCheckPath = defaultHealthCheckPath
+	}
+	srv.Use(&middleware.HealthCheck{Path: healthCheckPath})
+	return srv, nil
+}
+
+func secondsToDuration(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
@@ -0,0 +1,82 @@
+//  Copyright (C) 2021-2023 Chronicle Labs, Inc.
+//
+//  This program is free software: you can redistribute it and/or modify
+//  it under the terms of the GNU Affero General Public License as
+//  published by the Free Software Foundation, either version 3 of the
+//  License, or (at your option) any later version.
+//
+//  This program is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of
+//  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU Affero General Public License for more details.
+//
+//  You should have received a copy of the GNU Affero General Public License
+//  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/chronicleprotocol/go-utils/log/null"
+	"github.com/chronicleprotocol/go-utils/supervisor"
+)
+
+func TestLoadConfig(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "config.hcl"), `
+		include = ["logger.hcl"]
+
+		variables {
+			endpoints = ["http://localhost:8001", "http://localhost:8002"]
+		}
+
+		rpc_splitter {
+			listen_addr     = "localhost:0"
+			endpoints       = var.endpoints
+			min_responses   = 2
+			total_timeout   = 2.5
+			weights         = { "http://localhost:8001" = 2 }
+			cors_origin     = "*"
+		}
+	`)
+	writeFile(t, filepath.Join(dir, "logger.hcl"), `
+		logger {
+			verbosity = "debug"
+			format    = "json"
+		}
+	`)
+
+	var cfg config
+	loggerCfg, diags := supervisor.LoadConfig([]string{filepath.Join(dir, "config.hcl")}, &cfg)
+	require.False(t, diags.HasErrors(), diags.Error())
+
+	require.NotNil(t, loggerCfg)
+	assert.Equal(t, "debug", loggerCfg.Verbosity)
+	assert.Equal(t, "json", loggerCfg.Format)
+	assert.Equal(t, "localhost:0", cfg.RPCSplitter.ListenAddr)
+	assert.Equal(t, []string{"http://localhost:8001", "http://localhost:8002"}, cfg.RPCSplitter.Endpoints)
+	assert.Equal(t, 2, cfg.RPCSplitter.MinResponses)
+	assert.Equal(t, 2.5, cfg.RPCSplitter.TotalTimeout)
+	assert.Equal(t, map[string]int{"http://localhost:8001": 2}, cfg.RPCSplitter.Weights)
+
+	_, err := loggerCfg.Logger()
+	require.NoError(t, err)
+	_, err = cfg.Services(null.New(), appName, appVersion)
+	require.NoError(t, err)
+}
+
+func TestLoadConfig_MissingFile(t *testing.T) {
+	var cfg config
+	_, diags := supervisor.LoadConfig([]string{filepath.Join(t.TempDir(), "missing.hcl")}, &cfg)
+	assert.True(t, diags.HasErrors())
+}
+
+func writeFile(t *testing.T, path, content string) {
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+}
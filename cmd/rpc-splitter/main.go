@@ -0,0 +1,76 @@
+//  Copyright (C) 2021-2023 Chronicle Labs, Inc.
+//
+//  This program is free software: you can redistribute it and/or modify
+//  it under the terms of the GNU Affero General Public License as
+//  published by the Free Software Foundation, either version 3 of the
+//  License, or (at your option) any later version.
+//
+//  This program is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of
+//  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU Affero General Public License for more details.
+//
+//  You should have received a copy of the GNU Affero General Public License
+//  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+// Command rpc-splitter runs the RPC-Splitter as a standalone HTTP server.
+//
+// The configuration is loaded from one or more HCL files:
+//
+//	logger {
+//	  verbosity = "info"
+//	  format    = "text"
+//	}
+//
+//	rpc_splitter {
+//	  listen_addr       = ":8545"
+//	  endpoints         = ["https://archive.local", "https://rpc1.example", "https://rpc2.example"]
+//	  min_responses     = 2
+//	  max_blocks_behind = 10
+//	  total_timeout     = 10
+//	  graceful_timeout  = 1
+//	}
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/chronicleprotocol/go-utils/supervisor"
+)
+
+const (
+	appName                = "rpc-splitter"
+	defaultHealthCheckPath = "/healthz"
+)
+
+// appVersion is set during the build process.
+var appVersion = "dev"
+
+func main() {
+	if err := run(); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", appName, err)
+		os.Exit(1)
+	}
+}
+
+func run() error {
+	var (
+		paths       []string
+		showVersion bool
+	)
+	flag.Func("config", "path to the HCL configuration file, can be repeated", func(s string) error {
+		paths = append(paths, s)
+		return nil
+	})
+	flag.BoolVar(&showVersion, "version", false, "print version and exit")
+	flag.Parse()
+	if showVersion {
+		fmt.Println(appName, appVersion)
+		return nil
+	}
+
+	return supervisor.Bootstrap(context.Background(), &config{}, appName, appVersion, paths...)
+}
@@ -0,0 +1,141 @@
+//  Copyright (C) 2021-2023 Chronicle Labs, Inc.
+//
+//  This program is free software: you can redistribute it and/or modify
+//  it under the terms of the GNU Affero General Public License as
+//  published by the Free Software Foundation, either version 3 of the
+//  License, or (at your option) any later version.
+//
+//  This program is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of
+//  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU Affero General Public License for more details.
+//
+//  You should have received a copy of the GNU Affero General Public License
+//  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+// Package admin provides an HTTP handler with administrative endpoints,
+// such as profiling and runtime statistics.
+//
+// The package is separate from the httpserver package because the
+// net/http/pprof and expvar packages register their handlers on
+// http.DefaultServeMux when imported.
+package admin
+
+import (
+	"encoding/json"
+	"expvar"
+	"net/http"
+	"net/http/pprof"
+	"time"
+
+	"github.com/chronicleprotocol/go-utils/httpserver"
+	"github.com/chronicleprotocol/go-utils/log"
+	"github.com/chronicleprotocol/go-utils/sysmon"
+)
+
+// Config is a configuration of the admin handler.
+type Config struct {
+	// Sysmon is used to obtain the runtime snapshot. If nil, a new snapshot
+	// is taken on every request.
+	Sysmon *sysmon.Sysmon
+
+	// Logger is the logger whose level can be changed using the
+	// /debug/loglevel endpoint. It must implement the log.LevelSetter
+	// interface to change the level. If nil, the endpoint is disabled.
+	Logger log.Logger
+
+	// Auth is a middleware used to protect the handler, such as
+	// middleware.Auth. If nil, the handler is not protected, which is only
+	// acceptable if it is served on a trusted address.
+	Auth httpserver.Middleware
+}
+
+type logLevel struct {
+	Level string `json:"level"`
+}
+
+// Handler returns a handler with administrative endpoints:
+//
+//   - /debug/pprof/ - profiles from the net/http/pprof package,
+//   - /debug/vars - variables from the expvar package,
+//   - /debug/sysmon - the runtime snapshot from the sysmon package,
+//   - /debug/loglevel - the current log level; a PUT request with
+//     a {"level": "debug"} body changes it.
+//
+// The endpoints expose sensitive information and allow changing the behavior
+// of the service, so the handler should be protected using the Auth field
+// and served on a separate address, see NewServer.
+func Handler(cfg Config) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.Handle("GET /debug/vars", expvar.Handler())
+	mux.HandleFunc("GET /debug/sysmon", func(rw http.ResponseWriter, r *http.Request) {
+		stats := sysmon.ReadStats()
+		if cfg.Sysmon != nil {
+			stats = cfg.Sysmon.Stats()
+		}
+		writeJSON(rw, http.StatusOK, stats)
+	})
+	if cfg.Logger != nil {
+		mux.HandleFunc("GET /debug/loglevel", func(rw http.ResponseWriter, r *http.Request) {
+			writeJSON(rw, http.StatusOK, logLevel{Level: cfg.Logger.Level().String()})
+		})
+		mux.HandleFunc("PUT /debug/loglevel", func(rw http.ResponseWriter, r *http.Request) {
+			setter, ok := cfg.Logger.(log.LevelSetter)
+			if !ok {
+				http.Error(rw, "logger does not support changing the level", http.StatusNotImplemented)
+				return
+			}
+			var req logLevel
+			if err := json.NewDecoder(http.MaxBytesReader(rw, r.Body, 1024)).Decode(&req); err != nil {
+				http.Error(rw, "invalid request body", http.StatusBadRequest)
+				return
+			}
+			level, err := log.ParseLevel(req.Level)
+			if err != nil {
+				http.Error(rw, err.Error(), http.StatusBadRequest)
+				return
+			}
+			prev := cfg.Logger.Level()
+			setter.SetLevel(level)
+			cfg.Logger.
+				WithFields(log.Fields{
+					"previousLevel": prev.String(),
+					"level":         level.String(),
+					"remoteAddr":    r.RemoteAddr,
+				}).
+				Warn("Log level changed")
+			writeJSON(rw, http.StatusOK, logLevel{Level: level.String()})
+		})
+	}
+	if cfg.Auth != nil {
+		return cfg.Auth.Handle(mux)
+	}
+	return mux
+}
+
+// NewServer returns a server that serves the Handler on the given address.
+// It may be watched by the supervisor along with the main server.
+//
+//	srv := admin.NewServer("localhost:6060", admin.Config{
+//		Logger: logger,
+//		Auth:   &middleware.Auth{Authenticators: []middleware.Authenticator{apiKeys}},
+//	})
+func NewServer(addr string, cfg Config, opts ...httpserver.Option) *httpserver.HTTPServer {
+	return httpserver.New(&http.Server{
+		Addr:              addr,
+		Handler:           Handler(cfg),
+		ReadHeaderTimeout: 10 * time.Second,
+	}, opts...)
+}
+
+func writeJSON(rw http.ResponseWriter, code int, v any) {
+	rw.Header().Set("Content-Type", "application/json")
+	rw.Header().Set("Cache-Control", "no-store")
+	rw.WriteHeader(code)
+	_ = json.NewEncoder(rw).Encode(v)
+}
@@ -0,0 +1,142 @@
+//  Copyright (C) 2021-2023 Chronicle Labs, Inc.
+//
+//  This program is free software: you can redistribute it and/or modify
+//  it under the terms of the GNU Affero General Public License as
+//  published by the Free Software Foundation, either version 3 of the
+//  License, or (at your option) any later version.
+//
+//  This program is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of
+//  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU Affero General Public License for more details.
+//
+//  You should have received a copy of the GNU Affero General Public License
+//  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/chronicleprotocol/go-utils/httpserver"
+	"github.com/chronicleprotocol/go-utils/log"
+	logrusLogger "github.com/chronicleprotocol/go-utils/log/logrus"
+	"github.com/chronicleprotocol/go-utils/log/null"
+	"github.com/chronicleprotocol/go-utils/sysmon"
+)
+
+func TestHandler(t *testing.T) {
+	l := logrus.New()
+	l.SetOutput(io.Discard)
+	l.SetLevel(logrus.InfoLevel)
+	logger := logrusLogger.New(l)
+
+	h := Handler(Config{Logger: logger, Sysmon: sysmon.New(time.Second, nil)})
+
+	tests := []struct {
+		method     string
+		path       string
+		body       string
+		wantStatus int
+		wantBody   string
+	}{
+		{method: "GET", path: "/debug/pprof/", wantStatus: http.StatusOK, wantBody: "goroutine"},
+		{method: "GET", path: "/debug/pprof/goroutine?debug=1", wantStatus: http.StatusOK, wantBody: "goroutine profile"},
+		{method: "GET", path: "/debug/vars", wantStatus: http.StatusOK, wantBody: "memstats"},
+		{method: "GET", path: "/debug/sysmon", wantStatus: http.StatusOK, wantBody: `"goroutines"`},
+		{method: "GET", path: "/debug/loglevel", wantStatus: http.StatusOK, wantBody: `{"level":"info"}`},
+		{method: "PUT", path: "/debug/loglevel", body: `{"level":"debug"}`, wantStatus: http.StatusOK, wantBody: `{"level":"debug"}`},
+		{method: "GET", path: "/debug/loglevel", wantStatus: http.StatusOK, wantBody: `{"level":"debug"}`},
+		{method: "PUT", path: "/debug/loglevel", body: `{"level":"foo"}`, wantStatus: http.StatusBadRequest},
+		{method: "PUT", path: "/debug/loglevel", body: `foo`, wantStatus: http.StatusBadRequest},
+		{method: "POST", path: "/debug/sysmon", wantStatus: http.StatusMethodNotAllowed},
+		{method: "GET", path: "/foo", wantStatus: http.StatusNotFound},
+	}
+	for n, tt := range tests {
+		t.Run(fmt.Sprintf("case-%d", n+1), func(t *testing.T) {
+			rw := httptest.NewRecorder()
+			h.ServeHTTP(rw, httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body)))
+			assert.Equal(t, tt.wantStatus, rw.Code)
+			assert.Contains(t, rw.Body.String(), tt.wantBody)
+		})
+	}
+
+	// The level of the underlying logger must be changed too.
+	assert.Equal(t, log.Debug, logger.Level())
+	assert.Equal(t, logrus.DebugLevel, l.Level)
+}
+
+func TestHandler_Sysmon(t *testing.T) {
+	rw := httptest.NewRecorder()
+	Handler(Config{}).ServeHTTP(rw, httptest.NewRequest("GET", "/debug/sysmon", nil))
+	require.Equal(t, http.StatusOK, rw.Code)
+	assert.Equal(t, "application/json", rw.Header().Get("Content-Type"))
+
+	var stats sysmon.Stats
+	require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &stats))
+	assert.NotZero(t, stats.Goroutines)
+	assert.NotZero(t, stats.HeapAlloc)
+}
+
+func TestHandler_LogLevel(t *testing.T) {
+	// Without a logger, the endpoint is disabled.
+	rw := httptest.NewRecorder()
+	Handler(Config{}).ServeHTTP(rw, httptest.NewRequest("GET", "/debug/loglevel", nil))
+	assert.Equal(t, http.StatusNotFound, rw.Code)
+
+	// The null logger does not support changing the level.
+	rw = httptest.NewRecorder()
+	Handler(Config{Logger: null.New()}).ServeHTTP(rw, httptest.NewRequest("PUT", "/debug/loglevel", strings.NewReader(`{"level":"debug"}`)))
+	assert.Equal(t, http.StatusNotImplemented, rw.Code)
+}
+
+func TestHandler_Auth(t *testing.T) {
+	auth := httpserver.MiddlewareFunc(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") != "secret" {
+				rw.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(rw, r)
+		})
+	})
+	h := Handler(Config{Auth: auth})
+
+	rw := httptest.NewRecorder()
+	h.ServeHTTP(rw, httptest.NewRequest("GET", "/debug/vars", nil))
+	assert.Equal(t, http.StatusUnauthorized, rw.Code)
+
+	r := httptest.NewRequest("GET", "/debug/vars", nil)
+	r.Header.Set("Authorization", "secret")
+	rw = httptest.NewRecorder()
+	h.ServeHTTP(rw, r)
+	assert.Equal(t, http.StatusOK, rw.Code)
+}
+
+func TestNewServer(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	srv := NewServer("localhost:0", Config{})
+	require.NoError(t, srv.Start(ctx))
+
+	res, err := http.Get("http://" + srv.Addr().String() + "/debug/vars")
+	require.NoError(t, err)
+	_ = res.Body.Close()
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+
+	cancel()
+	require.NoError(t, <-srv.Wait())
+}
@@ -0,0 +1,170 @@
+//  Copyright (C) 2021-2023 Chronicle Labs, Inc.
+//
+//  This program is free software: you can redistribute it and/or modify
+//  it under the terms of the GNU Affero General Public License as
+//  published by the Free Software Foundation, either version 3 of the
+//  License, or (at your option) any later version.
+//
+//  This program is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of
+//  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU Affero General Public License for more details.
+//
+//  You should have received a copy of the GNU Affero General Public License
+//  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package httpserver
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/chronicleprotocol/go-utils/httpserver/middleware"
+	"github.com/chronicleprotocol/go-utils/log"
+	"github.com/chronicleprotocol/go-utils/log/callback"
+)
+
+type logMessages struct {
+	mu   sync.Mutex
+	msgs []string
+}
+
+func (l *logMessages) logger() log.Logger {
+	return callback.New(log.Debug, func(_ log.Level, _ log.Fields, msg string) {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		l.msgs = append(l.msgs, msg)
+	})
+}
+
+func (l *logMessages) has(msg string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, m := range l.msgs {
+		if m == msg {
+			return true
+		}
+	}
+	return false
+}
+
+func TestServer_Drain(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	logs := &logMessages{}
+	release := make(chan struct{})
+	srv := New(&http.Server{
+		Addr: "localhost:0",
+		Handler: http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/slow" {
+				<-release
+			}
+			rw.Write([]byte(strconv.FormatBool(Draining(r.Context()))))
+		}),
+	},
+		WithDrainPeriod(200*time.Millisecond),
+		WithShutdownTimeout(time.Second),
+		WithLogger(logs.logger()),
+	)
+	require.NoError(t, srv.Start(ctx))
+	url := "http://" + srv.Addr().String()
+	assert.Equal(t, "false", get(t, http.DefaultClient, url))
+
+	// Start a request that lasts longer than the drain period.
+	slowCh := make(chan string)
+	go func() { slowCh <- get(t, http.DefaultClient, url+"/slow") }()
+	assert.Eventually(t, func() bool {
+		return srv.inFlight.Load() == 1
+	}, time.Second, 10*time.Millisecond)
+
+	// During the drain period, requests are still served.
+	cancel()
+	assert.Eventually(t, func() bool {
+		return logs.has("Draining connections")
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, "true", get(t, http.DefaultClient, url))
+
+	// After the drain period, the server stops accepting new connections,
+	// but the in-flight request is finished.
+	assert.Eventually(t, func() bool {
+		return logs.has("Shutting down")
+	}, time.Second, 10*time.Millisecond)
+	close(release)
+	assert.Equal(t, "true", <-slowCh)
+	select {
+	case err := <-srv.Wait():
+		require.NoError(t, err)
+	case <-time.After(time.Second):
+		require.Fail(t, "Wait() channel should not be blocked")
+	}
+}
+
+func TestServer_ShutdownTimeout(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	logs := &logMessages{}
+	release := make(chan struct{})
+	defer close(release)
+	srv := New(&http.Server{
+		Addr: "localhost:0",
+		Handler: http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			<-release
+		}),
+	},
+		WithShutdownTimeout(50*time.Millisecond),
+		WithLogger(logs.logger()),
+	)
+	require.NoError(t, srv.Start(ctx))
+	go func() {
+		res, err := http.Get("http://" + srv.Addr().String())
+		if err == nil {
+			res.Body.Close()
+		}
+	}()
+	assert.Eventually(t, func() bool {
+		return srv.inFlight.Load() == 1
+	}, time.Second, 10*time.Millisecond)
+
+	cancel()
+	select {
+	case err := <-srv.Wait():
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+	case <-time.After(time.Second):
+		require.Fail(t, "Wait() channel should not be blocked")
+	}
+	assert.True(t, logs.has("Shutdown timeout exceeded, closing remaining connections"))
+}
+
+func TestServer_DrainHealthCheck(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	srv := New(&http.Server{Addr: "localhost:0"}, WithDrainPeriod(time.Second))
+	srv.Use(&middleware.HealthCheck{Path: "/health"})
+	require.NoError(t, srv.Start(ctx))
+	url := "http://" + srv.Addr().String() + "/health"
+
+	res, err := http.Get(url)
+	require.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+
+	cancel()
+	assert.Eventually(t, func() bool {
+		res, err := http.Get(url)
+		if err != nil {
+			return false
+		}
+		res.Body.Close()
+		return res.StatusCode == http.StatusServiceUnavailable
+	}, time.Second, 10*time.Millisecond)
+}
@@ -17,16 +17,28 @@ package httpserver
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
 	"net"
 	"net/http"
+	"slices"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/chronicleprotocol/go-utils/httpserver/middleware"
+	"github.com/chronicleprotocol/go-utils/log"
+	"github.com/chronicleprotocol/go-utils/log/null"
 	"github.com/chronicleprotocol/go-utils/supervisor"
 )
 
-const shutdownTimeout = 1 * time.Second
+const LoggerTag = "HTTP_SERVER"
+
+const (
+	defaultShutdownTimeout = 1 * time.Second
+	drainLogInterval       = time.Second
+)
 
 type Middleware interface {
 	Handle(http.Handler) http.Handler
@@ -74,52 +86,210 @@ type HTTPServer struct {
 	ctxCancel context.CancelFunc
 	serveCh   chan error
 	waitCh    chan error
+	readyCh   chan struct{}
+
+	mu          sync.RWMutex
+	routes      []route
+	middlewares []Middleware
+	handler     http.Handler
+
+	lns       []net.Listener
+	listeners []listener
+	systemd   bool
+	reusePort bool
+	srv       *http.Server
+	tls       *TLSConfig
+	log       log.Logger
 
-	handler map[string]http.Handler
-	ln      net.Listener
-	srv     *http.Server
+	drainPeriod     time.Duration
+	shutdownTimeout time.Duration
+	draining        atomic.Bool
+	inFlight        atomic.Int64
 }
 
+type route struct {
+	pattern string
+	handler http.Handler
+}
+
+// Option is a function that configures the HTTPServer.
+type Option func(s *HTTPServer)
+
 // New creates a new HTTPServer instance.
-func New(srv *http.Server) *HTTPServer {
+func New(srv *http.Server, opts ...Option) *HTTPServer {
 	s := &HTTPServer{
-		serveCh: make(chan error),
-		waitCh:  make(chan error),
-		handler: make(map[string]http.Handler),
-		srv:     srv,
+		serveCh:         make(chan error),
+		waitCh:          make(chan error),
+		readyCh:         make(chan struct{}),
+		srv:             srv,
+		log:             null.New(),
+		shutdownTimeout: defaultShutdownTimeout,
 	}
 	if srv.Handler != nil {
-		s.handler[""] = srv.Handler
+		s.routes = append(s.routes, route{pattern: "", handler: srv.Handler})
 	}
+	s.rebuild()
 	srv.Handler = http.HandlerFunc(s.serveHTTP)
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.log = s.log.WithField("tag", LoggerTag)
 	return s
 }
 
+// WithLogger sets the logger used to report the shutdown progress and
+// errors. By default, logs are discarded.
+func WithLogger(logger log.Logger) Option {
+	return func(s *HTTPServer) {
+		s.log = logger
+	}
+}
+
+// WithDrainPeriod sets the time for which the server keeps serving requests
+// after the context is canceled, before the shutdown begins. During this
+// period, the Draining function returns true and the HealthCheck middleware
+// responds with 503, so load balancers can deregister the instance.
+// By default, there is no drain period.
+func WithDrainPeriod(d time.Duration) Option {
+	return func(s *HTTPServer) {
+		s.drainPeriod = d
+	}
+}
+
+// WithShutdownTimeout sets the maximum time to wait for in-flight requests
+// to finish after the drain period. When the timeout expires, remaining
+// connections are closed. The default is 1 second.
+func WithShutdownTimeout(d time.Duration) Option {
+	return func(s *HTTPServer) {
+		s.shutdownTimeout = d
+	}
+}
+
+// Draining returns true if the server that handles the request is in
+// the drain period. It is the same as middleware.Draining.
+func Draining(ctx context.Context) bool {
+	return middleware.Draining(ctx)
+}
+
 // Use adds a middleware. Middlewares will be called in the reverse order
-// they were added.
+// they were added. Middlewares are applied to all requests, including
+// those to handlers set after calling Use.
 func (s *HTTPServer) Use(m ...Middleware) {
-	for p := range s.handler {
-		for _, m := range m {
-			s.handler[p] = m.Handle(s.handler[p])
-		}
-	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.middlewares = append(s.middlewares, m...)
+	s.rebuild()
 }
 
-// SetHandler sets the handler for the server.
-func (s *HTTPServer) SetHandler(path string, handler http.Handler) {
-	s.handler[strings.Trim(path, "/")+"/"] = handler
+// SetHandler sets the handler for the given pattern. If the handler for
+// the same pattern already exists, it is replaced.
+//
+// The pattern may be a path prefix, such as "api/v1", in which case
+// the handler is used for the path and all paths below it. If multiple
+// prefixes match, the longest one is used. The path without the trailing
+// slash, such as "/api/v1", is served by the same handler without
+// a redirect.
+//
+// Patterns containing a method or wildcards, such as "GET /items/{id}",
+// are handled as http.ServeMux patterns. It panics if the pattern is
+// invalid or conflicts with another pattern, in which case the handler
+// is not added.
+//
+// Requests are routed by http.ServeMux, so request paths that are not in
+// the canonical form, such as "/api//v1", are redirected to the cleaned
+// path, and unmatched requests receive the default "404 page not found"
+// response.
+func (s *HTTPServer) SetHandler(pattern string, handler http.Handler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	pattern = normalizePattern(pattern)
+	routes := slices.Clone(s.routes)
+	i := slices.IndexFunc(routes, func(rt route) bool { return rt.pattern == pattern })
+	if i >= 0 {
+		routes[i].handler = handler
+	} else {
+		routes = append(routes, route{pattern: pattern, handler: handler})
+	}
+	// The handler is built before the routes are updated, so an invalid
+	// pattern panics without being stored.
+	h := s.build(routes)
+	s.routes = routes
+	s.handler = h
 }
 
-// ServeHTTP prepares middlewares stack if necessary and calls ServerHTTP
-// on the wrapped server.
+// serveHTTP calls the handler with all middlewares applied.
 func (s *HTTPServer) serveHTTP(rw http.ResponseWriter, r *http.Request) {
-	for p, h := range s.handler {
-		if strings.HasPrefix(strings.Trim(r.URL.Path, "/")+"/", p) {
-			h.ServeHTTP(rw, r)
+	s.inFlight.Add(1)
+	defer s.inFlight.Add(-1)
+	s.mu.RLock()
+	h := s.handler
+	s.mu.RUnlock()
+	h.ServeHTTP(rw, r)
+}
+
+// rebuild creates a new router and applies middlewares to it. It must be
+// called with the mutex locked.
+func (s *HTTPServer) rebuild() {
+	s.handler = s.build(s.routes)
+}
+
+// build creates a router for the given routes and applies middlewares to
+// it. It panics if any of the patterns is invalid. It must be called with
+// the mutex locked.
+func (s *HTTPServer) build(routes []route) http.Handler {
+	var (
+		mux        = http.NewServeMux()
+		registered = make(map[string]bool)
+	)
+	handle := func(pattern string, handler http.Handler) {
+		if registered[pattern] {
 			return
 		}
+		registered[pattern] = true
+		mux.Handle(pattern, handler)
+	}
+	// Patterns are registered before prefixes, so that a pattern
+	// registered explicitly takes precedence over the exact match
+	// pattern generated for a prefix.
+	for _, rt := range routes {
+		if isMuxPattern(rt.pattern) {
+			handle(rt.pattern, rt.handler)
+		}
+	}
+	for _, rt := range routes {
+		if isMuxPattern(rt.pattern) {
+			continue
+		}
+		prefix := rt.pattern
+		if prefix == "" {
+			handle("/", rt.handler)
+			continue
+		}
+		// The subtree pattern handles all paths below the prefix, and
+		// the exact pattern prevents redirects from "/prefix" to "/prefix/".
+		handle("/"+prefix+"/", rt.handler)
+		handle("/"+prefix, rt.handler)
+	}
+	var h http.Handler = mux
+	for _, m := range s.middlewares {
+		h = m.Handle(h)
+	}
+	return h
+}
+
+// normalizePattern returns the pattern in the form stored in routes, so
+// that equivalent prefixes, such as "api" and "/api/", replace each other.
+func normalizePattern(pattern string) string {
+	if isMuxPattern(pattern) {
+		return pattern
 	}
-	rw.WriteHeader(http.StatusNotFound)
+	return strings.Trim(pattern, "/")
+}
+
+// isMuxPattern returns true if the pattern contains a method or wildcards,
+// and therefore must be handled as a http.ServeMux pattern.
+func isMuxPattern(pattern string) bool {
+	return strings.ContainsAny(pattern, " {")
 }
 
 // Start implements the supervisor.Service interface. It starts HTTP server.
@@ -130,18 +300,38 @@ func (s *HTTPServer) Start(ctx context.Context) error {
 	if ctx == nil {
 		return errors.New("context must not be nil")
 	}
-	s.ctx, s.ctxCancel = context.WithCancel(ctx)
-	addr := s.srv.Addr
-	if addr == "" {
-		addr = ":http"
+	var certs *certReloader
+	if s.tls != nil {
+		var err error
+		if certs, err = newCertReloader(*s.tls, s.tlsReloadError); err != nil {
+			return err
+		}
 	}
-	ln, err := (&net.ListenConfig{}).Listen(s.ctx, "tcp", addr)
+	s.ctx, s.ctxCancel = context.WithCancel(ctx)
+	lns, err := s.listen(s.ctx)
 	if err != nil {
+		s.ctxCancel()
 		return err
 	}
-	s.ln = ln
+	if certs != nil {
+		for i, ln := range lns {
+			lns[i] = tls.NewListener(ln, certs.config())
+		}
+	}
+	s.lns = lns
+	baseContext := s.srv.BaseContext
+	s.srv.BaseContext = func(ln net.Listener) context.Context {
+		ctx := context.Background()
+		if baseContext != nil {
+			ctx = baseContext(ln)
+		}
+		return middleware.WithDraining(ctx, s.draining.Load)
+	}
 	go s.shutdownHandler()
-	go s.serve()
+	for _, ln := range lns {
+		go s.serve(ln)
+	}
+	close(s.readyCh)
 	return nil
 }
 
@@ -150,19 +340,43 @@ func (s *HTTPServer) Wait() <-chan error {
 	return s.waitCh
 }
 
-// Addr returns the server's network address.
+// Ready implements the supervisor.Ready interface. The returned channel is
+// closed once all listeners are bound and the server accepts connections.
+func (s *HTTPServer) Ready() <-chan struct{} {
+	return s.readyCh
+}
+
+// Addr returns the address of the listener for the http.Server Addr field,
+// or of the first listener if that field is empty. Use Addrs to get all
+// addresses.
 func (s *HTTPServer) Addr() net.Addr {
-	if s.ln == nil {
+	if len(s.lns) == 0 {
 		return nil
 	}
-	return s.ln.Addr()
+	return s.lns[0].Addr()
+}
+
+// Addrs returns the network addresses of all listeners.
+func (s *HTTPServer) Addrs() []net.Addr {
+	addrs := make([]net.Addr, len(s.lns))
+	for i, ln := range s.lns {
+		addrs[i] = ln.Addr()
+	}
+	return addrs
+}
+
+func (s *HTTPServer) tlsReloadError(err error) {
+	s.log.
+		WithError(err).
+		WithAdvice("The previous certificate is used until the files are fixed").
+		Error("Failed to reload TLS certificates")
 }
 
-func (s *HTTPServer) serve() {
+func (s *HTTPServer) serve(ln net.Listener) {
+	err := s.srv.Serve(ln)
 	select {
 	case <-s.ctx.Done():
-	case s.serveCh <- s.srv.Serve(s.ln):
-	default:
+	case s.serveCh <- err:
 	}
 }
 
@@ -170,12 +384,60 @@ func (s *HTTPServer) shutdownHandler() {
 	defer func() { close(s.waitCh) }()
 	select {
 	case <-s.ctx.Done():
-		ctx, ctxCancel := context.WithTimeout(context.Background(), shutdownTimeout)
-		defer ctxCancel()
-		s.waitCh <- s.srv.Shutdown(ctx)
+		s.drain()
+		s.waitCh <- s.shutdown()
 	case err := <-s.serveCh:
+		// If one of the listeners fails, stop the others.
+		s.ctxCancel()
+		_ = s.shutdown()
 		if err != nil && !errors.Is(err, http.ErrServerClosed) {
 			s.waitCh <- err
 		}
 	}
 }
+
+// drain keeps serving requests for the drain period while reporting
+// the server as draining.
+func (s *HTTPServer) drain() {
+	if s.drainPeriod <= 0 {
+		return
+	}
+	s.draining.Store(true)
+	s.log.
+		WithField("drainPeriod", s.drainPeriod).
+		WithField("inFlight", s.inFlight.Load()).
+		Info("Draining connections")
+	t := time.NewTicker(drainLogInterval)
+	defer t.Stop()
+	deadline := time.After(s.drainPeriod)
+	for {
+		select {
+		case <-deadline:
+			return
+		case <-t.C:
+			s.log.
+				WithField("inFlight", s.inFlight.Load()).
+				Info("Draining connections")
+		}
+	}
+}
+
+// shutdown stops accepting new connections and waits for in-flight requests
+// to finish. If they do not finish within the shutdown timeout, remaining
+// connections are closed.
+func (s *HTTPServer) shutdown() error {
+	s.log.
+		WithField("shutdownTimeout", s.shutdownTimeout).
+		WithField("inFlight", s.inFlight.Load()).
+		Info("Shutting down")
+	ctx, ctxCancel := context.WithTimeout(context.Background(), s.shutdownTimeout)
+	defer ctxCancel()
+	err := s.srv.Shutdown(ctx)
+	if errors.Is(err, context.DeadlineExceeded) {
+		s.log.
+			WithField("inFlight", s.inFlight.Load()).
+			Warn("Shutdown timeout exceeded, closing remaining connections")
+		_ = s.srv.Close()
+	}
+	return err
+}
@@ -16,6 +16,7 @@
 package httpserver
 
 import (
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -64,3 +65,166 @@ func TestServer_WithMiddlewares(t *testing.T) {
 	srv.serveHTTP(rw, r)
 	assert.Equal(t, "before-response-after", rw.Body.String())
 }
+
+func textHandler(s string) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Write([]byte(s))
+	})
+}
+
+func TestServer_Routing(t *testing.T) {
+	srv := New(&http.Server{Handler: textHandler("root")})
+	srv.SetHandler("api", textHandler("api"))
+	srv.SetHandler("/api/v2/", textHandler("api-v2"))
+	srv.SetHandler("GET /items/{id}", http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Write([]byte("item-" + r.PathValue("id")))
+	}))
+	srv.SetHandler("POST /items/{id}", textHandler("post-item"))
+
+	tests := []struct {
+		method string
+		path   string
+		want   string
+		code   int
+	}{
+		{method: "GET", path: "/", want: "root", code: http.StatusOK},
+		{method: "GET", path: "/other", want: "root", code: http.StatusOK},
+		{method: "GET", path: "/api", want: "api", code: http.StatusOK},
+		{method: "GET", path: "/api/v1/foo", want: "api", code: http.StatusOK},
+		{method: "GET", path: "/api/v2", want: "api-v2", code: http.StatusOK},
+		{method: "GET", path: "/api/v2/foo", want: "api-v2", code: http.StatusOK},
+		{method: "GET", path: "/items/42", want: "item-42", code: http.StatusOK},
+		{method: "POST", path: "/items/42", want: "post-item", code: http.StatusOK},
+		{method: "DELETE", path: "/items/42", want: "root", code: http.StatusOK},
+	}
+	for n, tt := range tests {
+		t.Run(fmt.Sprintf("case-%d", n+1), func(t *testing.T) {
+			// Repeat the request to make sure the routing is deterministic.
+			for i := 0; i < 10; i++ {
+				rw := httptest.NewRecorder()
+				srv.serveHTTP(rw, httptest.NewRequest(tt.method, tt.path, nil))
+				assert.Equal(t, tt.code, rw.Code)
+				if tt.want != "" {
+					assert.Equal(t, tt.want, rw.Body.String())
+				}
+			}
+		})
+	}
+}
+
+func TestServer_ReplaceHandler(t *testing.T) {
+	srv := New(&http.Server{})
+	srv.SetHandler("api", textHandler("a"))
+	srv.SetHandler("api", textHandler("b"))
+
+	rw := httptest.NewRecorder()
+	srv.serveHTTP(rw, httptest.NewRequest("GET", "/api", nil))
+	assert.Equal(t, "b", rw.Body.String())
+
+	rw = httptest.NewRecorder()
+	srv.serveHTTP(rw, httptest.NewRequest("GET", "/", nil))
+	assert.Equal(t, http.StatusNotFound, rw.Code)
+}
+
+func TestServer_ReplaceEquivalentPattern(t *testing.T) {
+	// The default handler is replaced by the root prefix.
+	srv := New(&http.Server{Handler: textHandler("default")})
+	srv.SetHandler("/", textHandler("root"))
+
+	rw := httptest.NewRecorder()
+	srv.serveHTTP(rw, httptest.NewRequest("GET", "/foo", nil))
+	assert.Equal(t, "root", rw.Body.String())
+
+	// Equivalent prefixes replace each other.
+	srv.SetHandler("api", textHandler("old"))
+	srv.SetHandler("/api/", textHandler("new"))
+
+	rw = httptest.NewRecorder()
+	srv.serveHTTP(rw, httptest.NewRequest("GET", "/api/x", nil))
+	assert.Equal(t, "new", rw.Body.String())
+	assert.Len(t, srv.routes, 2)
+}
+
+func TestServer_InvalidPattern(t *testing.T) {
+	srv := New(&http.Server{})
+	srv.SetHandler("GET /items/{id}", textHandler("a"))
+	assert.Panics(t, func() { srv.SetHandler("GET /items/{", textHandler("b")) })
+	assert.Panics(t, func() { srv.SetHandler("GET /items/{name}", textHandler("c")) })
+
+	// The invalid patterns are not stored, so the server still works
+	// and new handlers can be added.
+	srv.SetHandler("api", textHandler("api"))
+	rw := httptest.NewRecorder()
+	srv.serveHTTP(rw, httptest.NewRequest("GET", "/items/1", nil))
+	assert.Equal(t, "a", rw.Body.String())
+	rw = httptest.NewRecorder()
+	srv.serveHTTP(rw, httptest.NewRequest("GET", "/api", nil))
+	assert.Equal(t, "api", rw.Body.String())
+	assert.Len(t, srv.routes, 2)
+}
+
+func TestServer_ServeMuxBehavior(t *testing.T) {
+	srv := New(&http.Server{})
+	srv.SetHandler("api", textHandler("api"))
+
+	tests := []struct {
+		path         string
+		wantCode     int
+		wantBody     string
+		wantLocation string
+	}{
+		// The prefix without the trailing slash is matched exactly,
+		// without a redirect to "/api/".
+		{path: "/api", wantCode: http.StatusOK, wantBody: "api"},
+		{path: "/api/", wantCode: http.StatusOK, wantBody: "api"},
+		{path: "/api/foo", wantCode: http.StatusOK, wantBody: "api"},
+		// The prefix is matched by path segments only.
+		{path: "/apifoo", wantCode: http.StatusNotFound, wantBody: "404 page not found\n"},
+		// Paths that are not clean are redirected to the cleaned path.
+		{path: "/api//foo", wantLocation: "/api/foo"},
+		{path: "/foo/../api", wantLocation: "/api"},
+	}
+	for n, tt := range tests {
+		t.Run(fmt.Sprintf("case-%d", n+1), func(t *testing.T) {
+			rw := httptest.NewRecorder()
+			srv.serveHTTP(rw, httptest.NewRequest("GET", tt.path, nil))
+			if tt.wantLocation != "" {
+				// The redirect status code depends on the Go version.
+				assert.True(t, rw.Code >= 300 && rw.Code < 400)
+			} else {
+				assert.Equal(t, tt.wantCode, rw.Code)
+			}
+			if tt.wantBody != "" {
+				assert.Equal(t, tt.wantBody, rw.Body.String())
+			}
+			assert.Equal(t, tt.wantLocation, rw.Header().Get("Location"))
+		})
+	}
+}
+
+func TestServer_MiddlewareOrder(t *testing.T) {
+	srv := New(&http.Server{})
+	srv.Use(MiddlewareFunc(func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			rw.Write([]byte("mw-"))
+			handler.ServeHTTP(rw, r)
+		})
+	}))
+
+	// The handler is set after the middleware, but the middleware must
+	// still be applied.
+	srv.SetHandler("api", textHandler("api"))
+
+	rw := httptest.NewRecorder()
+	srv.serveHTTP(rw, httptest.NewRequest("GET", "/api/foo", nil))
+	assert.Equal(t, "mw-api", rw.Body.String())
+}
+
+func TestDefaultServeMux(t *testing.T) {
+	// Importing the package must not register debug handlers, such as
+	// those from the net/http/pprof and expvar packages.
+	for _, path := range []string{"/debug/pprof/", "/debug/vars"} {
+		_, pattern := http.DefaultServeMux.Handler(httptest.NewRequest("GET", path, nil))
+		assert.Empty(t, pattern, path)
+	}
+}
@@ -0,0 +1,190 @@
+//  Copyright (C) 2021-2023 Chronicle Labs, Inc.
+//
+//  This program is free software: you can redistribute it and/or modify
+//  it under the terms of the GNU Affero General Public License as
+//  published by the Free Software Foundation, either version 3 of the
+//  License, or (at your option) any later version.
+//
+//  This program is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of
+//  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU Affero General Public License for more details.
+//
+//  You should have received a copy of the GNU Affero General Public License
+//  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package httpserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// listenFDsStart is the first file descriptor passed by systemd.
+var listenFDsStart = 3
+
+// listener describes a listener to be created when the server starts.
+type listener struct {
+	network string
+	address string
+	ln      net.Listener
+}
+
+// WithListener adds a listener on the given network and address, such as
+// "tcp" and "localhost:8080" or "unix" and "/run/app.sock". The same
+// handlers are served on all listeners. A stale Unix socket file left
+// by a previous process is removed before listening.
+//
+// If at least one listener is added and the Addr field of the http.Server
+// is empty, the default TCP listener is not created.
+func WithListener(network, address string) Option {
+	return func(s *HTTPServer) {
+		s.listeners = append(s.listeners, listener{network: network, address: address})
+	}
+}
+
+// WithNetListener adds an already created listener. The listener is closed
+// when the server stops.
+func WithNetListener(ln net.Listener) Option {
+	return func(s *HTTPServer) {
+		s.listeners = append(s.listeners, listener{ln: ln})
+	}
+}
+
+// WithSystemdListeners adds listeners passed by systemd socket activation
+// using the LISTEN_FDS and LISTEN_PID environment variables. If the
+// variables are not set, no listeners are added.
+func WithSystemdListeners() Option {
+	return func(s *HTTPServer) {
+		s.systemd = true
+	}
+}
+
+// WithReusePort enables the SO_REUSEADDR and SO_REUSEPORT socket options
+// on TCP listeners created by the server, so that multiple servers, possibly
+// in different processes, can listen on the same address. This allows
+// a new server to start before the old one stops, e.g. when used with
+// the supervisor.Reloader in the overlap mode. The kernel distributes
+// incoming connections between the servers.
+//
+// The option is only supported on Linux, macOS and BSD systems. On other
+// systems, the server fails to start.
+func WithReusePort() Option {
+	return func(s *HTTPServer) {
+		s.reusePort = true
+	}
+}
+
+// listenConfig returns the configuration used to create a listener on
+// the given network.
+func (s *HTTPServer) listenConfig(network string) *net.ListenConfig {
+	lc := &net.ListenConfig{}
+	if s.reusePort && strings.HasPrefix(network, "tcp") {
+		lc.Control = reusePortControl
+	}
+	return lc
+}
+
+// listen creates all listeners. If it fails, the listeners created so far
+// are closed.
+func (s *HTTPServer) listen(ctx context.Context) (lns []net.Listener, err error) {
+	defer func() {
+		if err != nil {
+			for _, ln := range lns {
+				_ = ln.Close()
+			}
+		}
+	}()
+	if s.systemd {
+		sdLns, err := systemdListeners()
+		if err != nil {
+			return nil, err
+		}
+		lns = append(lns, sdLns...)
+	}
+	for _, l := range s.listeners {
+		if l.ln != nil {
+			lns = append(lns, l.ln)
+			continue
+		}
+		if l.network == "unix" {
+			if err := removeStaleSocket(l.address); err != nil {
+				return lns, err
+			}
+		}
+		ln, err := s.listenConfig(l.network).Listen(ctx, l.network, l.address)
+		if err != nil {
+			return lns, err
+		}
+		lns = append(lns, ln)
+	}
+	if s.srv.Addr != "" || len(lns) == 0 {
+		addr := s.srv.Addr
+		if addr == "" {
+			addr = ":http"
+		}
+		ln, err := s.listenConfig("tcp").Listen(ctx, "tcp", addr)
+		if err != nil {
+			return lns, err
+		}
+		// The address from the http.Server is the primary one, so it is
+		// returned by the Addr method.
+		lns = append([]net.Listener{ln}, lns...)
+	}
+	return lns, nil
+}
+
+// systemdListeners returns listeners passed by systemd. The environment
+// variables are unset, so they are not inherited by child processes.
+func systemdListeners() ([]net.Listener, error) {
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return nil, nil
+	}
+	n, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || n <= 0 {
+		return nil, nil
+	}
+	_ = os.Unsetenv("LISTEN_PID")
+	_ = os.Unsetenv("LISTEN_FDS")
+	_ = os.Unsetenv("LISTEN_FDNAMES")
+	lns := make([]net.Listener, 0, n)
+	for fd := listenFDsStart; fd < listenFDsStart+n; fd++ {
+		f := os.NewFile(uintptr(fd), "LISTEN_FD_"+strconv.Itoa(fd))
+		ln, err := net.FileListener(f)
+		// FileListener duplicates the file descriptor.
+		_ = f.Close()
+		if err != nil {
+			for _, ln := range lns {
+				_ = ln.Close()
+			}
+			return nil, fmt.Errorf("invalid systemd listener %d: %w", fd, err)
+		}
+		lns = append(lns, ln)
+	}
+	return lns, nil
+}
+
+// removeStaleSocket removes the Unix socket file if nothing listens on it.
+func removeStaleSocket(path string) error {
+	fi, err := os.Lstat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if fi.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("%s exists and is not a socket", path)
+	}
+	if conn, err := net.Dial("unix", path); err == nil {
+		_ = conn.Close()
+		return fmt.Errorf("%s is already in use", path)
+	}
+	return os.Remove(path)
+}
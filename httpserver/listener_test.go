@@ -0,0 +1,94 @@
+//  Copyright (C) 2021-2023 Chronicle Labs, Inc.
+//
+//  This program is free software: you can redistribute it and/or modify
+//  it under the terms of the GNU Affero General Public License as
+//  published by the Free Software Foundation, either version 3 of the
+//  License, or (at your option) any later version.
+//
+//  This program is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of
+//  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU Affero General Public License for more details.
+//
+//  You should have received a copy of the GNU Affero General Public License
+//  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package httpserver
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func startServer(t *testing.T, srv *HTTPServer) {
+	ctx, cancel := context.WithCancel(context.Background())
+	require.NoError(t, srv.Start(ctx))
+	t.Cleanup(func() {
+		cancel()
+		<-srv.Wait()
+	})
+}
+
+func get(t *testing.T, c *http.Client, url string) string {
+	res, err := c.Get(url)
+	require.NoError(t, err)
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+	return string(body)
+}
+
+func unixClient(path string) *http.Client {
+	return &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", path)
+		},
+	}}
+}
+
+func TestServer_MultipleListeners(t *testing.T) {
+	sock := filepath.Join(t.TempDir(), "admin.sock")
+	srv := New(
+		&http.Server{Addr: "localhost:0", Handler: textHandler("ok")},
+		WithListener("unix", sock),
+		WithListener("tcp", "localhost:0"),
+	)
+	startServer(t, srv)
+
+	addrs := srv.Addrs()
+	require.Len(t, addrs, 3)
+	assert.Equal(t, addrs[0], srv.Addr())
+	assert.Equal(t, "tcp", addrs[0].Network())
+	assert.Equal(t, "unix", addrs[1].Network())
+	assert.Equal(t, "tcp", addrs[2].Network())
+
+	assert.Equal(t, "ok", get(t, http.DefaultClient, "http://"+addrs[0].String()))
+	assert.Equal(t, "ok", get(t, unixClient(sock), "http://unix/"))
+	assert.Equal(t, "ok", get(t, http.DefaultClient, "http://"+addrs[2].String()))
+}
+
+func TestServer_StaleUnixSocket(t *testing.T) {
+	sock := filepath.Join(t.TempDir(), "admin.sock")
+
+	// Create a socket file that nothing listens on.
+	ln, err := net.Listen("unix", sock)
+	require.NoError(t, err)
+	ln.(*net.UnixListener).SetUnlinkOnClose(false)
+	require.NoError(t, ln.Close())
+
+	srv := New(&http.Server{Handler: textHandler("ok")}, WithListener("unix", sock))
+	startServer(t, srv)
+	require.Len(t, srv.Addrs(), 1)
+	assert.Equal(t, "ok", get(t, unixClient(sock), "http://unix/"))
+
+	// The socket is in use.
+	srv2 := New(&http.Server{}, WithListener("unix", sock))
+	require.Error(t, srv2.Start(context.Background()))
+}
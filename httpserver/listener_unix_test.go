@@ -0,0 +1,55 @@
+//  Copyright (C) 2021-2023 Chronicle Labs, Inc.
+//
+//  This program is free software: you can redistribute it and/or modify
+//  it under the terms of the GNU Affero General Public License as
+//  published by the Free Software Foundation, either version 3 of the
+//  License, or (at your option) any later version.
+//
+//  This program is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of
+//  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU Affero General Public License for more details.
+//
+//  You should have received a copy of the GNU Affero General Public License
+//  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+//go:build unix
+
+package httpserver
+
+import (
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_SystemdListeners(t *testing.T) {
+	ln, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	defer ln.Close()
+	f, err := ln.(*net.TCPListener).File()
+	require.NoError(t, err)
+	defer f.Close()
+
+	// The descriptor is duplicated, because it is closed by the server.
+	fd, err := syscall.Dup(int(f.Fd()))
+	require.NoError(t, err)
+
+	defer func(n int) { listenFDsStart = n }(listenFDsStart)
+	listenFDsStart = fd
+	t.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()))
+	t.Setenv("LISTEN_FDS", "1")
+
+	srv := New(&http.Server{Handler: textHandler("ok")}, WithSystemdListeners())
+	startServer(t, srv)
+	require.Len(t, srv.Addrs(), 1)
+	assert.Equal(t, ln.Addr().String(), srv.Addr().String())
+	assert.Equal(t, "ok", get(t, http.DefaultClient, "http://"+srv.Addr().String()))
+	assert.Empty(t, os.Getenv("LISTEN_FDS"))
+}
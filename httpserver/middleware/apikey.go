@@ -0,0 +1,138 @@
+//  Copyright (C) 2021-2023 Chronicle Labs, Inc.
+//
+//  This program is free software: you can redistribute it and/or modify
+//  it under the terms of the GNU Affero General Public License as
+//  published by the Free Software Foundation, either version 3 of the
+//  License, or (at your option) any later version.
+//
+//  This program is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of
+//  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU Affero General Public License for more details.
+//
+//  You should have received a copy of the GNU Affero General Public License
+//  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package middleware
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// APIKeyHeader is the default name of the header that contains the API key.
+const APIKeyHeader = "X-API-Key"
+
+// APIKeys is an Authenticator that authenticates requests using static
+// API keys.
+//
+// The key is taken from the X-API-Key header (or the header set in the
+// Header field) or from the Authorization header with the "ApiKey" scheme.
+type APIKeys struct {
+	// Header is the name of the header that contains the API key.
+	// If empty, APIKeyHeader is used.
+	Header string
+
+	// Keys maps API keys to the names of their owners. The names are used
+	// as principal names. Use LoadAPIKeys or APIKeysFromEnv to load keys.
+	Keys map[string]string
+}
+
+// Authenticate implements the Authenticator interface.
+func (a *APIKeys) Authenticate(r *http.Request) (*Principal, error) {
+	header := a.Header
+	if header == "" {
+		header = APIKeyHeader
+	}
+	key := r.Header.Get(header)
+	if key == "" {
+		var ok bool
+		if key, ok = authorizationCredentials(r, "ApiKey"); !ok {
+			return nil, ErrNoCredentials
+		}
+	}
+	// Keys are compared in constant time to avoid leaking information
+	// about valid keys through timing. Hashes are compared so that the
+	// comparison time does not depend on the length of the keys.
+	var (
+		name  string
+		found bool
+		hash  = sha256.Sum256([]byte(key))
+	)
+	for k, n := range a.Keys {
+		h := sha256.Sum256([]byte(k))
+		if subtle.ConstantTimeCompare(hash[:], h[:]) == 1 {
+			name, found = n, true
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("api key: %w", ErrInvalidCredentials)
+	}
+	return &Principal{Name: name, Scheme: "apikey"}, nil
+}
+
+// Challenge implements the Challenger interface.
+func (a *APIKeys) Challenge() string {
+	return "ApiKey"
+}
+
+// LoadAPIKeys loads API keys from a file. Each line of the file contains
+// a name and a key separated by a colon. Empty lines and lines starting
+// with "#" are ignored. The result can be used as APIKeys.Keys.
+func LoadAPIKeys(path string) (map[string]string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("api key: unable to read file: %w", err)
+	}
+	keys := make(map[string]string)
+	s := bufio.NewScanner(bytes.NewReader(b))
+	for n := 1; s.Scan(); n++ {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if err := parseAPIKey(keys, line); err != nil {
+			return nil, fmt.Errorf("api key: %s:%d: %w", path, n, err)
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, fmt.Errorf("api key: unable to read file: %w", err)
+	}
+	return keys, nil
+}
+
+// APIKeysFromEnv loads API keys from an environment variable. The variable
+// contains comma-separated pairs of names and keys separated by a colon,
+// e.g. "alice:key1,bob:key2". The result can be used as APIKeys.Keys.
+func APIKeysFromEnv(name string) (map[string]string, error) {
+	keys := make(map[string]string)
+	for _, pair := range strings.Split(os.Getenv(name), ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		if err := parseAPIKey(keys, pair); err != nil {
+			return nil, fmt.Errorf("api key: %s: %w", name, err)
+		}
+	}
+	return keys, nil
+}
+
+func parseAPIKey(keys map[string]string, s string) error {
+	name, key, ok := strings.Cut(s, ":")
+	name, key = strings.TrimSpace(name), strings.TrimSpace(key)
+	if !ok || name == "" || key == "" {
+		return fmt.Errorf("invalid entry, expected name:key")
+	}
+	if _, ok := keys[key]; ok {
+		return fmt.Errorf("duplicate key for %q", name)
+	}
+	keys[key] = name
+	return nil
+}
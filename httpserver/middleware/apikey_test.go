@@ -0,0 +1,105 @@
+//  Copyright (C) 2021-2023 Chronicle Labs, Inc.
+//
+//  This program is free software: you can redistribute it and/or modify
+//  it under the terms of the GNU Affero General Public License as
+//  published by the Free Software Foundation, either version 3 of the
+//  License, or (at your option) any later version.
+//
+//  This program is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of
+//  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU Affero General Public License for more details.
+//
+//  You should have received a copy of the GNU Affero General Public License
+//  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package middleware
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPIKeys(t *testing.T) {
+	a := &APIKeys{Keys: map[string]string{"key1": "alice", "key2": "bob"}}
+	tests := []struct {
+		header  string
+		value   string
+		want    string
+		wantErr error
+	}{
+		{header: APIKeyHeader, value: "key1", want: "alice"},
+		{header: "Authorization", value: "ApiKey key2", want: "bob"},
+		{header: "Authorization", value: "apikey key2", want: "bob"},
+		{header: APIKeyHeader, value: "key3", wantErr: ErrInvalidCredentials},
+		{header: "Authorization", value: "Bearer key1", wantErr: ErrNoCredentials},
+		{wantErr: ErrNoCredentials},
+	}
+	for n, tt := range tests {
+		t.Run(fmt.Sprintf("case-%d", n+1), func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			if tt.header != "" {
+				r.Header.Set(tt.header, tt.value)
+			}
+			p, err := a.Authenticate(r)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, p.Name)
+			assert.Equal(t, "apikey", p.Scheme)
+		})
+	}
+}
+
+func TestAPIKeys_CustomHeader(t *testing.T) {
+	a := &APIKeys{Header: "X-Token", Keys: map[string]string{"key1": "alice"}}
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("X-Token", "key1")
+	p, err := a.Authenticate(r)
+	require.NoError(t, err)
+	assert.Equal(t, "alice", p.Name)
+
+	r = httptest.NewRequest("GET", "/", nil)
+	r.Header.Set(APIKeyHeader, "key1")
+	_, err = a.Authenticate(r)
+	assert.ErrorIs(t, err, ErrNoCredentials)
+}
+
+func TestLoadAPIKeys(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "keys")
+	require.NoError(t, os.WriteFile(path, []byte("# comment\nalice:key1\n\n bob : key:2 \n"), 0o600))
+	keys, err := LoadAPIKeys(path)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"key1": "alice", "key:2": "bob"}, keys)
+
+	require.NoError(t, os.WriteFile(path, []byte("alice:key1\nbob\n"), 0o600))
+	_, err = LoadAPIKeys(path)
+	assert.ErrorContains(t, err, ":2:")
+
+	require.NoError(t, os.WriteFile(path, []byte("alice:key1\nbob:key1\n"), 0o600))
+	_, err = LoadAPIKeys(path)
+	assert.Error(t, err)
+
+	_, err = LoadAPIKeys(filepath.Join(dir, "missing"))
+	assert.Error(t, err)
+}
+
+func TestAPIKeysFromEnv(t *testing.T) {
+	t.Setenv("TEST_API_KEYS", "alice:key1, bob:key2,")
+	keys, err := APIKeysFromEnv("TEST_API_KEYS")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"key1": "alice", "key2": "bob"}, keys)
+
+	t.Setenv("TEST_API_KEYS", "alice")
+	_, err = APIKeysFromEnv("TEST_API_KEYS")
+	assert.Error(t, err)
+}
@@ -0,0 +1,176 @@
+//  Copyright (C) 2021-2023 Chronicle Labs, Inc.
+//
+//  This program is free software: you can redistribute it and/or modify
+//  it under the terms of the GNU Affero General Public License as
+//  published by the Free Software Foundation, either version 3 of the
+//  License, or (at your option) any later version.
+//
+//  This program is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of
+//  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU Affero General Public License for more details.
+//
+//  You should have received a copy of the GNU Affero General Public License
+//  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/chronicleprotocol/go-utils/log"
+	"github.com/chronicleprotocol/go-utils/log/null"
+)
+
+// ErrNoCredentials is returned by an Authenticator if the request does not
+// contain credentials for its scheme.
+var ErrNoCredentials = errors.New("no credentials")
+
+// ErrInvalidCredentials is returned by an Authenticator if the request
+// contains credentials for its scheme, but they are invalid.
+var ErrInvalidCredentials = errors.New("invalid credentials")
+
+// Principal is an authenticated client.
+type Principal struct {
+	// Name identifies the client, such as the API key name or the JWT
+	// subject.
+	Name string
+
+	// Scheme is the name of the authentication scheme used.
+	Scheme string
+
+	// Claims are the JWT claims. Nil for other schemes.
+	Claims map[string]any
+}
+
+// Authenticator authenticates requests using a single scheme.
+type Authenticator interface {
+	// Authenticate returns the principal for the request. It returns
+	// an error wrapping ErrNoCredentials if the request does not contain
+	// credentials for the scheme, so that the next authenticator can be
+	// tried. Any other error causes the request to be rejected.
+	Authenticate(r *http.Request) (*Principal, error)
+}
+
+// Challenger may be implemented by an Authenticator to provide a value of
+// the WWW-Authenticate header sent with 401 responses.
+type Challenger interface {
+	Challenge() string
+}
+
+// Auth is a middleware that authenticates requests.
+//
+// Authenticators are tried in order. The first one that finds credentials
+// in the request decides whether the request is authenticated. If none of
+// them finds credentials, or the credentials are invalid, a 401 response is
+// returned. If the Authorize function rejects the principal, a 403 response
+// is returned.
+//
+// The principal is stored in the request context and can be retrieved using
+// the PrincipalFromContext function. The Logger middleware and the logger
+// created by the RequestID middleware include the principal name in logs.
+type Auth struct {
+	// Authenticators is a list of authenticators, such as APIKeys, JWT or
+	// HMAC. At least one must be provided.
+	Authenticators []Authenticator
+
+	// Authorize is an optional function that checks if the principal is
+	// allowed to make the request.
+	Authorize func(r *http.Request, p *Principal) bool
+
+	// Log is a logger used to log rejected requests. If nil, the logs are
+	// discarded.
+	Log log.Logger
+}
+
+// Handle implements the httpserver.Middleware interface.
+func (a *Auth) Handle(next http.Handler) http.Handler {
+	logger := a.Log
+	if logger == nil {
+		logger = null.New()
+	}
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		p, err := a.authenticate(r)
+		if err != nil {
+			logger.
+				WithError(err).
+				WithFields(log.Fields{
+					"remoteAddr": r.RemoteAddr,
+					"url":        r.URL.String(),
+				}).
+				Debug("Authentication failed")
+			for _, au := range a.Authenticators {
+				if c, ok := au.(Challenger); ok {
+					rw.Header().Add("WWW-Authenticate", c.Challenge())
+				}
+			}
+			http.Error(rw, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		if h, ok := r.Context().Value(principalHolderCtxKey{}).(*principalHolder); ok {
+			h.principal = p
+		}
+		ctx := context.WithValue(r.Context(), principalCtxKey{}, p)
+		if l, ok := ctx.Value(loggerCtxKey{}).(log.Logger); ok {
+			ctx = context.WithValue(ctx, loggerCtxKey{}, l.WithField("principal", p.Name))
+		}
+		r = r.WithContext(ctx)
+		if a.Authorize != nil && !a.Authorize(r, p) {
+			logger.
+				WithFields(log.Fields{
+					"principal":  p.Name,
+					"remoteAddr": r.RemoteAddr,
+					"url":        r.URL.String(),
+				}).
+				Debug("Authorization failed")
+			http.Error(rw, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(rw, r)
+	})
+}
+
+func (a *Auth) authenticate(r *http.Request) (*Principal, error) {
+	for _, au := range a.Authenticators {
+		p, err := au.Authenticate(r)
+		if errors.Is(err, ErrNoCredentials) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return p, nil
+	}
+	return nil, ErrNoCredentials
+}
+
+type (
+	principalCtxKey       struct{}
+	principalHolderCtxKey struct{}
+)
+
+// principalHolder is used by the Logger middleware to obtain the principal
+// set by the Auth middleware called after it.
+type principalHolder struct {
+	principal *Principal
+}
+
+// PrincipalFromContext returns the principal authenticated by the Auth
+// middleware.
+func PrincipalFromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(principalCtxKey{}).(*Principal)
+	return p, ok
+}
+
+// authorizationCredentials returns the credentials from the Authorization
+// header if the header uses the given scheme.
+func authorizationCredentials(r *http.Request, scheme string) (string, bool) {
+	v := r.Header.Get("Authorization")
+	if len(v) <= len(scheme) || !strings.EqualFold(v[:len(scheme)], scheme) || v[len(scheme)] != ' ' {
+		return "", false
+	}
+	return strings.TrimSpace(v[len(scheme)+1:]), true
+}
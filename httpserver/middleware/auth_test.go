@@ -0,0 +1,125 @@
+//  Copyright (C) 2021-2023 Chronicle Labs, Inc.
+//
+//  This program is free software: you can redistribute it and/or modify
+//  it under the terms of the GNU Affero General Public License as
+//  published by the Free Software Foundation, either version 3 of the
+//  License, or (at your option) any later version.
+//
+//  This program is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of
+//  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU Affero General Public License for more details.
+//
+//  You should have received a copy of the GNU Affero General Public License
+//  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/chronicleprotocol/go-utils/log"
+	"github.com/chronicleprotocol/go-utils/log/callback"
+)
+
+type authenticatorFunc func(r *http.Request) (*Principal, error)
+
+func (f authenticatorFunc) Authenticate(r *http.Request) (*Principal, error) {
+	return f(r)
+}
+
+func TestAuth(t *testing.T) {
+	noCredentials := authenticatorFunc(func(r *http.Request) (*Principal, error) {
+		return nil, ErrNoCredentials
+	})
+	invalid := authenticatorFunc(func(r *http.Request) (*Principal, error) {
+		return nil, fmt.Errorf("foo: %w", ErrInvalidCredentials)
+	})
+	failing := authenticatorFunc(func(r *http.Request) (*Principal, error) {
+		return nil, errors.New("foo")
+	})
+	alice := authenticatorFunc(func(r *http.Request) (*Principal, error) {
+		return &Principal{Name: "alice", Scheme: "test"}, nil
+	})
+	bob := authenticatorFunc(func(r *http.Request) (*Principal, error) {
+		return &Principal{Name: "bob", Scheme: "test"}, nil
+	})
+	onlyAlice := func(r *http.Request, p *Principal) bool {
+		return p.Name == "alice"
+	}
+
+	tests := []struct {
+		authenticators []Authenticator
+		authorize      func(r *http.Request, p *Principal) bool
+		wantStatus     int
+		wantPrincipal  string
+	}{
+		{authenticators: []Authenticator{alice}, wantStatus: http.StatusOK, wantPrincipal: "alice"},
+		{authenticators: []Authenticator{noCredentials, bob}, wantStatus: http.StatusOK, wantPrincipal: "bob"},
+		{authenticators: []Authenticator{alice, bob}, wantStatus: http.StatusOK, wantPrincipal: "alice"},
+		{authenticators: []Authenticator{noCredentials}, wantStatus: http.StatusUnauthorized},
+		{authenticators: []Authenticator{invalid, alice}, wantStatus: http.StatusUnauthorized},
+		{authenticators: []Authenticator{failing, alice}, wantStatus: http.StatusUnauthorized},
+		{authenticators: nil, wantStatus: http.StatusUnauthorized},
+		{authenticators: []Authenticator{alice}, authorize: onlyAlice, wantStatus: http.StatusOK, wantPrincipal: "alice"},
+		{authenticators: []Authenticator{bob}, authorize: onlyAlice, wantStatus: http.StatusForbidden},
+	}
+	for n, tt := range tests {
+		t.Run(fmt.Sprintf("case-%d", n+1), func(t *testing.T) {
+			var principal *Principal
+			h := (&Auth{Authenticators: tt.authenticators, Authorize: tt.authorize}).Handle(
+				http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+					principal, _ = PrincipalFromContext(r.Context())
+				}),
+			)
+			rw := httptest.NewRecorder()
+			h.ServeHTTP(rw, httptest.NewRequest("GET", "/", nil))
+			assert.Equal(t, tt.wantStatus, rw.Code)
+			if tt.wantPrincipal != "" {
+				require.NotNil(t, principal)
+				assert.Equal(t, tt.wantPrincipal, principal.Name)
+			} else {
+				assert.Nil(t, principal)
+			}
+		})
+	}
+}
+
+func TestAuth_Challenge(t *testing.T) {
+	h := (&Auth{Authenticators: []Authenticator{&JWT{}, &HMAC{}}}).Handle(
+		http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {}),
+	)
+	rw := httptest.NewRecorder()
+	h.ServeHTTP(rw, httptest.NewRequest("GET", "/", nil))
+	assert.Equal(t, http.StatusUnauthorized, rw.Code)
+	assert.Equal(t, []string{"Bearer", "HMAC-SHA256"}, rw.Header().Values("WWW-Authenticate"))
+}
+
+func TestAuth_Logger(t *testing.T) {
+	var recordedLogFields []log.Fields
+	l := callback.New(log.Info, func(level log.Level, fields log.Fields, msg string) {
+		recordedLogFields = append(recordedLogFields, fields)
+	})
+	alice := authenticatorFunc(func(r *http.Request) (*Principal, error) {
+		return &Principal{Name: "alice", Scheme: "test"}, nil
+	})
+
+	var h http.Handler = http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		LoggerFromContext(r.Context(), nil).Info("handler")
+	})
+	h = (&Auth{Authenticators: []Authenticator{alice}}).Handle(h)
+	h = (&Logger{Log: l}).Handle(h)
+	h = (&RequestID{Log: l}).Handle(h)
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+
+	require.Len(t, recordedLogFields, 2)
+	assert.Equal(t, "alice", recordedLogFields[0]["principal"])
+	assert.Equal(t, "alice", recordedLogFields[1]["principal"])
+}
@@ -0,0 +1,279 @@
+//  Copyright (C) 2021-2023 Chronicle Labs, Inc.
+//
+//  This program is free software: you can redistribute it and/or modify
+//  it under the terms of the GNU Affero General Public License as
+//  published by the Free Software Foundation, either version 3 of the
+//  License, or (at your option) any later version.
+//
+//  This program is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of
+//  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU Affero General Public License for more details.
+//
+//  You should have received a copy of the GNU Affero General Public License
+//  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package middleware
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// defaultCompressMinSize is the default minimum size of a response to be
+// compressed.
+const defaultCompressMinSize = 1024
+
+// defaultCompressContentTypes is the default list of compressed content
+// types.
+var defaultCompressContentTypes = []string{
+	"text/",
+	"application/json",
+	"application/javascript",
+	"application/xml",
+	"image/svg+xml",
+}
+
+// Compress is a middleware that compresses responses using gzip or deflate,
+// depending on the Accept-Encoding header of the request.
+//
+// Responses smaller than MinSize, responses that already have the
+// Content-Encoding header and responses with content types not listed in
+// ContentTypes are sent uncompressed.
+//
+// The middleware should be added after the Logger middleware, so that logs
+// contain uncompressed responses.
+type Compress struct {
+	// Level is the compression level, see the compress/flate package.
+	// If zero, the default compression level is used.
+	Level int
+
+	// MinSize is the minimum size of a response in bytes to be compressed.
+	// If zero, 1024 is used.
+	MinSize int
+
+	// ContentTypes is a list of compressed content types. Entries ending
+	// with "/" match all subtypes. If empty, text, JSON, JavaScript, XML
+	// and SVG responses are compressed.
+	ContentTypes []string
+}
+
+// Handle implements the httpserver.Middleware interface.
+func (c *Compress) Handle(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Add("Vary", "Accept-Encoding")
+		encoding := negotiateEncoding(r.Header.Values("Accept-Encoding"))
+		if encoding == "" {
+			next.ServeHTTP(rw, r)
+			return
+		}
+		cw := &compressWriter{rw: rw, c: c, encoding: encoding}
+		defer cw.close()
+		next.ServeHTTP(cw, r)
+	})
+}
+
+func (c *Compress) minSize() int {
+	if c.MinSize == 0 {
+		return defaultCompressMinSize
+	}
+	return c.MinSize
+}
+
+func (c *Compress) compressible(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	types := c.ContentTypes
+	if len(types) == 0 {
+		types = defaultCompressContentTypes
+	}
+	for _, t := range types {
+		if mediaType == t || (strings.HasSuffix(t, "/") && strings.HasPrefix(mediaType, t)) {
+			return true
+		}
+	}
+	return false
+}
+
+// compressWriter buffers the beginning of the response until MinSize bytes
+// are written, and then decides whether to compress the response.
+type compressWriter struct {
+	rw       http.ResponseWriter
+	c        *Compress
+	encoding string
+	code     int
+	buf      []byte
+	decided  bool
+	enc      io.WriteCloser // enc is nil if the response is not compressed
+}
+
+func (w *compressWriter) Header() http.Header {
+	return w.rw.Header()
+}
+
+func (w *compressWriter) WriteHeader(code int) {
+	if code >= 100 && code < 200 {
+		w.rw.WriteHeader(code)
+		return
+	}
+	if w.code == 0 {
+		w.code = code
+	}
+}
+
+func (w *compressWriter) Write(b []byte) (int, error) {
+	if w.code == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	if !w.decided {
+		w.buf = append(w.buf, b...)
+		if len(w.buf) < w.c.minSize() {
+			return len(b), nil
+		}
+		if err := w.decide(); err != nil {
+			return 0, err
+		}
+		return len(b), nil
+	}
+	if w.enc != nil {
+		return w.enc.Write(b)
+	}
+	return w.rw.Write(b)
+}
+
+// Flush implements the http.Flusher interface. Flushing a response smaller
+// than MinSize sends it uncompressed.
+func (w *compressWriter) Flush() {
+	if w.code == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	if !w.decided {
+		if err := w.decide(); err != nil {
+			return
+		}
+	}
+	if f, ok := w.enc.(interface{ Flush() error }); ok {
+		_ = f.Flush()
+	}
+	_ = http.NewResponseController(w.rw).Flush()
+}
+
+// Unwrap returns the underlying ResponseWriter. It is used by
+// http.ResponseController.
+func (w *compressWriter) Unwrap() http.ResponseWriter {
+	return w.rw
+}
+
+// decide writes the headers and the buffered data, compressed or not.
+func (w *compressWriter) decide() error {
+	w.decided = true
+	h := w.rw.Header()
+	if h.Get("Content-Type") == "" && len(w.buf) > 0 {
+		h.Set("Content-Type", http.DetectContentType(w.buf))
+	}
+	if w.shouldCompress() {
+		h.Set("Content-Encoding", w.encoding)
+		h.Del("Content-Length")
+		w.enc = newEncoder(w.encoding, w.rw, w.c.Level)
+	}
+	w.rw.WriteHeader(w.code)
+	buf := w.buf
+	w.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	var err error
+	if w.enc != nil {
+		_, err = w.enc.Write(buf)
+	} else {
+		_, err = w.rw.Write(buf)
+	}
+	return err
+}
+
+func (w *compressWriter) shouldCompress() bool {
+	h := w.rw.Header()
+	return len(w.buf) >= w.c.minSize() &&
+		w.code != http.StatusNoContent &&
+		w.code != http.StatusNotModified &&
+		h.Get("Content-Encoding") == "" &&
+		h.Get("Content-Range") == "" &&
+		w.c.compressible(h.Get("Content-Type"))
+}
+
+func (w *compressWriter) close() {
+	if w.code == 0 {
+		return
+	}
+	if !w.decided {
+		if err := w.decide(); err != nil {
+			return
+		}
+	}
+	if w.enc != nil {
+		_ = w.enc.Close()
+	}
+}
+
+func newEncoder(encoding string, w io.Writer, level int) io.WriteCloser {
+	if level == 0 {
+		level = gzip.DefaultCompression
+	}
+	var (
+		enc io.WriteCloser
+		err error
+	)
+	switch encoding {
+	case "gzip":
+		enc, err = gzip.NewWriterLevel(w, level)
+	case "deflate":
+		// The "deflate" content coding is the zlib format (RFC 9110).
+		enc, err = zlib.NewWriterLevel(w, level)
+	}
+	if err != nil {
+		// Invalid level, fallback to the default one.
+		return newEncoder(encoding, w, 0)
+	}
+	return enc
+}
+
+// negotiateEncoding returns the preferred supported encoding from
+// the Accept-Encoding header values, or an empty string if the response
+// should not be compressed.
+func negotiateEncoding(values []string) string {
+	q := map[string]float64{}
+	for _, v := range values {
+		for _, part := range strings.Split(v, ",") {
+			coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+			coding = strings.ToLower(strings.TrimSpace(coding))
+			if coding == "" {
+				continue
+			}
+			weight := 1.0
+			if name, value, ok := strings.Cut(strings.TrimSpace(params), "="); ok && strings.TrimSpace(name) == "q" {
+				if f, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+					weight = f
+				}
+			}
+			q[coding] = weight
+		}
+	}
+	best, bestQ := "", 0.0
+	for _, coding := range []string{"gzip", "deflate"} {
+		w, ok := q[coding]
+		if !ok {
+			w = q["*"]
+		}
+		if w > bestQ {
+			best, bestQ = coding, w
+		}
+	}
+	return best
+}
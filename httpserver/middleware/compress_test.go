@@ -0,0 +1,163 @@
+//  Copyright (C) 2021-2023 Chronicle Labs, Inc.
+//
+//  This program is free software: you can redistribute it and/or modify
+//  it under the terms of the GNU Affero General Public License as
+//  published by the Free Software Foundation, either version 3 of the
+//  License, or (at your option) any later version.
+//
+//  This program is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of
+//  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU Affero General Public License for more details.
+//
+//  You should have received a copy of the GNU Affero General Public License
+//  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package middleware
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	tests := []struct {
+		header []string
+		want   string
+	}{
+		{header: nil, want: ""},
+		{header: []string{"gzip"}, want: "gzip"},
+		{header: []string{"deflate"}, want: "deflate"},
+		{header: []string{"gzip, deflate"}, want: "gzip"},
+		{header: []string{"deflate, gzip"}, want: "gzip"},
+		{header: []string{"gzip;q=0.5, deflate"}, want: "deflate"},
+		{header: []string{"GZIP"}, want: "gzip"},
+		{header: []string{"gzip;q=0"}, want: ""},
+		{header: []string{"*"}, want: "gzip"},
+		{header: []string{"*, gzip;q=0"}, want: "deflate"},
+		{header: []string{"br"}, want: ""},
+		{header: []string{"identity"}, want: ""},
+		{header: []string{"br", "deflate"}, want: "deflate"},
+	}
+	for n, tt := range tests {
+		t.Run(fmt.Sprintf("case-%d", n+1), func(t *testing.T) {
+			assert.Equal(t, tt.want, negotiateEncoding(tt.header))
+		})
+	}
+}
+
+func TestCompress(t *testing.T) {
+	large := strings.Repeat("a", 2048)
+	tests := []struct {
+		acceptEncoding  string
+		contentType     string
+		contentEncoding string
+		code            int
+		body            string
+		wantEncoding    string
+	}{
+		{acceptEncoding: "gzip", contentType: "application/json", body: large, wantEncoding: "gzip"},
+		{acceptEncoding: "deflate", contentType: "text/plain; charset=utf-8", body: large, wantEncoding: "deflate"},
+		{acceptEncoding: "gzip", body: large, wantEncoding: "gzip"}, // detected as text/plain
+		{acceptEncoding: "", contentType: "application/json", body: large},
+		{acceptEncoding: "gzip", contentType: "application/json", body: "small"},
+		{acceptEncoding: "gzip", contentType: "image/png", body: large},
+		{acceptEncoding: "gzip", contentType: "application/json", contentEncoding: "br", body: large},
+		{acceptEncoding: "gzip", contentType: "application/json", code: http.StatusNotFound, body: large, wantEncoding: "gzip"},
+		{acceptEncoding: "gzip", code: http.StatusNoContent},
+	}
+	for n, tt := range tests {
+		t.Run(fmt.Sprintf("case-%d", n+1), func(t *testing.T) {
+			h := (&Compress{}).Handle(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+				if tt.contentType != "" {
+					rw.Header().Set("Content-Type", tt.contentType)
+				}
+				if tt.contentEncoding != "" {
+					rw.Header().Set("Content-Encoding", tt.contentEncoding)
+				}
+				if tt.code != 0 {
+					rw.WriteHeader(tt.code)
+				}
+				// Write in small chunks to test buffering.
+				for i := 0; i < len(tt.body); i += 100 {
+					rw.Write([]byte(tt.body[i:min(i+100, len(tt.body))]))
+				}
+			}))
+			r := httptest.NewRequest("GET", "/", nil)
+			if tt.acceptEncoding != "" {
+				r.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			}
+			rw := httptest.NewRecorder()
+			h.ServeHTTP(rw, r)
+
+			wantCode := tt.code
+			if wantCode == 0 {
+				wantCode = http.StatusOK
+			}
+			assert.Equal(t, wantCode, rw.Code)
+			assert.Equal(t, "Accept-Encoding", rw.Header().Get("Vary"))
+			if tt.contentEncoding != "" {
+				assert.Equal(t, tt.contentEncoding, rw.Header().Get("Content-Encoding"))
+			} else {
+				assert.Equal(t, tt.wantEncoding, rw.Header().Get("Content-Encoding"))
+			}
+
+			var body io.Reader = rw.Body
+			switch tt.wantEncoding {
+			case "gzip":
+				zr, err := gzip.NewReader(rw.Body)
+				require.NoError(t, err)
+				body = zr
+			case "deflate":
+				zr, err := zlib.NewReader(rw.Body)
+				require.NoError(t, err)
+				body = zr
+			}
+			b, err := io.ReadAll(body)
+			require.NoError(t, err)
+			assert.Equal(t, tt.body, string(b))
+		})
+	}
+}
+
+func TestCompress_Flush(t *testing.T) {
+	h := (&Compress{MinSize: 1}).Handle(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Set("Content-Type", "text/event-stream")
+		rw.Write([]byte("data: foo\n\n"))
+		rw.(http.Flusher).Flush()
+		rw.Write([]byte("data: bar\n\n"))
+	}))
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	rw := httptest.NewRecorder()
+	h.ServeHTTP(rw, r)
+
+	assert.True(t, rw.Flushed)
+	assert.Equal(t, "gzip", rw.Header().Get("Content-Encoding"))
+	zr, err := gzip.NewReader(rw.Body)
+	require.NoError(t, err)
+	b, err := io.ReadAll(zr)
+	require.NoError(t, err)
+	assert.Equal(t, "data: foo\n\ndata: bar\n\n", string(b))
+}
+
+func TestCompress_Unwrap(t *testing.T) {
+	rw := httptest.NewRecorder()
+	h := (&Compress{}).Handle(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		u, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		require.True(t, ok)
+		assert.Same(t, rw, u.Unwrap())
+	}))
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	h.ServeHTTP(rw, r)
+}
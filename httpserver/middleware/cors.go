@@ -17,23 +17,135 @@ package middleware
 
 import (
 	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 )
 
-// CORS adds a basic support for CORS preflight requests.
+// defaultCORSMethods is the list of methods allowed if CORS.AllowedMethods
+// is empty.
+var defaultCORSMethods = []string{http.MethodGet, http.MethodHead, http.MethodPost}
+
+// CORS is a middleware that implements Cross-Origin Resource Sharing.
+//
+// Requests with the Origin header matching one of the AllowedOrigins get
+// the CORS response headers. A request is treated as a preflight request if
+// it uses the OPTIONS method and has the Access-Control-Request-Method
+// header. Preflight requests are answered by the middleware with a 204
+// response, or with a 403 response if the origin, method or headers are not
+// allowed. Other requests, including other OPTIONS requests, are passed to
+// the next handler.
+//
+// For backward compatibility, if the Origin function is set, the middleware
+// uses the legacy mode, in which the Origin, Headers and Methods functions
+// are used to set the response headers, and every OPTIONS request is
+// answered as a preflight request.
 type CORS struct {
+	// AllowedOrigins is a list of allowed origins, such as
+	// "https://example.com". The "*" entry allows all origins. An entry may
+	// contain a wildcard subdomain, such as "https://*.example.com", which
+	// matches all subdomains of example.com, but not example.com itself.
+	AllowedOrigins []string
+
+	// AllowedMethods is a list of methods allowed in cross-origin requests.
+	// If empty, GET, HEAD and POST are allowed.
+	AllowedMethods []string
+
+	// AllowedHeaders is a list of request headers allowed in cross-origin
+	// requests. The "*" entry allows all headers.
+	AllowedHeaders []string
+
+	// ExposedHeaders is a list of response headers that can be read by
+	// the client.
+	ExposedHeaders []string
+
+	// AllowCredentials allows requests with credentials, such as cookies.
+	// If set, the origin is sent back instead of "*".
+	AllowCredentials bool
+
+	// MaxAge is the time for which the result of a preflight request can be
+	// cached. If zero, the Access-Control-Max-Age header is not sent.
+	MaxAge time.Duration
+
 	// Origin is a function that returns a value of
-	// an Access-Control-Allow-Origin header. It cannot be nil.
+	// an Access-Control-Allow-Origin header.
+	//
+	// Deprecated: Use AllowedOrigins instead.
 	Origin func(r *http.Request) string
 	// Headers is a function that returns a value of
-	// an Access-Control-Allow-Headers header. It cannot be nil.
+	// an Access-Control-Allow-Headers header. It cannot be nil if Origin is set.
+	//
+	// Deprecated: Use AllowedHeaders instead.
 	Headers func(r *http.Request) string
 	// Methods is a function that returns a value of
-	// an Access-Control-Allow-Methods. It cannot be nil.
+	// an Access-Control-Allow-Methods. It cannot be nil if Origin is set.
+	//
+	// Deprecated: Use AllowedMethods instead.
 	Methods func(r *http.Request) string
 }
 
 // Handle implements the httpserver.Middleware interface.
 func (c *CORS) Handle(next http.Handler) http.Handler {
+	if c.Origin != nil {
+		return c.handleLegacy(next)
+	}
+	anyOrigin := slices.Contains(c.AllowedOrigins, "*")
+	anyHeader := slices.Contains(c.AllowedHeaders, "*")
+	methods := c.AllowedMethods
+	if len(methods) == 0 {
+		methods = defaultCORSMethods
+	}
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		headers := rw.Header()
+		origin := r.Header.Get("Origin")
+		preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+
+		// Responses depend on the Origin header unless all origins are
+		// allowed and "*" is sent back.
+		if !anyOrigin || c.AllowCredentials {
+			headers.Add("Vary", "Origin")
+		}
+		if preflight {
+			headers.Add("Vary", "Access-Control-Request-Method")
+			headers.Add("Vary", "Access-Control-Request-Headers")
+		}
+		if origin == "" {
+			next.ServeHTTP(rw, r)
+			return
+		}
+		allowed := anyOrigin || c.originAllowed(origin)
+		if !preflight {
+			if allowed {
+				c.setAllowOrigin(headers, origin, anyOrigin)
+				if len(c.ExposedHeaders) > 0 {
+					headers.Set("Access-Control-Expose-Headers", strings.Join(c.ExposedHeaders, ", "))
+				}
+			}
+			next.ServeHTTP(rw, r)
+			return
+		}
+
+		// Preflight request.
+		method := r.Header.Get("Access-Control-Request-Method")
+		reqHeaders := parseHeaderList(r.Header.Values("Access-Control-Request-Headers"))
+		if !allowed || !slices.Contains(methods, method) || (!anyHeader && !c.headersAllowed(reqHeaders)) {
+			rw.WriteHeader(http.StatusForbidden)
+			return
+		}
+		c.setAllowOrigin(headers, origin, anyOrigin)
+		headers.Set("Access-Control-Allow-Methods", strings.Join(methods, ", "))
+		if len(reqHeaders) > 0 {
+			headers.Set("Access-Control-Allow-Headers", strings.Join(reqHeaders, ", "))
+		}
+		if c.MaxAge > 0 {
+			headers.Set("Access-Control-Max-Age", strconv.Itoa(int(c.MaxAge.Seconds())))
+		}
+		rw.WriteHeader(http.StatusNoContent)
+	})
+}
+
+func (c *CORS) handleLegacy(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
 		headers := rw.Header()
 		headers.Set("Access-Control-Allow-Origin", c.Origin(r))
@@ -48,3 +160,61 @@ func (c *CORS) Handle(next http.Handler) http.Handler {
 		}
 	})
 }
+
+func (c *CORS) setAllowOrigin(headers http.Header, origin string, anyOrigin bool) {
+	if anyOrigin && !c.AllowCredentials {
+		headers.Set("Access-Control-Allow-Origin", "*")
+		return
+	}
+	headers.Set("Access-Control-Allow-Origin", origin)
+	if c.AllowCredentials {
+		headers.Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+func (c *CORS) originAllowed(origin string) bool {
+	origin = strings.ToLower(origin)
+	for _, o := range c.AllowedOrigins {
+		if matchOrigin(strings.ToLower(o), origin) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *CORS) headersAllowed(headers []string) bool {
+	for _, h := range headers {
+		if !slices.ContainsFunc(c.AllowedHeaders, func(a string) bool { return strings.EqualFold(a, h) }) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchOrigin checks if the origin matches the pattern. The pattern may
+// contain a single "*" in place of subdomains.
+func matchOrigin(pattern, origin string) bool {
+	prefix, suffix, wildcard := strings.Cut(pattern, "*")
+	if !wildcard {
+		return pattern == origin
+	}
+	if len(origin) <= len(prefix)+len(suffix) || !strings.HasPrefix(origin, prefix) || !strings.HasSuffix(origin, suffix) {
+		return false
+	}
+	// The wildcard may only match subdomain labels.
+	sub := origin[len(prefix) : len(origin)-len(suffix)]
+	return !strings.ContainsAny(sub, "/:@?#") && !strings.HasPrefix(sub, ".")
+}
+
+// parseHeaderList parses a comma-separated list of header names.
+func parseHeaderList(values []string) []string {
+	var headers []string
+	for _, v := range values {
+		for _, h := range strings.Split(v, ",") {
+			if h = strings.TrimSpace(h); h != "" {
+				headers = append(headers, strings.ToLower(h))
+			}
+		}
+	}
+	return headers
+}
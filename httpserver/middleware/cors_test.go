@@ -16,9 +16,11 @@
 package middleware
 
 import (
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -58,3 +60,173 @@ func TestCORS_Get(t *testing.T) {
 	assert.Empty(t, rw.Header().Get("Access-Control-Allow-Methods"))
 	assert.Empty(t, rw.Header().Get("Access-Control-Allow-Max-Age"))
 }
+
+func TestCORS_Origins(t *testing.T) {
+	tests := []struct {
+		allowed []string
+		origin  string
+		want    bool
+	}{
+		{allowed: []string{"https://example.com"}, origin: "https://example.com", want: true},
+		{allowed: []string{"https://example.com"}, origin: "https://EXAMPLE.com", want: true},
+		{allowed: []string{"https://example.com"}, origin: "http://example.com", want: false},
+		{allowed: []string{"https://example.com"}, origin: "https://example.com:8080", want: false},
+		{allowed: []string{"https://*.example.com"}, origin: "https://foo.example.com", want: true},
+		{allowed: []string{"https://*.example.com"}, origin: "https://foo.bar.example.com", want: true},
+		{allowed: []string{"https://*.example.com"}, origin: "https://example.com", want: false},
+		{allowed: []string{"https://*.example.com"}, origin: "https://.example.com", want: false},
+		{allowed: []string{"https://*.example.com"}, origin: "https://fooexample.com", want: false},
+		{allowed: []string{"https://*.example.com"}, origin: "https://evil.com/.example.com", want: false},
+		{allowed: []string{"https://*.example.com"}, origin: "https://foo.example.com.evil.com", want: false},
+		{allowed: []string{"https://foo.com", "https://bar.com"}, origin: "https://bar.com", want: true},
+	}
+	for n, tt := range tests {
+		t.Run(fmt.Sprintf("case-%d", n+1), func(t *testing.T) {
+			h := (&CORS{AllowedOrigins: tt.allowed}).Handle(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+			r := httptest.NewRequest("GET", "/", nil)
+			r.Header.Set("Origin", tt.origin)
+			rw := httptest.NewRecorder()
+			h.ServeHTTP(rw, r)
+
+			assert.Equal(t, http.StatusOK, rw.Code)
+			assert.Equal(t, "Origin", rw.Header().Get("Vary"))
+			if tt.want {
+				assert.Equal(t, tt.origin, rw.Header().Get("Access-Control-Allow-Origin"))
+			} else {
+				assert.Empty(t, rw.Header().Get("Access-Control-Allow-Origin"))
+			}
+		})
+	}
+}
+
+func TestCORS_Request(t *testing.T) {
+	tests := []struct {
+		cors        *CORS
+		origin      string
+		wantOrigin  string
+		wantCreds   string
+		wantExposed string
+		wantVary    []string
+	}{
+		{
+			cors:       &CORS{AllowedOrigins: []string{"*"}},
+			origin:     "https://example.com",
+			wantOrigin: "*",
+		},
+		{
+			cors:       &CORS{AllowedOrigins: []string{"*"}, AllowCredentials: true},
+			origin:     "https://example.com",
+			wantOrigin: "https://example.com",
+			wantCreds:  "true",
+			wantVary:   []string{"Origin"},
+		},
+		{
+			cors:        &CORS{AllowedOrigins: []string{"https://example.com"}, ExposedHeaders: []string{"X-Foo", "X-Bar"}},
+			origin:      "https://example.com",
+			wantOrigin:  "https://example.com",
+			wantExposed: "X-Foo, X-Bar",
+			wantVary:    []string{"Origin"},
+		},
+		{
+			cors:     &CORS{AllowedOrigins: []string{"https://example.com"}, AllowCredentials: true},
+			origin:   "https://other.com",
+			wantVary: []string{"Origin"},
+		},
+		{
+			cors:     &CORS{AllowedOrigins: []string{"https://example.com"}},
+			wantVary: []string{"Origin"},
+		},
+	}
+	for n, tt := range tests {
+		t.Run(fmt.Sprintf("case-%d", n+1), func(t *testing.T) {
+			called := false
+			h := tt.cors.Handle(http.HandlerFunc(func(http.ResponseWriter, *http.Request) { called = true }))
+			r := httptest.NewRequest("GET", "/", nil)
+			if tt.origin != "" {
+				r.Header.Set("Origin", tt.origin)
+			}
+			rw := httptest.NewRecorder()
+			h.ServeHTTP(rw, r)
+
+			assert.True(t, called)
+			assert.Equal(t, tt.wantOrigin, rw.Header().Get("Access-Control-Allow-Origin"))
+			assert.Equal(t, tt.wantCreds, rw.Header().Get("Access-Control-Allow-Credentials"))
+			assert.Equal(t, tt.wantExposed, rw.Header().Get("Access-Control-Expose-Headers"))
+			assert.Equal(t, tt.wantVary, rw.Header().Values("Vary"))
+		})
+	}
+}
+
+func TestCORS_Preflight(t *testing.T) {
+	c := &CORS{
+		AllowedOrigins: []string{"https://example.com"},
+		AllowedMethods: []string{"GET", "POST", "PUT"},
+		AllowedHeaders: []string{"Content-Type", "X-Foo"},
+		MaxAge:         time.Hour,
+	}
+	tests := []struct {
+		cors        *CORS
+		origin      string
+		method      string
+		headers     string
+		wantStatus  int
+		wantHeaders string
+		wantNext    bool
+	}{
+		{cors: c, origin: "https://example.com", method: "PUT", wantStatus: http.StatusNoContent},
+		{cors: c, origin: "https://example.com", method: "POST", headers: "content-type, x-foo", wantStatus: http.StatusNoContent, wantHeaders: "content-type, x-foo"},
+		{cors: c, origin: "https://example.com", method: "DELETE", wantStatus: http.StatusForbidden},
+		{cors: c, origin: "https://example.com", method: "POST", headers: "X-Bar", wantStatus: http.StatusForbidden},
+		{cors: c, origin: "https://other.com", method: "POST", wantStatus: http.StatusForbidden},
+		{cors: &CORS{AllowedOrigins: []string{"*"}, AllowedHeaders: []string{"*"}}, origin: "https://example.com", method: "POST", headers: "X-Bar", wantStatus: http.StatusNoContent, wantHeaders: "x-bar"},
+		{cors: &CORS{AllowedOrigins: []string{"*"}}, origin: "https://example.com", method: "PUT", wantStatus: http.StatusForbidden},
+		// Not a preflight request, passed to the next handler.
+		{cors: c, origin: "https://example.com", wantStatus: http.StatusOK, wantNext: true},
+		{cors: c, method: "POST", wantStatus: http.StatusOK, wantNext: true},
+	}
+	for n, tt := range tests {
+		t.Run(fmt.Sprintf("case-%d", n+1), func(t *testing.T) {
+			called := false
+			h := tt.cors.Handle(http.HandlerFunc(func(http.ResponseWriter, *http.Request) { called = true }))
+			r := httptest.NewRequest("OPTIONS", "/", nil)
+			if tt.origin != "" {
+				r.Header.Set("Origin", tt.origin)
+			}
+			if tt.method != "" {
+				r.Header.Set("Access-Control-Request-Method", tt.method)
+			}
+			if tt.headers != "" {
+				r.Header.Set("Access-Control-Request-Headers", tt.headers)
+			}
+			rw := httptest.NewRecorder()
+			h.ServeHTTP(rw, r)
+
+			assert.Equal(t, tt.wantStatus, rw.Code)
+			assert.Equal(t, tt.wantNext, called)
+			if tt.wantStatus != http.StatusNoContent {
+				assert.Empty(t, rw.Header().Get("Access-Control-Allow-Methods"))
+				return
+			}
+			wantOrigin := tt.origin
+			if tt.cors.AllowedOrigins[0] == "*" {
+				wantOrigin = "*"
+			}
+			assert.Equal(t, wantOrigin, rw.Header().Get("Access-Control-Allow-Origin"))
+			assert.Equal(t, tt.wantHeaders, rw.Header().Get("Access-Control-Allow-Headers"))
+			assert.NotEmpty(t, rw.Header().Get("Access-Control-Allow-Methods"))
+			assert.Contains(t, rw.Header().Values("Vary"), "Access-Control-Request-Method")
+		})
+	}
+}
+
+func TestCORS_MaxAge(t *testing.T) {
+	h := (&CORS{AllowedOrigins: []string{"*"}, MaxAge: time.Hour}).Handle(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	r := httptest.NewRequest("OPTIONS", "/", nil)
+	r.Header.Set("Origin", "https://example.com")
+	r.Header.Set("Access-Control-Request-Method", "GET")
+	rw := httptest.NewRecorder()
+	h.ServeHTTP(rw, r)
+	assert.Equal(t, "3600", rw.Header().Get("Access-Control-Max-Age"))
+	assert.Equal(t, "*", rw.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "GET, HEAD, POST", rw.Header().Get("Access-Control-Allow-Methods"))
+}
@@ -16,6 +16,7 @@
 package middleware
 
 import (
+	"context"
 	"net/http"
 	"strings"
 )
@@ -24,7 +25,8 @@ import (
 // be used with Kubernetes' liveliness probe.
 //
 // It returns a 200 response if the service is healthy, otherwise it returns a
-// 503 response.
+// 503 response. The service is considered unhealthy while the HTTP server
+// is in the drain period (see httpserver.WithDrainPeriod).
 type HealthCheck struct {
 	// Path is the path where the health check will be available.
 	Path string
@@ -37,7 +39,7 @@ func (c *HealthCheck) Handle(next http.Handler) http.Handler {
 	path := "/" + strings.Trim(c.Path, "/")
 	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
 		if path == strings.TrimRight(r.URL.Path, "/") {
-			if c.Check != nil && !c.Check(r) {
+			if Draining(r.Context()) || (c.Check != nil && !c.Check(r)) {
 				rw.WriteHeader(http.StatusServiceUnavailable)
 				return
 			}
@@ -47,3 +49,19 @@ func (c *HealthCheck) Handle(next http.Handler) http.Handler {
 		next.ServeHTTP(rw, r)
 	})
 }
+
+type drainingCtxKey struct{}
+
+// WithDraining returns a copy of the context with a function that reports
+// whether the server is in the drain period. The httpserver package adds it
+// to the context of every request.
+func WithDraining(ctx context.Context, draining func() bool) context.Context {
+	return context.WithValue(ctx, drainingCtxKey{}, draining)
+}
+
+// Draining returns true if the server that handles the request is in
+// the drain period.
+func Draining(ctx context.Context) bool {
+	draining, ok := ctx.Value(drainingCtxKey{}).(func() bool)
+	return ok && draining()
+}
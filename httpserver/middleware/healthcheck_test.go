@@ -16,6 +16,7 @@
 package middleware
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
@@ -66,3 +67,18 @@ func TestHealthCheck(t *testing.T) {
 		})
 	}
 }
+
+func TestHealthCheck_Draining(t *testing.T) {
+	draining := false
+	h := (&HealthCheck{Path: "/health"}).Handle(http.NotFoundHandler())
+	ctx := WithDraining(context.Background(), func() bool { return draining })
+
+	rw := httptest.NewRecorder()
+	h.ServeHTTP(rw, httptest.NewRequest("GET", "/health", nil).WithContext(ctx))
+	assert.Equal(t, http.StatusOK, rw.Code)
+
+	draining = true
+	rw = httptest.NewRecorder()
+	h.ServeHTTP(rw, httptest.NewRequest("GET", "/health", nil).WithContext(ctx))
+	assert.Equal(t, http.StatusServiceUnavailable, rw.Code)
+}
@@ -0,0 +1,178 @@
+//  Copyright (C) 2021-2023 Chronicle Labs, Inc.
+//
+//  This program is free software: you can redistribute it and/or modify
+//  it under the terms of the GNU Affero General Public License as
+//  published by the Free Software Foundation, either version 3 of the
+//  License, or (at your option) any later version.
+//
+//  This program is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of
+//  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU Affero General Public License for more details.
+//
+//  You should have received a copy of the GNU Affero General Public License
+//  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package middleware
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// HMACKeyIDHeader is the name of the header that contains the ID of
+	// the key used to sign the request.
+	HMACKeyIDHeader = "X-Signature-Key"
+
+	// HMACTimestampHeader is the name of the header that contains the Unix
+	// time at which the request was signed.
+	HMACTimestampHeader = "X-Signature-Timestamp"
+
+	// HMACSignatureHeader is the name of the header that contains the
+	// hex encoded HMAC-SHA256 signature of the request.
+	HMACSignatureHeader = "X-Signature"
+
+	// defaultHMACMaxSkew is the default maximum difference between
+	// the request timestamp and the current time.
+	defaultHMACMaxSkew = 5 * time.Minute
+
+	// defaultHMACMaxBodySize is the default maximum size of the request
+	// body read to verify the signature.
+	defaultHMACMaxBodySize = 1 << 20
+)
+
+// HMAC is an Authenticator that authenticates requests signed with
+// a shared secret using HMAC-SHA256.
+//
+// The signature is calculated over the method, the request URI, the
+// timestamp and the SHA-256 hash of the body, separated by new lines.
+// Requests can be signed using the SignRequest function.
+//
+// To verify the signature, the whole body is read into memory. Requests
+// with bodies larger than MaxBodySize are rejected, but the Auth middleware
+// should still be placed after the MaxBodySize middleware, so that the limit
+// applies to all requests and the connection is closed when it is exceeded.
+type HMAC struct {
+	// Keys maps key IDs to secrets. Key IDs are used as principal names.
+	Keys map[string][]byte
+
+	// MaxSkew is the maximum difference between the request timestamp and
+	// the current time. If zero, 5 minutes is used.
+	MaxSkew time.Duration
+
+	// MaxBodySize is the maximum size of the request body in bytes. If zero,
+	// 1 MiB is used.
+	MaxBodySize int64
+
+	now func() time.Time
+}
+
+// Authenticate implements the Authenticator interface.
+func (h *HMAC) Authenticate(r *http.Request) (*Principal, error) {
+	keyID := r.Header.Get(HMACKeyIDHeader)
+	if keyID == "" {
+		return nil, ErrNoCredentials
+	}
+	if err := h.verify(r, keyID); err != nil {
+		return nil, fmt.Errorf("hmac: %w: %w", ErrInvalidCredentials, err)
+	}
+	return &Principal{Name: keyID, Scheme: "hmac"}, nil
+}
+
+// Challenge implements the Challenger interface.
+func (h *HMAC) Challenge() string {
+	return "HMAC-SHA256"
+}
+
+func (h *HMAC) verify(r *http.Request, keyID string) error {
+	secret, ok := h.Keys[keyID]
+	if !ok {
+		return errors.New("unknown key")
+	}
+	ts, err := strconv.ParseInt(r.Header.Get(HMACTimestampHeader), 10, 64)
+	if err != nil {
+		return errors.New("invalid timestamp")
+	}
+	now := time.Now()
+	if h.now != nil {
+		now = h.now()
+	}
+	maxSkew := h.MaxSkew
+	if maxSkew == 0 {
+		maxSkew = defaultHMACMaxSkew
+	}
+	if skew := now.Sub(time.Unix(ts, 0)); skew > maxSkew || skew < -maxSkew {
+		return errors.New("timestamp out of range")
+	}
+	sig, err := hex.DecodeString(r.Header.Get(HMACSignatureHeader))
+	if err != nil {
+		return errors.New("invalid signature")
+	}
+	maxBodySize := h.MaxBodySize
+	if maxBodySize == 0 {
+		maxBodySize = defaultHMACMaxBodySize
+	}
+	expected, err := requestSignature(r, secret, ts, maxBodySize)
+	if err != nil {
+		return err
+	}
+	if !hmac.Equal(sig, expected) {
+		return errors.New("invalid signature")
+	}
+	return nil
+}
+
+// SignRequest signs the request for the HMAC authenticator. It sets
+// the HMACKeyIDHeader, HMACTimestampHeader and HMACSignatureHeader headers.
+// The request body is read and replaced with a copy.
+func SignRequest(r *http.Request, keyID string, secret []byte) error {
+	ts := time.Now().Unix()
+	sig, err := requestSignature(r, secret, ts, 0)
+	if err != nil {
+		return err
+	}
+	r.Header.Set(HMACKeyIDHeader, keyID)
+	r.Header.Set(HMACTimestampHeader, strconv.FormatInt(ts, 10))
+	r.Header.Set(HMACSignatureHeader, hex.EncodeToString(sig))
+	return nil
+}
+
+// requestSignature calculates the signature of the request. The request
+// body is read and replaced with a copy. If maxBodySize is greater than
+// zero, bodies larger than maxBodySize bytes are rejected.
+func requestSignature(r *http.Request, secret []byte, ts int64, maxBodySize int64) ([]byte, error) {
+	var body []byte
+	if r.Body != nil {
+		var (
+			rd  io.Reader = r.Body
+			err error
+		)
+		if maxBodySize > 0 {
+			rd = io.LimitReader(r.Body, maxBodySize+1)
+		}
+		if body, err = io.ReadAll(rd); err != nil {
+			return nil, fmt.Errorf("unable to read body: %w", err)
+		}
+		if maxBodySize > 0 && int64(len(body)) > maxBodySize {
+			return nil, errors.New("body too large")
+		}
+		_ = r.Body.Close()
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		r.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(body)), nil
+		}
+	}
+	bodyHash := sha256.Sum256(body)
+	mac := hmac.New(sha256.New, secret)
+	_, _ = fmt.Fprintf(mac, "%s\n%s\n%d\n%x", r.Method, r.URL.RequestURI(), ts, bodyHash)
+	return mac.Sum(nil), nil
+}
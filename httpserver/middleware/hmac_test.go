@@ -0,0 +1,140 @@
+//  Copyright (C) 2021-2023 Chronicle Labs, Inc.
+//
+//  This program is free software: you can redistribute it and/or modify
+//  it under the terms of the GNU Affero General Public License as
+//  published by the Free Software Foundation, either version 3 of the
+//  License, or (at your option) any later version.
+//
+//  This program is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of
+//  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU Affero General Public License for more details.
+//
+//  You should have received a copy of the GNU Affero General Public License
+//  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package middleware
+
+import (
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHMAC(t *testing.T) {
+	h := &HMAC{Keys: map[string][]byte{"alice": []byte("secret")}}
+
+	tests := []struct {
+		modify  func(r *httptestRequest)
+		wantErr error
+	}{
+		{},
+		{
+			modify:  func(r *httptestRequest) { r.body = "tampered" },
+			wantErr: ErrInvalidCredentials,
+		},
+		{
+			modify:  func(r *httptestRequest) { r.target = "/foo?bar=2" },
+			wantErr: ErrInvalidCredentials,
+		},
+		{
+			modify:  func(r *httptestRequest) { r.method = "PUT" },
+			wantErr: ErrInvalidCredentials,
+		},
+		{
+			modify:  func(r *httptestRequest) { r.headers[HMACKeyIDHeader] = "bob" },
+			wantErr: ErrInvalidCredentials,
+		},
+		{
+			modify: func(r *httptestRequest) {
+				r.headers[HMACTimestampHeader] = strconv.FormatInt(time.Now().Add(-time.Hour).Unix(), 10)
+			},
+			wantErr: ErrInvalidCredentials,
+		},
+		{
+			modify:  func(r *httptestRequest) { r.headers[HMACSignatureHeader] = "zz" },
+			wantErr: ErrInvalidCredentials,
+		},
+		{
+			modify:  func(r *httptestRequest) { delete(r.headers, HMACKeyIDHeader) },
+			wantErr: ErrNoCredentials,
+		},
+	}
+	for n, tt := range tests {
+		t.Run(fmt.Sprintf("case-%d", n+1), func(t *testing.T) {
+			// Sign the request.
+			r := httptest.NewRequest("POST", "/foo?bar=1", strings.NewReader("body"))
+			require.NoError(t, SignRequest(r, "alice", []byte("secret")))
+			body, err := io.ReadAll(r.Body)
+			require.NoError(t, err)
+			assert.Equal(t, "body", string(body))
+
+			// Rebuild it, optionally modified.
+			tr := &httptestRequest{method: "POST", target: "/foo?bar=1", body: "body", headers: map[string]string{}}
+			for _, k := range []string{HMACKeyIDHeader, HMACTimestampHeader, HMACSignatureHeader} {
+				tr.headers[k] = r.Header.Get(k)
+			}
+			if tt.modify != nil {
+				tt.modify(tr)
+			}
+			r = httptest.NewRequest(tr.method, tr.target, strings.NewReader(tr.body))
+			for k, v := range tr.headers {
+				r.Header.Set(k, v)
+			}
+
+			p, err := h.Authenticate(r)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, "alice", p.Name)
+			assert.Equal(t, "hmac", p.Scheme)
+
+			// The body must still be readable by the next handler.
+			body, err = io.ReadAll(r.Body)
+			require.NoError(t, err)
+			assert.Equal(t, "body", string(body))
+		})
+	}
+}
+
+func TestHMAC_MaxBodySize(t *testing.T) {
+	h := &HMAC{Keys: map[string][]byte{"alice": []byte("secret")}, MaxBodySize: 4}
+
+	tests := []struct {
+		body    string
+		wantErr bool
+	}{
+		{body: ""},
+		{body: "body"},
+		{body: "body!", wantErr: true},
+	}
+	for n, tt := range tests {
+		t.Run(fmt.Sprintf("case-%d", n+1), func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/", strings.NewReader(tt.body))
+			require.NoError(t, SignRequest(r, "alice", []byte("secret")))
+
+			_, err := h.Authenticate(r)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidCredentials)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+type httptestRequest struct {
+	method  string
+	target  string
+	body    string
+	headers map[string]string
+}
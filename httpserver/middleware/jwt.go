@@ -0,0 +1,228 @@
+//  Copyright (C) 2021-2023 Chronicle Labs, Inc.
+//
+//  This program is free software: you can redistribute it and/or modify
+//  it under the terms of the GNU Affero General Public License as
+//  published by the Free Software Foundation, either version 3 of the
+//  License, or (at your option) any later version.
+//
+//  This program is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of
+//  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU Affero General Public License for more details.
+//
+//  You should have received a copy of the GNU Affero General Public License
+//  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package middleware
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// JWTKey is a key used to verify JWT signatures.
+type JWTKey struct {
+	// ID is the key ID matched against the "kid" header of the token.
+	// Tokens without the "kid" header are verified with all keys.
+	ID string
+
+	// Secret is a secret used to verify HS256 signatures.
+	Secret []byte
+
+	// PublicKey is a P-256 public key used to verify ES256 signatures.
+	PublicKey *ecdsa.PublicKey
+}
+
+// JWT is an Authenticator that authenticates requests using JSON Web Tokens
+// sent in the Authorization header with the "Bearer" scheme.
+//
+// Tokens must be signed with HS256 or ES256 using one of the configured
+// keys. The algorithm must match the type of the key. The "exp" and "nbf"
+// claims are verified if present, the "sub" claim is required and is used as
+// the principal name.
+type JWT struct {
+	// Keys is a set of keys used to verify tokens.
+	Keys []JWTKey
+
+	// Issuer, if not empty, must match the "iss" claim.
+	Issuer string
+
+	// Audience, if not empty, must be one of the values of the "aud" claim.
+	Audience string
+
+	// Leeway is the allowed clock skew when verifying the "exp" and "nbf"
+	// claims.
+	Leeway time.Duration
+
+	now func() time.Time
+}
+
+// Authenticate implements the Authenticator interface.
+func (j *JWT) Authenticate(r *http.Request) (*Principal, error) {
+	token, ok := authorizationCredentials(r, "Bearer")
+	if !ok {
+		return nil, ErrNoCredentials
+	}
+	claims, err := j.verify(token)
+	if err != nil {
+		return nil, fmt.Errorf("jwt: %w: %w", ErrInvalidCredentials, err)
+	}
+	return &Principal{Name: claims.Subject, Scheme: "jwt", Claims: claims.all}, nil
+}
+
+// Challenge implements the Challenger interface.
+func (j *JWT) Challenge() string {
+	return "Bearer"
+}
+
+// ParseECPublicKey parses a PEM encoded P-256 public key in the PKIX format.
+func ParseECPublicKey(data []byte) (*ecdsa.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("jwt: invalid PEM data")
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("jwt: unable to parse public key: %w", err)
+	}
+	ecKey, ok := key.(*ecdsa.PublicKey)
+	if !ok || ecKey.Curve != elliptic.P256() {
+		return nil, errors.New("jwt: public key must be a P-256 key")
+	}
+	return ecKey, nil
+}
+
+// LoadECPublicKey loads a PEM encoded P-256 public key from a file.
+func LoadECPublicKey(path string) (*ecdsa.PublicKey, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("jwt: unable to read public key: %w", err)
+	}
+	return ParseECPublicKey(b)
+}
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+type jwtClaims struct {
+	Subject   string      `json:"sub"`
+	Issuer    string      `json:"iss"`
+	Audience  jwtAudience `json:"aud"`
+	ExpiresAt *float64    `json:"exp"`
+	NotBefore *float64    `json:"nbf"`
+
+	all map[string]any
+}
+
+// jwtAudience is the "aud" claim, which can be either a string or an array
+// of strings.
+type jwtAudience []string
+
+func (a *jwtAudience) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		*a = jwtAudience{s}
+		return nil
+	}
+	return json.Unmarshal(b, (*[]string)(a))
+}
+
+func (j *JWT) verify(token string) (*jwtClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed token")
+	}
+	var header jwtHeader
+	if err := decodeJWTPart(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("invalid header: %w", err)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("invalid signature: %w", err)
+	}
+	if !j.verifySignature(header, []byte(parts[0]+"."+parts[1]), sig) {
+		return nil, errors.New("invalid signature")
+	}
+	claims := &jwtClaims{}
+	if err := decodeJWTPart(parts[1], claims); err != nil {
+		return nil, fmt.Errorf("invalid claims: %w", err)
+	}
+	if err := decodeJWTPart(parts[1], &claims.all); err != nil {
+		return nil, fmt.Errorf("invalid claims: %w", err)
+	}
+	now := time.Now()
+	if j.now != nil {
+		now = j.now()
+	}
+	if claims.ExpiresAt != nil && !now.Before(unixTime(*claims.ExpiresAt).Add(j.Leeway)) {
+		return nil, errors.New("token expired")
+	}
+	if claims.NotBefore != nil && now.Before(unixTime(*claims.NotBefore).Add(-j.Leeway)) {
+		return nil, errors.New("token not valid yet")
+	}
+	if j.Issuer != "" && claims.Issuer != j.Issuer {
+		return nil, errors.New("invalid issuer")
+	}
+	if j.Audience != "" && !slices.Contains(claims.Audience, j.Audience) {
+		return nil, errors.New("invalid audience")
+	}
+	if claims.Subject == "" {
+		return nil, errors.New("missing subject")
+	}
+	return claims, nil
+}
+
+func (j *JWT) verifySignature(header jwtHeader, input, sig []byte) bool {
+	for _, key := range j.Keys {
+		if header.Kid != "" && key.ID != header.Kid {
+			continue
+		}
+		switch {
+		case header.Alg == "HS256" && len(key.Secret) > 0:
+			mac := hmac.New(sha256.New, key.Secret)
+			mac.Write(input)
+			if hmac.Equal(mac.Sum(nil), sig) {
+				return true
+			}
+		case header.Alg == "ES256" && key.PublicKey != nil:
+			if len(sig) != 64 {
+				return false
+			}
+			hash := sha256.Sum256(input)
+			r := new(big.Int).SetBytes(sig[:32])
+			s := new(big.Int).SetBytes(sig[32:])
+			if ecdsa.Verify(key.PublicKey, hash[:], r, s) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func decodeJWTPart(s string, v any) error {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+func unixTime(s float64) time.Time {
+	return time.Unix(0, int64(s*float64(time.Second)))
+}
@@ -0,0 +1,169 @@
+//  Copyright (C) 2021-2023 Chronicle Labs, Inc.
+//
+//  This program is free software: you can redistribute it and/or modify
+//  it under the terms of the GNU Affero General Public License as
+//  published by the Free Software Foundation, either version 3 of the
+//  License, or (at your option) any later version.
+//
+//  This program is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of
+//  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU Affero General Public License for more details.
+//
+//  You should have received a copy of the GNU Affero General Public License
+//  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package middleware
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func signJWT(t *testing.T, header, claims map[string]any, key any) string {
+	h, err := json.Marshal(header)
+	require.NoError(t, err)
+	c, err := json.Marshal(claims)
+	require.NoError(t, err)
+	input := base64.RawURLEncoding.EncodeToString(h) + "." + base64.RawURLEncoding.EncodeToString(c)
+	var sig []byte
+	switch k := key.(type) {
+	case []byte:
+		mac := hmac.New(sha256.New, k)
+		mac.Write([]byte(input))
+		sig = mac.Sum(nil)
+	case *ecdsa.PrivateKey:
+		hash := sha256.Sum256([]byte(input))
+		r, s, err := ecdsa.Sign(rand.Reader, k, hash[:])
+		require.NoError(t, err)
+		sig = make([]byte, 64)
+		r.FillBytes(sig[:32])
+		s.FillBytes(sig[32:])
+	}
+	return input + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func TestJWT(t *testing.T) {
+	secret := []byte("secret")
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	now := time.Unix(1700000000, 0)
+	j := &JWT{
+		Keys: []JWTKey{
+			{ID: "hs", Secret: secret},
+			{ID: "es", PublicKey: &ecKey.PublicKey},
+		},
+		Issuer:   "issuer",
+		Audience: "audience",
+		Leeway:   time.Second,
+		now:      func() time.Time { return now },
+	}
+	claims := func(overrides map[string]any) map[string]any {
+		c := map[string]any{
+			"sub": "alice",
+			"iss": "issuer",
+			"aud": "audience",
+			"exp": now.Add(time.Minute).Unix(),
+			"nbf": now.Add(-time.Minute).Unix(),
+		}
+		for k, v := range overrides {
+			if v == nil {
+				delete(c, k)
+				continue
+			}
+			c[k] = v
+		}
+		return c
+	}
+	hs256 := map[string]any{"alg": "HS256", "typ": "JWT"}
+	es256 := map[string]any{"alg": "ES256", "typ": "JWT", "kid": "es"}
+
+	tests := []struct {
+		token   string
+		wantErr error
+	}{
+		// Valid tokens.
+		{token: signJWT(t, hs256, claims(nil), secret)},
+		{token: signJWT(t, es256, claims(nil), ecKey)},
+		{token: signJWT(t, hs256, claims(map[string]any{"aud": []string{"foo", "audience"}}), secret)},
+		{token: signJWT(t, hs256, claims(map[string]any{"exp": nil, "nbf": nil}), secret)},
+		{token: signJWT(t, hs256, claims(map[string]any{"exp": now.Unix()}), secret)},
+		// Invalid signatures.
+		{token: signJWT(t, hs256, claims(nil), []byte("other")), wantErr: ErrInvalidCredentials},
+		{token: signJWT(t, es256, claims(nil), otherKey), wantErr: ErrInvalidCredentials},
+		{token: signJWT(t, map[string]any{"alg": "HS256", "kid": "es"}, claims(nil), secret), wantErr: ErrInvalidCredentials},
+		{token: signJWT(t, map[string]any{"alg": "none"}, claims(nil), nil), wantErr: ErrInvalidCredentials},
+		// Invalid claims.
+		{token: signJWT(t, hs256, claims(map[string]any{"exp": now.Add(-2 * time.Second).Unix()}), secret), wantErr: ErrInvalidCredentials},
+		{token: signJWT(t, hs256, claims(map[string]any{"nbf": now.Add(2 * time.Second).Unix()}), secret), wantErr: ErrInvalidCredentials},
+		{token: signJWT(t, hs256, claims(map[string]any{"iss": "foo"}), secret), wantErr: ErrInvalidCredentials},
+		{token: signJWT(t, hs256, claims(map[string]any{"aud": "foo"}), secret), wantErr: ErrInvalidCredentials},
+		{token: signJWT(t, hs256, claims(map[string]any{"sub": nil}), secret), wantErr: ErrInvalidCredentials},
+		// Malformed tokens.
+		{token: "foo", wantErr: ErrInvalidCredentials},
+		{token: "foo.bar.baz", wantErr: ErrInvalidCredentials},
+	}
+	for n, tt := range tests {
+		t.Run(fmt.Sprintf("case-%d", n+1), func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.Header.Set("Authorization", "Bearer "+tt.token)
+			p, err := j.Authenticate(r)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, "alice", p.Name)
+			assert.Equal(t, "jwt", p.Scheme)
+			assert.Equal(t, "issuer", p.Claims["iss"])
+		})
+	}
+}
+
+func TestJWT_NoCredentials(t *testing.T) {
+	j := &JWT{Keys: []JWTKey{{Secret: []byte("secret")}}}
+	r := httptest.NewRequest("GET", "/", nil)
+	_, err := j.Authenticate(r)
+	assert.ErrorIs(t, err, ErrNoCredentials)
+
+	r.Header.Set("Authorization", "Basic Zm9vOmJhcg==")
+	_, err = j.Authenticate(r)
+	assert.ErrorIs(t, err, ErrNoCredentials)
+}
+
+func TestParseECPublicKey(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	require.NoError(t, err)
+	pub, err := ParseECPublicKey(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+	require.NoError(t, err)
+	assert.True(t, pub.Equal(&key.PublicKey))
+
+	key384, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	require.NoError(t, err)
+	der, err = x509.MarshalPKIXPublicKey(&key384.PublicKey)
+	require.NoError(t, err)
+	_, err = ParseECPublicKey(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+	assert.Error(t, err)
+
+	_, err = ParseECPublicKey([]byte("foo"))
+	assert.Error(t, err)
+}
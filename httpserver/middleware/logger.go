@@ -16,6 +16,7 @@
 package middleware
 
 import (
+	"context"
 	"net/http"
 	"time"
 
@@ -24,11 +25,24 @@ import (
 
 const httpRequestLog = "HTTP request"
 
+// defaultMaxBodyLogSize is the default maximum number of bytes of request
+// and response bodies logged in the debug mode.
+const defaultMaxBodyLogSize = 4096
+
 // Logger prints logs for each request. If the log level is set to debug, it
 // will print the contents of requests and responses.
+//
+// If the request ID or the trace context were assigned by the RequestID
+// middleware, they are added to the log fields. The same applies to the
+// principal authenticated by the Auth middleware.
 type Logger struct {
 	// Log is an instance of a log.Logger. It cannot be nil, otherwise code will panic.
 	Log log.Logger
+
+	// MaxBodyLogSize is the maximum number of bytes of request and response
+	// bodies logged in the debug mode. Longer bodies are truncated. If zero,
+	// 4096 is used. If negative, bodies are not truncated.
+	MaxBodyLogSize int
 }
 
 // Handle implements the httpserver.Middleware interface.
@@ -36,9 +50,15 @@ func (l *Logger) Handle(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
 		t := time.Now()
 		e := l.Log
+		h := &principalHolder{}
+		r = r.WithContext(context.WithValue(r.Context(), principalHolderCtxKey{}, h))
 		if l.Log.Level() >= log.Debug {
-			rw = newRecorder(rw)
-			e = e.WithField("request", string(readRequest(r)))
+			limit := l.MaxBodyLogSize
+			if limit == 0 {
+				limit = defaultMaxBodyLogSize
+			}
+			rw = newRecorder(rw, limit)
+			e = e.WithField("request", readRequest(r, limit))
 		}
 		defer func() {
 			e = e.WithFields(log.Fields{
@@ -47,9 +67,20 @@ func (l *Logger) Handle(next http.Handler) http.Handler {
 				"method":     r.Method,
 				"url":        r.URL.String(),
 			})
+			if id := RequestIDFromContext(r.Context()); id != "" {
+				e = e.WithField("requestID", id)
+			}
+			if trace, ok := TraceFromContext(r.Context()); ok {
+				e = e.WithField("traceID", trace.TraceID)
+			}
+			if p, ok := PrincipalFromContext(r.Context()); ok {
+				e = e.WithField("principal", p.Name)
+			} else if h.principal != nil {
+				e = e.WithField("principal", h.principal.Name)
+			}
 			if l.Log.Level() >= log.Debug {
 				e = e.WithFields(log.Fields{
-					"response": string(readResponse(rw.(*recorder))),
+					"response": readResponse(rw.(*recorder)),
 					"status":   rw.(*recorder).code,
 				})
 				e.Debug(httpRequestLog)
@@ -16,6 +16,8 @@
 package middleware
 
 import (
+	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -81,3 +83,62 @@ func TestLogger_DebugLevel(t *testing.T) {
 	assert.NotEmpty(t, recordedLogFields[0]["duration"])
 	assert.NotEmpty(t, recordedLogFields[0]["remoteAddr"])
 }
+
+func TestLogger_MaxBodyLogSize(t *testing.T) {
+	tests := []struct {
+		maxBodyLogSize int
+		request        string
+		response       string
+		wantRequest    string
+		wantResponse   string
+	}{
+		{maxBodyLogSize: 4, request: "req", response: "resp", wantRequest: "req", wantResponse: "resp"},
+		{maxBodyLogSize: 4, request: "request", response: "response", wantRequest: "requ" + truncatedSuffix, wantResponse: "resp" + truncatedSuffix},
+		{maxBodyLogSize: -1, request: "request", response: "response", wantRequest: "request", wantResponse: "response"},
+		{maxBodyLogSize: 0, request: strings.Repeat("a", 5000), response: "response", wantRequest: strings.Repeat("a", 4096) + truncatedSuffix, wantResponse: "response"},
+	}
+	for n, tt := range tests {
+		t.Run(fmt.Sprintf("case-%d", n+1), func(t *testing.T) {
+			var recordedLogFields []log.Fields
+			l := callback.New(log.Debug, func(level log.Level, fields log.Fields, msg string) {
+				recordedLogFields = append(recordedLogFields, fields)
+			})
+
+			var body string
+			r := httptest.NewRequest("POST", "/", strings.NewReader(tt.request))
+			w := httptest.NewRecorder()
+			h := (&Logger{Log: l, MaxBodyLogSize: tt.maxBodyLogSize}).Handle(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+				b, _ := io.ReadAll(r.Body)
+				body = string(b)
+				// Write in two parts to test the truncation across writes.
+				rw.Write([]byte(tt.response[:2]))
+				rw.Write([]byte(tt.response[2:]))
+			}))
+			h.ServeHTTP(w, r)
+
+			// Handler must receive and send complete bodies.
+			assert.Equal(t, tt.request, body)
+			assert.Equal(t, tt.response, w.Body.String())
+
+			require.Len(t, recordedLogFields, 1)
+			assert.Equal(t, tt.wantRequest, recordedLogFields[0]["request"])
+			assert.Equal(t, tt.wantResponse, recordedLogFields[0]["response"])
+		})
+	}
+}
+
+func TestLogger_Flush(t *testing.T) {
+	l := callback.New(log.Debug, func(level log.Level, fields log.Fields, msg string) {})
+
+	r := httptest.NewRequest("GET", "/", nil)
+	w := httptest.NewRecorder()
+	h := (&Logger{Log: l}).Handle(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		f, ok := rw.(http.Flusher)
+		require.True(t, ok)
+		f.Flush()
+		assert.Same(t, w, rw.(interface{ Unwrap() http.ResponseWriter }).Unwrap())
+	}))
+	h.ServeHTTP(w, r)
+
+	assert.True(t, w.Flushed)
+}
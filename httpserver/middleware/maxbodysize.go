@@ -0,0 +1,44 @@
+//  Copyright (C) 2021-2023 Chronicle Labs, Inc.
+//
+//  This program is free software: you can redistribute it and/or modify
+//  it under the terms of the GNU Affero General Public License as
+//  published by the Free Software Foundation, either version 3 of the
+//  License, or (at your option) any later version.
+//
+//  This program is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of
+//  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU Affero General Public License for more details.
+//
+//  You should have received a copy of the GNU Affero General Public License
+//  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package middleware
+
+import "net/http"
+
+// MaxBodySize limits the size of request bodies.
+//
+// Requests with the Content-Length header larger than the limit are rejected
+// with a 413 response. For other requests, reading the body past the limit
+// returns an *http.MaxBytesError and closes the connection.
+type MaxBodySize struct {
+	// Size is the maximum size of the request body in bytes. If zero,
+	// the size is not limited.
+	Size int64
+}
+
+// Handle implements the httpserver.Middleware interface.
+func (m *MaxBodySize) Handle(next http.Handler) http.Handler {
+	if m.Size <= 0 {
+		return next
+	}
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if r.ContentLength > m.Size {
+			http.Error(rw, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+			return
+		}
+		r.Body = http.MaxBytesReader(rw, r.Body, m.Size)
+		next.ServeHTTP(rw, r)
+	})
+}
@@ -0,0 +1,78 @@
+//  Copyright (C) 2021-2023 Chronicle Labs, Inc.
+//
+//  This program is free software: you can redistribute it and/or modify
+//  it under the terms of the GNU Affero General Public License as
+//  published by the Free Software Foundation, either version 3 of the
+//  License, or (at your option) any later version.
+//
+//  This program is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of
+//  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU Affero General Public License for more details.
+//
+//  You should have received a copy of the GNU Affero General Public License
+//  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMaxBodySize(t *testing.T) {
+	tests := []struct {
+		body          string
+		contentLength int64
+		wantStatus    int
+		wantErr       bool
+	}{
+		{body: "foo", contentLength: 3, wantStatus: http.StatusOK},
+		{body: "foobar", contentLength: 6, wantStatus: http.StatusRequestEntityTooLarge},
+		{body: "foobar", contentLength: -1, wantStatus: http.StatusOK, wantErr: true},
+		{body: "foo", contentLength: -1, wantStatus: http.StatusOK},
+	}
+	for n, tt := range tests {
+		t.Run(fmt.Sprintf("case-%d", n+1), func(t *testing.T) {
+			var (
+				called bool
+				err    error
+			)
+			h := (&MaxBodySize{Size: 5}).Handle(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+				called = true
+				_, err = io.ReadAll(r.Body)
+			}))
+			r := httptest.NewRequest("POST", "/", strings.NewReader(tt.body))
+			r.ContentLength = tt.contentLength
+			rw := httptest.NewRecorder()
+			h.ServeHTTP(rw, r)
+
+			assert.Equal(t, tt.wantStatus, rw.Code)
+			assert.Equal(t, tt.wantStatus == http.StatusOK, called)
+			if tt.wantErr {
+				var maxBytesErr *http.MaxBytesError
+				assert.True(t, errors.As(err, &maxBytesErr))
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestMaxBodySize_NoLimit(t *testing.T) {
+	var body []byte
+	h := (&MaxBodySize{}).Handle(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+	}))
+	rw := httptest.NewRecorder()
+	h.ServeHTTP(rw, httptest.NewRequest("POST", "/", strings.NewReader("foobar")))
+	assert.Equal(t, http.StatusOK, rw.Code)
+	assert.Equal(t, "foobar", string(body))
+}
@@ -0,0 +1,259 @@
+//  Copyright (C) 2021-2023 Chronicle Labs, Inc.
+//
+//  This program is free software: you can redistribute it and/or modify
+//  it under the terms of the GNU Affero General Public License as
+//  published by the Free Software Foundation, either version 3 of the
+//  License, or (at your option) any later version.
+//
+//  This program is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of
+//  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU Affero General Public License for more details.
+//
+//  You should have received a copy of the GNU Affero General Public License
+//  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package middleware
+
+import (
+	"container/list"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/chronicleprotocol/go-utils/log"
+	"github.com/chronicleprotocol/go-utils/log/null"
+)
+
+// bucketCleanupInterval is the interval at which unused token buckets are
+// removed from memory.
+const bucketCleanupInterval = time.Minute
+
+// defaultMaxKeys is the default maximum number of keys tracked by
+// the RateLimit middleware.
+const defaultMaxKeys = 10000
+
+// RateLimit is a middleware that limits the number of requests.
+//
+// Requests are limited per key using token buckets: each key may make up to
+// Burst requests at once, and the bucket is refilled at Rate requests per
+// second. Additionally, the total number of requests processed at the same
+// time may be limited by MaxInFlight.
+//
+// Requests exceeding the limits are rejected with a 429 response and
+// the Retry-After header.
+type RateLimit struct {
+	// Rate is the number of requests per second allowed for a single key.
+	// If zero, requests are not limited per key.
+	Rate float64
+
+	// Burst is the maximum number of requests a single key can make at once.
+	// If zero, Rate rounded up is used, but not less than 1.
+	Burst int
+
+	// Key is a function that returns the key used to limit requests, such as
+	// IPKey or HeaderKey. If nil, IPKey is used. If the function returns an
+	// empty string, the request is not limited per key.
+	Key func(r *http.Request) string
+
+	// MaxKeys is the maximum number of keys tracked at the same time. When
+	// the limit is reached, the least recently used key is forgotten, which
+	// resets its limit. If zero, 10000 keys are tracked.
+	MaxKeys int
+
+	// MaxInFlight is the maximum number of requests processed at the same
+	// time. If zero, the number of concurrent requests is not limited.
+	MaxInFlight int
+
+	// Exempt is a list of path prefixes that are not rate limited.
+	Exempt []string
+
+	// Log is a logger used to log rejected requests. If nil, the logs are
+	// discarded.
+	Log log.Logger
+
+	once        sync.Once
+	mu          sync.Mutex
+	key         func(r *http.Request) string
+	burst       int
+	maxKeys     int
+	log         log.Logger
+	buckets     map[string]*list.Element // elements of lru
+	lru         *list.List               // buckets, the most recently used first
+	lastCleanup time.Time
+	inFlight    atomic.Int64
+	exempt      []string
+	now         func() time.Time
+}
+
+// IPKey returns the IP address of the client, without the port number.
+//
+// The address is taken from the request's RemoteAddr field. If the server is
+// behind a proxy, use HeaderKey with a header set by the proxy instead.
+func IPKey(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// HeaderKey returns a function that uses the value of the given header as
+// a key. If the header is missing, the IP address of the client is used, so
+// that clients cannot bypass the limit by omitting the header.
+//
+// HeaderKey must only be used with a header set by a trusted proxy, which
+// overwrites any value sent by the client, such as X-Real-IP. Otherwise,
+// clients can bypass the limit by sending a different value with every
+// request.
+func HeaderKey(name string) func(r *http.Request) string {
+	return func(r *http.Request) string {
+		if v := r.Header.Get(name); v != "" {
+			return v
+		}
+		return IPKey(r)
+	}
+}
+
+// Handle implements the httpserver.Middleware interface.
+func (l *RateLimit) Handle(next http.Handler) http.Handler {
+	l.once.Do(l.init)
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if l.isExempt(r.URL.Path) {
+			next.ServeHTTP(rw, r)
+			return
+		}
+		if l.MaxInFlight > 0 {
+			if l.inFlight.Add(1) > int64(l.MaxInFlight) {
+				l.inFlight.Add(-1)
+				l.reject(rw, r, "", time.Second)
+				return
+			}
+			defer l.inFlight.Add(-1)
+		}
+		if l.Rate > 0 {
+			if key := l.key(r); key != "" {
+				if wait, ok := l.take(key); !ok {
+					l.reject(rw, r, key, wait)
+					return
+				}
+			}
+		}
+		next.ServeHTTP(rw, r)
+	})
+}
+
+func (l *RateLimit) init() {
+	l.key = l.Key
+	if l.key == nil {
+		l.key = IPKey
+	}
+	l.burst = l.Burst
+	if l.burst <= 0 {
+		l.burst = int(math.Max(1, math.Ceil(l.Rate)))
+	}
+	l.maxKeys = l.MaxKeys
+	if l.maxKeys <= 0 {
+		l.maxKeys = defaultMaxKeys
+	}
+	l.log = l.Log
+	if l.log == nil {
+		l.log = null.New()
+	}
+	if l.now == nil {
+		l.now = time.Now
+	}
+	l.buckets = make(map[string]*list.Element)
+	l.lru = list.New()
+	l.lastCleanup = l.now()
+	for _, p := range l.Exempt {
+		l.exempt = append(l.exempt, "/"+strings.Trim(p, "/"))
+	}
+}
+
+// take takes a token from the bucket for the given key. If there are no
+// tokens left, it returns false and the time after which the next token
+// will be available.
+func (l *RateLimit) take(key string) (time.Duration, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	if now.Sub(l.lastCleanup) >= bucketCleanupInterval {
+		l.cleanup(now)
+	}
+	var b *tokenBucket
+	if e, ok := l.buckets[key]; ok {
+		b = e.Value.(*tokenBucket)
+		l.lru.MoveToFront(e)
+	} else {
+		if len(l.buckets) >= l.maxKeys {
+			l.remove(l.lru.Back())
+		}
+		b = &tokenBucket{key: key, tokens: float64(l.burst), updatedAt: now}
+		l.buckets[key] = l.lru.PushFront(b)
+	}
+	b.refill(now, l.Rate, l.burst)
+	if b.tokens < 1 {
+		return time.Duration((1 - b.tokens) / l.Rate * float64(time.Second)), false
+	}
+	b.tokens--
+	return 0, true
+}
+
+// cleanup removes buckets that are full. Such buckets are equivalent to
+// new ones, so they can be removed without affecting the limits.
+func (l *RateLimit) cleanup(now time.Time) {
+	for _, e := range l.buckets {
+		b := e.Value.(*tokenBucket)
+		b.refill(now, l.Rate, l.burst)
+		if b.tokens >= float64(l.burst) {
+			l.remove(e)
+		}
+	}
+	l.lastCleanup = now
+}
+
+// remove removes the bucket stored in the given element.
+func (l *RateLimit) remove(e *list.Element) {
+	delete(l.buckets, e.Value.(*tokenBucket).key)
+	l.lru.Remove(e)
+}
+
+func (l *RateLimit) isExempt(path string) bool {
+	for _, p := range l.exempt {
+		if p == "/" || path == p || strings.HasPrefix(path, p+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func (l *RateLimit) reject(rw http.ResponseWriter, r *http.Request, key string, wait time.Duration) {
+	retryAfter := int(math.Max(1, math.Ceil(wait.Seconds())))
+	l.log.
+		WithFields(log.Fields{
+			"key":        key,
+			"remoteAddr": r.RemoteAddr,
+			"url":        r.URL.String(),
+			"retryAfter": retryAfter,
+		}).
+		Debug("Rate limit exceeded")
+	rw.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+	http.Error(rw, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+}
+
+type tokenBucket struct {
+	key       string
+	tokens    float64
+	updatedAt time.Time
+}
+
+func (b *tokenBucket) refill(now time.Time, rate float64, burst int) {
+	b.tokens = math.Min(float64(burst), b.tokens+now.Sub(b.updatedAt).Seconds()*rate)
+	b.updatedAt = now
+}
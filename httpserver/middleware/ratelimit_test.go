@@ -0,0 +1,214 @@
+//  Copyright (C) 2021-2023 Chronicle Labs, Inc.
+//
+//  This program is free software: you can redistribute it and/or modify
+//  it under the terms of the GNU Affero General Public License as
+//  published by the Free Software Foundation, either version 3 of the
+//  License, or (at your option) any later version.
+//
+//  This program is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of
+//  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU Affero General Public License for more details.
+//
+//  You should have received a copy of the GNU Affero General Public License
+//  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimit_TokenBucket(t *testing.T) {
+	now := time.Unix(0, 0)
+	l := &RateLimit{Rate: 2, Burst: 3, now: func() time.Time { return now }}
+	h := l.Handle(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {}))
+
+	serve := func(addr string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = addr
+		rw := httptest.NewRecorder()
+		h.ServeHTTP(rw, r)
+		return rw
+	}
+
+	// Burst.
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusOK, serve("1.1.1.1:1000").Code)
+	}
+	rw := serve("1.1.1.1:2000")
+	assert.Equal(t, http.StatusTooManyRequests, rw.Code)
+	assert.Equal(t, "1", rw.Header().Get("Retry-After"))
+
+	// Different IP has a separate bucket.
+	assert.Equal(t, http.StatusOK, serve("2.2.2.2:1000").Code)
+
+	// Refill.
+	now = now.Add(500 * time.Millisecond)
+	assert.Equal(t, http.StatusOK, serve("1.1.1.1:1000").Code)
+	assert.Equal(t, http.StatusTooManyRequests, serve("1.1.1.1:1000").Code)
+
+	// Cleanup of full buckets.
+	now = now.Add(bucketCleanupInterval)
+	assert.Equal(t, http.StatusOK, serve("3.3.3.3:1000").Code)
+	assert.Len(t, l.buckets, 1)
+}
+
+func TestRateLimit_RetryAfter(t *testing.T) {
+	now := time.Unix(0, 0)
+	l := &RateLimit{Rate: 0.1, now: func() time.Time { return now }}
+	h := l.Handle(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {}))
+
+	rw := httptest.NewRecorder()
+	h.ServeHTTP(rw, httptest.NewRequest("GET", "/", nil))
+	assert.Equal(t, http.StatusOK, rw.Code)
+
+	now = now.Add(time.Second)
+	rw = httptest.NewRecorder()
+	h.ServeHTTP(rw, httptest.NewRequest("GET", "/", nil))
+	assert.Equal(t, http.StatusTooManyRequests, rw.Code)
+	assert.Equal(t, "9", rw.Header().Get("Retry-After"))
+}
+
+func TestRateLimit_MaxKeys(t *testing.T) {
+	now := time.Unix(0, 0)
+	l := &RateLimit{Rate: 1, MaxKeys: 2, now: func() time.Time { return now }}
+	h := l.Handle(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {}))
+
+	serve := func(addr string) int {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = addr
+		rw := httptest.NewRecorder()
+		h.ServeHTTP(rw, r)
+		return rw.Code
+	}
+
+	assert.Equal(t, http.StatusOK, serve("1.1.1.1:1000"))
+	assert.Equal(t, http.StatusOK, serve("2.2.2.2:1000"))
+	assert.Equal(t, http.StatusTooManyRequests, serve("1.1.1.1:1000"))
+
+	// The least recently used key (2.2.2.2) is forgotten.
+	assert.Equal(t, http.StatusOK, serve("3.3.3.3:1000"))
+	assert.Len(t, l.buckets, 2)
+	assert.Equal(t, http.StatusTooManyRequests, serve("1.1.1.1:1000"))
+	assert.Equal(t, http.StatusOK, serve("2.2.2.2:1000"))
+	assert.Len(t, l.buckets, 2)
+}
+
+func TestRateLimit_Defaults(t *testing.T) {
+	l := &RateLimit{Rate: 2.5}
+	h := l.Handle(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {}))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+
+	// Defaults must not be written to the exported fields.
+	assert.Nil(t, l.Key)
+	assert.Zero(t, l.Burst)
+	assert.Zero(t, l.MaxKeys)
+	assert.Nil(t, l.Log)
+	assert.Equal(t, 3, l.burst)
+	assert.Equal(t, defaultMaxKeys, l.maxKeys)
+}
+
+func TestRateLimit_Key(t *testing.T) {
+	tests := []struct {
+		key        func(r *http.Request) string
+		header     string
+		remoteAddr string
+		want       string
+	}{
+		{key: IPKey, remoteAddr: "1.1.1.1:1000", want: "1.1.1.1"},
+		{key: IPKey, remoteAddr: "[::1]:1000", want: "::1"},
+		{key: IPKey, remoteAddr: "@", want: "@"},
+		{key: HeaderKey("X-API-Key"), header: "foo", remoteAddr: "1.1.1.1:1000", want: "foo"},
+		{key: HeaderKey("X-API-Key"), remoteAddr: "1.1.1.1:1000", want: "1.1.1.1"},
+	}
+	for n, tt := range tests {
+		t.Run(fmt.Sprintf("case-%d", n+1), func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = tt.remoteAddr
+			if tt.header != "" {
+				r.Header.Set("X-API-Key", tt.header)
+			}
+			assert.Equal(t, tt.want, tt.key(r))
+		})
+	}
+}
+
+func TestRateLimit_EmptyKey(t *testing.T) {
+	l := &RateLimit{Rate: 1, Key: func(r *http.Request) string { return "" }}
+	h := l.Handle(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {}))
+	for i := 0; i < 5; i++ {
+		rw := httptest.NewRecorder()
+		h.ServeHTTP(rw, httptest.NewRequest("GET", "/", nil))
+		assert.Equal(t, http.StatusOK, rw.Code)
+	}
+}
+
+func TestRateLimit_Exempt(t *testing.T) {
+	l := &RateLimit{Rate: 1, Exempt: []string{"/metrics", "status/"}}
+	h := l.Handle(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {}))
+
+	tests := []struct {
+		path string
+		want int
+	}{
+		{path: "/metrics", want: http.StatusOK},
+		{path: "/metrics/foo", want: http.StatusOK},
+		{path: "/status", want: http.StatusOK},
+		{path: "/", want: http.StatusOK},
+		{path: "/metricsfoo", want: http.StatusTooManyRequests},
+		{path: "/foo", want: http.StatusTooManyRequests},
+	}
+	for n, tt := range tests {
+		t.Run(fmt.Sprintf("case-%d", n+1), func(t *testing.T) {
+			rw := httptest.NewRecorder()
+			h.ServeHTTP(rw, httptest.NewRequest("GET", tt.path, nil))
+			assert.Equal(t, tt.want, rw.Code)
+		})
+	}
+}
+
+func TestRateLimit_MaxInFlight(t *testing.T) {
+	var (
+		wg      sync.WaitGroup
+		started = make(chan struct{})
+		release = make(chan struct{})
+	)
+	l := &RateLimit{MaxInFlight: 2}
+	h := l.Handle(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-release
+	}))
+
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rw := httptest.NewRecorder()
+			h.ServeHTTP(rw, httptest.NewRequest("GET", "/", nil))
+			assert.Equal(t, http.StatusOK, rw.Code)
+		}()
+		<-started
+	}
+
+	rw := httptest.NewRecorder()
+	h.ServeHTTP(rw, httptest.NewRequest("GET", "/", nil))
+	assert.Equal(t, http.StatusTooManyRequests, rw.Code)
+	assert.Equal(t, "1", rw.Header().Get("Retry-After"))
+
+	close(release)
+	wg.Wait()
+
+	rw = httptest.NewRecorder()
+	go func() { <-started }()
+	h.ServeHTTP(rw, httptest.NewRequest("GET", "/", nil))
+	require.Equal(t, http.StatusOK, rw.Code)
+}
@@ -21,6 +21,9 @@ import (
 	"net/http"
 )
 
+// truncatedSuffix is appended to logged bodies that were truncated.
+const truncatedSuffix = "...(truncated)"
+
 // recorder implements the http.ResponseWriter interface. It passes all calls
 // to the underlying ResponseWriter and records a copies of values for a later
 // inspection.
@@ -29,14 +32,16 @@ type recorder struct {
 	code    int                 // code is the HTTP status code
 	headers http.Header         // headers is the list of HTTP headers
 	body    *bytes.Buffer       // body is the HTTP response body
+	limit   int                 // limit is the max body size to record, negative means no limit
 }
 
-func newRecorder(rw http.ResponseWriter) *recorder {
+func newRecorder(rw http.ResponseWriter, limit int) *recorder {
 	return &recorder{
 		rw:      rw,
 		headers: make(http.Header),
 		body:    new(bytes.Buffer),
 		code:    http.StatusOK,
+		limit:   limit,
 	}
 }
 
@@ -45,7 +50,13 @@ func (r *recorder) Header() http.Header {
 }
 
 func (r *recorder) Write(buf []byte) (int, error) {
-	r.body.Write(buf)
+	// One byte more than the limit is recorded to detect truncation.
+	switch n := r.limit + 1 - r.body.Len(); {
+	case r.limit < 0 || n >= len(buf):
+		r.body.Write(buf)
+	case n > 0:
+		r.body.Write(buf[:n])
+	}
 	return r.rw.Write(buf)
 }
 
@@ -54,13 +65,46 @@ func (r *recorder) WriteHeader(code int) {
 	r.rw.WriteHeader(code)
 }
 
-func readRequest(r *http.Request) []byte {
-	b, _ := io.ReadAll(r.Body)
-	r.Body = io.NopCloser(bytes.NewReader(b))
-	return b
+// Flush implements the http.Flusher interface. It does nothing if
+// the underlying ResponseWriter does not support flushing.
+func (r *recorder) Flush() {
+	_ = http.NewResponseController(r.rw).Flush()
+}
+
+// Unwrap returns the underlying ResponseWriter. It is used by
+// http.ResponseController.
+func (r *recorder) Unwrap() http.ResponseWriter {
+	return r.rw
 }
 
-func readResponse(r *recorder) []byte {
+// readRequest reads up to limit bytes of the request body and restores
+// the body, so it can be read again. If limit is negative, the whole body
+// is read.
+func readRequest(r *http.Request, limit int) string {
+	if limit < 0 {
+		b, _ := io.ReadAll(r.Body)
+		r.Body = io.NopCloser(bytes.NewReader(b))
+		return string(b)
+	}
+	b, _ := io.ReadAll(io.LimitReader(r.Body, int64(limit)+1))
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{
+		Reader: io.MultiReader(bytes.NewReader(b), r.Body),
+		Closer: r.Body,
+	}
+	return truncateBody(b, limit)
+}
+
+func readResponse(r *recorder) string {
 	b, _ := io.ReadAll(r.body)
-	return b
+	return truncateBody(b, r.limit)
+}
+
+func truncateBody(b []byte, limit int) string {
+	if limit < 0 || len(b) <= limit {
+		return string(b)
+	}
+	return string(b[:limit]) + truncatedSuffix
 }
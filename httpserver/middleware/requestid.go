@@ -0,0 +1,114 @@
+//  Copyright (C) 2021-2023 Chronicle Labs, Inc.
+//
+//  This program is free software: you can redistribute it and/or modify
+//  it under the terms of the GNU Affero General Public License as
+//  published by the Free Software Foundation, either version 3 of the
+//  License, or (at your option) any later version.
+//
+//  This program is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of
+//  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU Affero General Public License for more details.
+//
+//  You should have received a copy of the GNU Affero General Public License
+//  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package middleware
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/chronicleprotocol/go-utils/log"
+	"github.com/chronicleprotocol/go-utils/log/null"
+	"github.com/chronicleprotocol/go-utils/requestid"
+)
+
+const (
+	// RequestIDHeader is the name of the header that contains the request ID.
+	RequestIDHeader = requestid.Header
+
+	// TraceparentHeader is the name of the W3C Trace Context header.
+	TraceparentHeader = requestid.TraceparentHeader
+)
+
+// Trace is a W3C Trace Context of the request.
+type Trace = requestid.Trace
+
+// RequestID is a middleware that assigns a request ID and a W3C trace
+// context to each request.
+//
+// The request ID is taken from the X-Request-ID header or generated if
+// the header is missing or invalid. It is added to the response headers.
+// The trace context is taken from the traceparent header or a new trace
+// is started. In both cases, a new span ID is generated for the request.
+//
+// The request ID, the trace and a logger with the "requestID", "traceID"
+// and "spanID" fields are stored in the request context, and can be
+// retrieved using the RequestIDFromContext, TraceFromContext and
+// LoggerFromContext functions. The Logger middleware adds these fields
+// automatically if it is called after this middleware.
+type RequestID struct {
+	// Log is a logger used to create the logger stored in the request
+	// context. If nil, the logs are discarded.
+	Log log.Logger
+}
+
+// Handle implements the httpserver.Middleware interface.
+func (m *RequestID) Handle(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if !requestid.Valid(id) {
+			id = requestid.New()
+		}
+		trace, ok := requestid.ParseTraceparent(r.Header.Get(TraceparentHeader))
+		if ok {
+			trace.SpanID = requestid.NewSpanID()
+		} else {
+			trace = requestid.NewTrace()
+		}
+		rw.Header().Set(RequestIDHeader, id)
+
+		logger := m.Log
+		if logger == nil {
+			logger = null.New()
+		}
+		logger = logger.WithFields(log.Fields{
+			"requestID": id,
+			"traceID":   trace.TraceID,
+			"spanID":    trace.SpanID,
+		})
+
+		ctx := requestid.WithRequestID(r.Context(), id)
+		ctx = requestid.WithTrace(ctx, trace)
+		ctx = context.WithValue(ctx, loggerCtxKey{}, logger)
+		next.ServeHTTP(rw, r.WithContext(ctx))
+	})
+}
+
+type loggerCtxKey struct{}
+
+// WithRequestID returns a copy of the context with the request ID.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return requestid.WithRequestID(ctx, id)
+}
+
+// RequestIDFromContext returns the request ID from the context or an empty
+// string if there is no request ID.
+func RequestIDFromContext(ctx context.Context) string {
+	return requestid.FromContext(ctx)
+}
+
+// TraceFromContext returns the trace context from the context.
+func TraceFromContext(ctx context.Context) (Trace, bool) {
+	return requestid.TraceFromContext(ctx)
+}
+
+// LoggerFromContext returns the logger created by the RequestID middleware.
+// If there is no logger in the context, the fallback logger is returned.
+func LoggerFromContext(ctx context.Context, fallback log.Logger) log.Logger {
+	if l, ok := ctx.Value(loggerCtxKey{}).(log.Logger); ok {
+		return l
+	}
+	return fallback
+}
@@ -0,0 +1,180 @@
+//  Copyright (C) 2021-2023 Chronicle Labs, Inc.
+//
+//  This program is free software: you can redistribute it and/or modify
+//  it under the terms of the GNU Affero General Public License as
+//  published by the Free Software Foundation, either version 3 of the
+//  License, or (at your option) any later version.
+//
+//  This program is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of
+//  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU Affero General Public License for more details.
+//
+//  You should have received a copy of the GNU Affero General Public License
+//  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/chronicleprotocol/go-utils/log"
+	"github.com/chronicleprotocol/go-utils/log/callback"
+)
+
+func TestRequestID(t *testing.T) {
+	tests := []struct {
+		requestID        string
+		traceparent      string
+		wantRequestID    string
+		wantTraceID      string
+		wantParentID     string
+		wantFlags        byte
+		wantNewTrace     bool
+		wantNewRequestID bool
+	}{
+		// Both headers present.
+		{
+			requestID:     "foo",
+			traceparent:   "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+			wantRequestID: "foo",
+			wantTraceID:   "4bf92f3577b34da6a3ce929d0e0e4736",
+			wantParentID:  "00f067aa0ba902b7",
+			wantFlags:     1,
+		},
+		// Missing headers.
+		{
+			wantNewRequestID: true,
+			wantNewTrace:     true,
+		},
+		// Invalid request ID.
+		{
+			requestID:        "foo bar",
+			wantNewRequestID: true,
+			wantNewTrace:     true,
+		},
+		// Higher version with additional fields.
+		{
+			requestID:     "foo",
+			traceparent:   "01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00-extra",
+			wantRequestID: "foo",
+			wantTraceID:   "4bf92f3577b34da6a3ce929d0e0e4736",
+			wantParentID:  "00f067aa0ba902b7",
+		},
+		// Version 00 with additional fields.
+		{
+			traceparent:      "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00-extra",
+			wantNewRequestID: true,
+			wantNewTrace:     true,
+		},
+		// All-zero trace ID.
+		{
+			traceparent:      "00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+			wantNewRequestID: true,
+			wantNewTrace:     true,
+		},
+		// Uppercase hex.
+		{
+			traceparent:      "00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+			wantNewRequestID: true,
+			wantNewTrace:     true,
+		},
+		// Invalid version.
+		{
+			traceparent:      "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+			wantNewRequestID: true,
+			wantNewTrace:     true,
+		},
+	}
+	for n, tt := range tests {
+		t.Run(fmt.Sprintf("case-%d", n+1), func(t *testing.T) {
+			var (
+				requestID string
+				trace     Trace
+				traceOK   bool
+			)
+			h := (&RequestID{}).Handle(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+				requestID = RequestIDFromContext(r.Context())
+				trace, traceOK = TraceFromContext(r.Context())
+			}))
+			r := httptest.NewRequest("GET", "/", nil)
+			if tt.requestID != "" {
+				r.Header.Set(RequestIDHeader, tt.requestID)
+			}
+			if tt.traceparent != "" {
+				r.Header.Set(TraceparentHeader, tt.traceparent)
+			}
+			rw := httptest.NewRecorder()
+			h.ServeHTTP(rw, r)
+
+			require.True(t, traceOK)
+			assert.Equal(t, requestID, rw.Header().Get(RequestIDHeader))
+			if tt.wantNewRequestID {
+				assert.Len(t, requestID, 32)
+				assert.NotEqual(t, tt.requestID, requestID)
+			} else {
+				assert.Equal(t, tt.wantRequestID, requestID)
+			}
+			if tt.wantNewTrace {
+				assert.Len(t, trace.TraceID, 32)
+				assert.Empty(t, trace.ParentID)
+			} else {
+				assert.Equal(t, tt.wantTraceID, trace.TraceID)
+				assert.Equal(t, tt.wantParentID, trace.ParentID)
+				assert.Equal(t, tt.wantFlags, trace.Flags)
+			}
+			assert.Len(t, trace.SpanID, 16)
+			assert.NotEqual(t, trace.ParentID, trace.SpanID)
+		})
+	}
+}
+
+func TestRequestID_Traceparent(t *testing.T) {
+	trace := Trace{
+		TraceID:  "4bf92f3577b34da6a3ce929d0e0e4736",
+		ParentID: "00f067aa0ba902b7",
+		SpanID:   "b7ad6b7169203331",
+		Flags:    1,
+	}
+	assert.Equal(t, "00-4bf92f3577b34da6a3ce929d0e0e4736-b7ad6b7169203331-01", trace.Traceparent())
+}
+
+func TestRequestID_Logger(t *testing.T) {
+	var recordedLogFields []log.Fields
+	l := callback.New(log.Info, func(level log.Level, fields log.Fields, msg string) {
+		recordedLogFields = append(recordedLogFields, fields)
+	})
+
+	var trace Trace
+	h := (&Logger{Log: l}).Handle(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		trace, _ = TraceFromContext(r.Context())
+		LoggerFromContext(r.Context(), nil).Info("handler")
+	}))
+	h = (&RequestID{Log: l}).Handle(h)
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set(RequestIDHeader, "foo")
+	h.ServeHTTP(httptest.NewRecorder(), r)
+
+	// Handler log.
+	require.Len(t, recordedLogFields, 2)
+	assert.Equal(t, "foo", recordedLogFields[0]["requestID"])
+	assert.Equal(t, trace.TraceID, recordedLogFields[0]["traceID"])
+	assert.Equal(t, trace.SpanID, recordedLogFields[0]["spanID"])
+
+	// Logger middleware log.
+	assert.Equal(t, "foo", recordedLogFields[1]["requestID"])
+	assert.Equal(t, trace.TraceID, recordedLogFields[1]["traceID"])
+}
+
+func TestLoggerFromContext_Fallback(t *testing.T) {
+	l := callback.New(log.Info, func(level log.Level, fields log.Fields, msg string) {})
+	r := httptest.NewRequest("GET", "/", nil)
+	assert.Equal(t, l, LoggerFromContext(r.Context(), l))
+}
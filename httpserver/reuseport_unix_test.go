@@ -0,0 +1,66 @@
+//  Copyright (C) 2021-2023 Chronicle Labs, Inc.
+//
+//  This program is free software: you can redistribute it and/or modify
+//  it under the terms of the GNU Affero General Public License as
+//  published by the Free Software Foundation, either version 3 of the
+//  License, or (at your option) any later version.
+//
+//  This program is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of
+//  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU Affero General Public License for more details.
+//
+//  You should have received a copy of the GNU Affero General Public License
+//  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd
+
+package httpserver
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_ReusePort(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	srv1 := New(&http.Server{Addr: "localhost:0", Handler: textHandler("srv1")}, WithReusePort())
+	require.NoError(t, srv1.Start(ctx))
+	<-srv1.Ready()
+	addr := srv1.Addr().String()
+
+	// The second server binds the same address while the first one is
+	// still running.
+	srv2 := New(&http.Server{Addr: addr, Handler: textHandler("srv2")}, WithReusePort())
+	startServer(t, srv2)
+	<-srv2.Ready()
+	assert.Equal(t, addr, srv2.Addr().String())
+
+	// The socket of the first server is closed asynchronously, so
+	// a connection may still be routed to it for a short time.
+	cancel()
+	<-srv1.Wait()
+	c := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+	assert.Eventually(t, func() bool {
+		res, err := c.Get("http://" + addr)
+		if err != nil {
+			return false
+		}
+		defer res.Body.Close()
+		body, err := io.ReadAll(res.Body)
+		return err == nil && string(body) == "srv2"
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestServer_WithoutReusePort(t *testing.T) {
+	srv1 := New(&http.Server{Addr: "localhost:0", Handler: textHandler("srv1")})
+	startServer(t, srv1)
+	srv2 := New(&http.Server{Addr: srv1.Addr().String(), Handler: textHandler("srv2")})
+	require.Error(t, srv2.Start(context.Background()))
+}
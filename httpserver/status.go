@@ -0,0 +1,57 @@
+//  Copyright (C) 2021-2023 Chronicle Labs, Inc.
+//
+//  This program is free software: you can redistribute it and/or modify
+//  it under the terms of the GNU Affero General Public License as
+//  published by the Free Software Foundation, either version 3 of the
+//  License, or (at your option) any later version.
+//
+//  This program is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of
+//  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU Affero General Public License for more details.
+//
+//  You should have received a copy of the GNU Affero General Public License
+//  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package httpserver
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/chronicleprotocol/go-utils/supervisor"
+)
+
+// StatusProvider provides the status served by the StatusHandler. It is
+// implemented by supervisor.Supervisor.
+type StatusProvider interface {
+	Status() supervisor.Status
+}
+
+// StatusHandler returns a handler that responds with the status returned
+// by the provider, encoded as JSON.
+//
+// The response code is 200 if the status is ready and 503 otherwise,
+// so the handler may be used with Kubernetes' readiness probe, while
+// middleware.HealthCheck is used for the liveness probe:
+//
+//	srv.SetHandler("/status", httpserver.StatusHandler(sup))
+func StatusHandler(p StatusProvider) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			rw.Header().Set("Allow", "GET, HEAD")
+			rw.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		status := p.Status()
+		rw.Header().Set("Content-Type", "application/json")
+		rw.Header().Set("Cache-Control", "no-store")
+		if !status.Ready {
+			rw.WriteHeader(http.StatusServiceUnavailable)
+		}
+		if r.Method == http.MethodHead {
+			return
+		}
+		_ = json.NewEncoder(rw).Encode(status)
+	})
+}
@@ -0,0 +1,103 @@
+//  Copyright (C) 2021-2023 Chronicle Labs, Inc.
+//
+//  This program is free software: you can redistribute it and/or modify
+//  it under the terms of the GNU Affero General Public License as
+//  published by the Free Software Foundation, either version 3 of the
+//  License, or (at your option) any later version.
+//
+//  This program is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of
+//  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU Affero General Public License for more details.
+//
+//  You should have received a copy of the GNU Affero General Public License
+//  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package httpserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/chronicleprotocol/go-utils/supervisor"
+)
+
+func TestStatusHandler(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sup := supervisor.New(nil)
+	sup.Watch(&NullServer{})
+	h := StatusHandler(sup)
+
+	// Not started yet.
+	rw := httptest.NewRecorder()
+	h.ServeHTTP(rw, httptest.NewRequest("GET", "/status", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rw.Code)
+
+	// Running.
+	require.NoError(t, sup.Start(ctx))
+	rw = httptest.NewRecorder()
+	h.ServeHTTP(rw, httptest.NewRequest("GET", "/status", nil))
+	assert.Equal(t, http.StatusOK, rw.Code)
+	assert.Equal(t, "application/json", rw.Header().Get("Content-Type"))
+
+	var status supervisor.Status
+	require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &status))
+	assert.True(t, status.Ready)
+	require.Len(t, status.Services, 2)
+	assert.Equal(t, "NullServer", status.Services[0].Name)
+	assert.Equal(t, supervisor.StateRunning, status.Services[0].State)
+
+	// Invalid method.
+	rw = httptest.NewRecorder()
+	h.ServeHTTP(rw, httptest.NewRequest("POST", "/status", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rw.Code)
+
+	// Shutting down.
+	cancel()
+	<-sup.Wait()
+	rw = httptest.NewRecorder()
+	h.ServeHTTP(rw, httptest.NewRequest("GET", "/status", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rw.Code)
+}
+
+type statusProvider supervisor.Status
+
+func (p statusProvider) Status() supervisor.Status {
+	return supervisor.Status(p)
+}
+
+func TestStatusHandler_Provider(t *testing.T) {
+	tests := []struct {
+		provider statusProvider
+		wantCode int
+		wantBody string
+	}{
+		{
+			provider: statusProvider{Ready: true, Services: []supervisor.ServiceStatus{}},
+			wantCode: http.StatusOK,
+			wantBody: `{"ready":true,"services":[]}`,
+		},
+		{
+			provider: statusProvider{Ready: false, Services: []supervisor.ServiceStatus{}},
+			wantCode: http.StatusServiceUnavailable,
+			wantBody: `{"ready":false,"services":[]}`,
+		},
+	}
+	for n, tt := range tests {
+		t.Run(fmt.Sprintf("case-%d", n+1), func(t *testing.T) {
+			rw := httptest.NewRecorder()
+			StatusHandler(tt.provider).ServeHTTP(rw, httptest.NewRequest("GET", "/status", nil))
+			assert.Equal(t, tt.wantCode, rw.Code)
+			assert.JSONEq(t, tt.wantBody, rw.Body.String())
+		})
+	}
+}
@@ -0,0 +1,188 @@
+//  Copyright (C) 2021-2023 Chronicle Labs, Inc.
+//
+//  This program is free software: you can redistribute it and/or modify
+//  it under the terms of the GNU Affero General Public License as
+//  published by the Free Software Foundation, either version 3 of the
+//  License, or (at your option) any later version.
+//
+//  This program is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of
+//  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU Affero General Public License for more details.
+//
+//  You should have received a copy of the GNU Affero General Public License
+//  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package httpserver
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// tlsCheckInterval is the minimum interval between checks whether
+// the certificate files have changed.
+var tlsCheckInterval = time.Second
+
+// TLSConfig is a configuration of TLS used by the HTTPServer.
+type TLSConfig struct {
+	// CertFile and KeyFile are paths to the PEM encoded certificate and
+	// private key. The certificate file may contain intermediate
+	// certificates. Files are reloaded automatically when they change.
+	CertFile string
+	KeyFile  string
+
+	// ClientCAFile is an optional path to the PEM encoded bundle of CA
+	// certificates used to verify client certificates. If set, mutual TLS
+	// is enabled. The file is reloaded automatically when it changes.
+	ClientCAFile string
+
+	// ClientAuth is the policy for client certificate verification. If
+	// ClientCAFile is set and ClientAuth is not, it defaults to
+	// tls.RequireAndVerifyClientCert.
+	ClientAuth tls.ClientAuthType
+
+	// MinVersion is the minimum TLS version. The default is TLS 1.2.
+	MinVersion uint16
+}
+
+// WithTLS enables TLS using the certificate and key files.
+func WithTLS(cfg TLSConfig) Option {
+	return func(s *HTTPServer) {
+		s.tls = &cfg
+	}
+}
+
+// fileStat is used to detect changes in files.
+type fileStat struct {
+	modTime time.Time
+	size    int64
+}
+
+// certReloader provides a TLS configuration with certificates that are
+// reloaded when the files they were loaded from change.
+type certReloader struct {
+	mu        sync.Mutex
+	cfg       TLSConfig
+	tlsConfig *tls.Config
+	stats     map[string]fileStat
+	lastCheck time.Time
+	onError   func(err error)
+}
+
+func newCertReloader(cfg TLSConfig, onError func(err error)) (*certReloader, error) {
+	if cfg.CertFile == "" || cfg.KeyFile == "" {
+		return nil, errors.New("certificate and key files must be provided")
+	}
+	if cfg.MinVersion == 0 {
+		cfg.MinVersion = tls.VersionTLS12
+	}
+	if cfg.ClientCAFile != "" && cfg.ClientAuth == tls.NoClientCert {
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	c := &certReloader{cfg: cfg, onError: onError}
+	if err := c.load(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// config returns a TLS configuration that uses the most recent
+// certificates for every new connection.
+func (c *certReloader) config() *tls.Config {
+	return &tls.Config{
+		MinVersion: c.cfg.MinVersion,
+		NextProtos: []string{"h2", "http/1.1"},
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			return c.current(), nil
+		},
+	}
+}
+
+// current returns the current TLS configuration, reloading certificates
+// if the files have changed. If reloading fails, the previous
+// configuration is used.
+func (c *certReloader) current() *tls.Config {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if time.Since(c.lastCheck) >= tlsCheckInterval {
+		c.lastCheck = time.Now()
+		if c.changed() {
+			if err := c.load(); err != nil && c.onError != nil {
+				c.onError(err)
+			}
+		}
+	}
+	return c.tlsConfig
+}
+
+// changed returns true if any of the files changed since the last load.
+func (c *certReloader) changed() bool {
+	for path, stat := range c.stats {
+		fi, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		if !fi.ModTime().Equal(stat.modTime) || fi.Size() != stat.size {
+			return true
+		}
+	}
+	return false
+}
+
+// load loads the certificates and updates the TLS configuration.
+func (c *certReloader) load() error {
+	paths := []string{c.cfg.CertFile, c.cfg.KeyFile}
+	if c.cfg.ClientCAFile != "" {
+		paths = append(paths, c.cfg.ClientCAFile)
+	}
+	// Stats are read before the files, so if a file changes while it is
+	// being loaded, it will be loaded again during the next check.
+	stats := make(map[string]fileStat, len(paths))
+	var statErr error
+	for _, path := range paths {
+		fi, err := os.Stat(path)
+		if err != nil {
+			// A missing file is recorded with zero stats, so it is loaded
+			// again when it appears.
+			stats[path] = fileStat{}
+			statErr = errors.Join(statErr, err)
+			continue
+		}
+		stats[path] = fileStat{modTime: fi.ModTime(), size: fi.Size()}
+	}
+	// Stats are recorded even if loading fails, so a failed reload is
+	// reported once and retried only when the files change again.
+	c.stats = stats
+	if statErr != nil {
+		return statErr
+	}
+	cert, err := tls.LoadX509KeyPair(c.cfg.CertFile, c.cfg.KeyFile)
+	if err != nil {
+		return fmt.Errorf("unable to load certificate: %w", err)
+	}
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   c.cfg.MinVersion,
+		NextProtos:   []string{"h2", "http/1.1"},
+		ClientAuth:   c.cfg.ClientAuth,
+	}
+	if c.cfg.ClientCAFile != "" {
+		pem, err := os.ReadFile(c.cfg.ClientCAFile)
+		if err != nil {
+			return fmt.Errorf("unable to load client CA bundle: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("no valid certificates found in client CA bundle %s", c.cfg.ClientCAFile)
+		}
+		tlsConfig.ClientCAs = pool
+	}
+	c.tlsConfig = tlsConfig
+	return nil
+}
@@ -0,0 +1,229 @@
+//  Copyright (C) 2021-2023 Chronicle Labs, Inc.
+//
+//  This program is free software: you can redistribute it and/or modify
+//  it under the terms of the GNU Affero General Public License as
+//  published by the Free Software Foundation, either version 3 of the
+//  License, or (at your option) any later version.
+//
+//  This program is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of
+//  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU Affero General Public License for more details.
+//
+//  You should have received a copy of the GNU Affero General Public License
+//  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package httpserver
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T) *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tpl, tpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return &testCA{
+		cert: cert,
+		key:  key,
+		pem:  pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+	}
+}
+
+// issue returns PEM encoded certificate and key signed by the CA.
+func (ca *testCA) issue(t *testing.T, serial int64, usage x509.ExtKeyUsage) (certPEM, keyPEM []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tpl, ca.cert, &key.PublicKey, ca.key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+func startTLSServer(t *testing.T, cfg TLSConfig) *HTTPServer {
+	srv := New(&http.Server{
+		Addr: "localhost:0",
+		Handler: http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			_, _ = rw.Write([]byte("ok"))
+		}),
+	}, WithTLS(cfg))
+	ctx, cancel := context.WithCancel(context.Background())
+	require.NoError(t, srv.Start(ctx))
+	t.Cleanup(func() {
+		cancel()
+		<-srv.Wait()
+	})
+	return srv
+}
+
+func tlsClient(ca *testCA, cert *tls.Certificate) *http.Client {
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+	cfg := &tls.Config{RootCAs: pool, ServerName: "localhost", MinVersion: tls.VersionTLS12}
+	if cert != nil {
+		cfg.Certificates = []tls.Certificate{*cert}
+	}
+	return &http.Client{Transport: &http.Transport{TLSClientConfig: cfg}}
+}
+
+// peerSerial sends a request and returns the serial number of the server
+// certificate.
+func peerSerial(t *testing.T, c *http.Client, srv *HTTPServer) int64 {
+	res, err := c.Get("https://" + srv.Addr().String())
+	require.NoError(t, err)
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "ok", string(body))
+	return res.TLS.PeerCertificates[0].SerialNumber.Int64()
+}
+
+func TestServer_TLS(t *testing.T) {
+	defer func(d time.Duration) { tlsCheckInterval = d }(tlsCheckInterval)
+	tlsCheckInterval = 0
+
+	dir := t.TempDir()
+	ca := newTestCA(t)
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	certPEM, keyPEM := ca.issue(t, 2, x509.ExtKeyUsageServerAuth)
+	require.NoError(t, os.WriteFile(certFile, certPEM, 0o600))
+	require.NoError(t, os.WriteFile(keyFile, keyPEM, 0o600))
+
+	srv := startTLSServer(t, TLSConfig{CertFile: certFile, KeyFile: keyFile})
+	c := tlsClient(ca, nil)
+	assert.Equal(t, int64(2), peerSerial(t, c, srv))
+
+	// Rotate the certificate.
+	certPEM, keyPEM = ca.issue(t, 3, x509.ExtKeyUsageServerAuth)
+	require.NoError(t, os.WriteFile(certFile, certPEM, 0o600))
+	require.NoError(t, os.WriteFile(keyFile, keyPEM, 0o600))
+	future := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(certFile, future, future))
+	require.NoError(t, os.Chtimes(keyFile, future, future))
+	c.CloseIdleConnections()
+	assert.Equal(t, int64(3), peerSerial(t, c, srv))
+
+	// Invalid files are ignored and the previous certificate is used.
+	require.NoError(t, os.WriteFile(certFile, []byte("invalid"), 0o600))
+	c.CloseIdleConnections()
+	assert.Equal(t, int64(3), peerSerial(t, c, srv))
+}
+
+func TestCertReloader_ReloadError(t *testing.T) {
+	defer func(d time.Duration) { tlsCheckInterval = d }(tlsCheckInterval)
+	tlsCheckInterval = 0
+
+	dir := t.TempDir()
+	ca := newTestCA(t)
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	certPEM, keyPEM := ca.issue(t, 2, x509.ExtKeyUsageServerAuth)
+	require.NoError(t, os.WriteFile(certFile, certPEM, 0o600))
+	require.NoError(t, os.WriteFile(keyFile, keyPEM, 0o600))
+
+	var errs int
+	c, err := newCertReloader(TLSConfig{CertFile: certFile, KeyFile: keyFile}, func(error) { errs++ })
+	require.NoError(t, err)
+	prev := c.current()
+
+	// A failed reload is reported only once.
+	require.NoError(t, os.WriteFile(certFile, []byte("invalid"), 0o600))
+	for i := 0; i < 3; i++ {
+		assert.Same(t, prev, c.current())
+	}
+	assert.Equal(t, 1, errs)
+
+	// The reload is retried when the files change again.
+	require.NoError(t, os.WriteFile(certFile, certPEM, 0o600))
+	future := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(certFile, future, future))
+	assert.NotSame(t, prev, c.current())
+	assert.Equal(t, 1, errs)
+}
+
+func TestServer_MutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t)
+	clientCA := newTestCA(t)
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	caFile := filepath.Join(dir, "ca.pem")
+	certPEM, keyPEM := ca.issue(t, 2, x509.ExtKeyUsageServerAuth)
+	require.NoError(t, os.WriteFile(certFile, certPEM, 0o600))
+	require.NoError(t, os.WriteFile(keyFile, keyPEM, 0o600))
+	require.NoError(t, os.WriteFile(caFile, clientCA.pem, 0o600))
+
+	srv := startTLSServer(t, TLSConfig{CertFile: certFile, KeyFile: keyFile, ClientCAFile: caFile})
+
+	// Without a client certificate.
+	_, err := tlsClient(ca, nil).Get("https://" + srv.Addr().String())
+	require.Error(t, err)
+
+	// With a client certificate signed by an unknown CA.
+	certPEM, keyPEM = ca.issue(t, 3, x509.ExtKeyUsageClientAuth)
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	require.NoError(t, err)
+	_, err = tlsClient(ca, &cert).Get("https://" + srv.Addr().String())
+	require.Error(t, err)
+
+	// With a valid client certificate.
+	certPEM, keyPEM = clientCA.issue(t, 4, x509.ExtKeyUsageClientAuth)
+	cert, err = tls.X509KeyPair(certPEM, keyPEM)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), peerSerial(t, tlsClient(ca, &cert), srv))
+}
+
+func TestServer_TLSInvalidFiles(t *testing.T) {
+	srv := New(&http.Server{Addr: "localhost:0"}, WithTLS(TLSConfig{
+		CertFile: filepath.Join(t.TempDir(), "missing.pem"),
+		KeyFile:  filepath.Join(t.TempDir(), "missing.pem"),
+	}))
+	require.Error(t, srv.Start(context.Background()))
+}
@@ -64,6 +64,14 @@ func (l Level) String() string {
 	return "unknown"
 }
 
+// LevelSetter is implemented by loggers that allow changing the logging
+// level at runtime.
+type LevelSetter interface {
+	// SetLevel changes the logging level of the logger and all loggers
+	// derived from it.
+	SetLevel(level Level)
+}
+
 // IsLevel reports whether current logger shows logs for the given log level.
 func IsLevel(logger Logger, level Level) bool {
 	return logger.Level() >= level
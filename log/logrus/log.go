@@ -16,12 +16,17 @@
 package logrus
 
 import (
+	"sync/atomic"
+
 	"github.com/sirupsen/logrus"
 
 	"github.com/chronicleprotocol/go-utils/log"
 )
 
 // New creates a new logger that uses Logrus for logging.
+//
+// The returned logger implements the log.LevelSetter interface. Changing
+// the level also changes the level of the underlying Logrus logger.
 func New(logrusLogger logrus.FieldLogger) log.Logger {
 	lvl := log.Debug
 	if l, ok := logrusLogger.(*logrus.Logger); ok {
@@ -42,17 +47,46 @@ func New(logrusLogger logrus.FieldLogger) log.Logger {
 			lvl = log.Debug
 		}
 	}
-	return &logger{log: logrusLogger, lvl: lvl}
+	l := &logger{log: logrusLogger, lvl: new(atomic.Uint32)}
+	l.lvl.Store(uint32(lvl))
+	return l
 }
 
 type logger struct {
 	log logrus.FieldLogger
-	lvl log.Level
+	lvl *atomic.Uint32 // lvl is shared with derived loggers
 }
 
 // Level implements new log.Logger interface.
 func (l *logger) Level() log.Level {
-	return l.lvl
+	return log.Level(l.lvl.Load())
+}
+
+// SetLevel implements the log.LevelSetter interface.
+func (l *logger) SetLevel(level log.Level) {
+	l.lvl.Store(uint32(level))
+	var root *logrus.Logger
+	switch ll := l.log.(type) {
+	case *logrus.Logger:
+		root = ll
+	case *logrus.Entry:
+		root = ll.Logger
+	}
+	if root == nil {
+		return
+	}
+	switch level {
+	case log.Panic:
+		root.SetLevel(logrus.PanicLevel)
+	case log.Error:
+		root.SetLevel(logrus.ErrorLevel)
+	case log.Warn:
+		root.SetLevel(logrus.WarnLevel)
+	case log.Info:
+		root.SetLevel(logrus.InfoLevel)
+	case log.Debug:
+		root.SetLevel(logrus.DebugLevel)
+	}
 }
 
 // WithField implements new log.Logger interface.
@@ -0,0 +1,164 @@
+//  Copyright (C) 2021-2023 Chronicle Labs, Inc.
+//
+//  This program is free software: you can redistribute it and/or modify
+//  it under the terms of the GNU Affero General Public License as
+//  published by the Free Software Foundation, either version 3 of the
+//  License, or (at your option) any later version.
+//
+//  This program is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of
+//  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU Affero General Public License for more details.
+//
+//  You should have received a copy of the GNU Affero General Public License
+//  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package requestid
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+const (
+	// Header is the name of the header that contains the request ID.
+	Header = "X-Request-ID"
+
+	// TraceparentHeader is the name of the W3C Trace Context header.
+	TraceparentHeader = "traceparent"
+
+	// MaxLength is the maximum length of a valid request ID.
+	MaxLength = 128
+)
+
+// Trace is a W3C Trace Context of the request.
+type Trace struct {
+	// TraceID is a 32 hex characters long identifier of the whole trace.
+	TraceID string
+
+	// ParentID is a 16 hex characters long identifier of the caller's span
+	// or an empty string if the trace was started by this service.
+	ParentID string
+
+	// SpanID is a 16 hex characters long identifier of the request handled
+	// by this service.
+	SpanID string
+
+	// Flags are the trace flags, such as the sampled flag.
+	Flags byte
+}
+
+// Traceparent returns the value of the traceparent header to be sent to
+// downstream services, with the SpanID as the parent ID.
+func (t Trace) Traceparent() string {
+	return fmt.Sprintf("00-%s-%s-%02x", t.TraceID, t.SpanID, t.Flags)
+}
+
+// New returns a new random request ID.
+func New() string {
+	return randomHex(16)
+}
+
+// Valid checks if the request ID is safe to use in logs and headers. A valid
+// ID is not empty, is at most MaxLength bytes long and contains only
+// printable ASCII characters other than space.
+func Valid(id string) bool {
+	if id == "" || len(id) > MaxLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// NewTrace starts a new trace with a random trace ID and span ID.
+func NewTrace() Trace {
+	return Trace{TraceID: randomHex(16), SpanID: NewSpanID()}
+}
+
+// NewSpanID returns a new random span ID.
+func NewSpanID() string {
+	return randomHex(8)
+}
+
+// ParseTraceparent parses the traceparent header. The span ID of
+// the returned trace is empty. Only the version 00 format is supported, but
+// higher versions are accepted as long as they start with the same fields,
+// as required by the specification.
+func ParseTraceparent(s string) (Trace, bool) {
+	parts := strings.Split(s, "-")
+	if len(parts) < 4 {
+		return Trace{}, false
+	}
+	version, traceID, parentID, flags := parts[0], parts[1], parts[2], parts[3]
+	if !isLowerHex(version, 2) || version == "ff" || (version == "00" && len(parts) != 4) {
+		return Trace{}, false
+	}
+	if !isLowerHex(traceID, 32) || isZeros(traceID) {
+		return Trace{}, false
+	}
+	if !isLowerHex(parentID, 16) || isZeros(parentID) {
+		return Trace{}, false
+	}
+	if !isLowerHex(flags, 2) {
+		return Trace{}, false
+	}
+	f, _ := hex.DecodeString(flags)
+	return Trace{TraceID: traceID, ParentID: parentID, Flags: f[0]}, true
+}
+
+type (
+	requestIDCtxKey struct{}
+	traceCtxKey     struct{}
+)
+
+// WithRequestID returns a copy of the context with the request ID.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDCtxKey{}, id)
+}
+
+// FromContext returns the request ID from the context or an empty string if
+// there is no request ID.
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDCtxKey{}).(string)
+	return id
+}
+
+// WithTrace returns a copy of the context with the trace context.
+func WithTrace(ctx context.Context, t Trace) context.Context {
+	return context.WithValue(ctx, traceCtxKey{}, t)
+}
+
+// TraceFromContext returns the trace context from the context.
+func TraceFromContext(ctx context.Context) (Trace, bool) {
+	t, ok := ctx.Value(traceCtxKey{}).(Trace)
+	return t, ok
+}
+
+func isLowerHex(s string, n int) bool {
+	if len(s) != n {
+		return false
+	}
+	for _, c := range s {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+func isZeros(s string) bool {
+	return strings.Trim(s, "0") == ""
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
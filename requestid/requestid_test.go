@@ -0,0 +1,112 @@
+//  Copyright (C) 2021-2023 Chronicle Labs, Inc.
+//
+//  This program is free software: you can redistribute it and/or modify
+//  it under the terms of the GNU Affero General Public License as
+//  published by the Free Software Foundation, either version 3 of the
+//  License, or (at your option) any later version.
+//
+//  This program is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of
+//  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU Affero General Public License for more details.
+//
+//  You should have received a copy of the GNU Affero General Public License
+//  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package requestid
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValid(t *testing.T) {
+	tests := []struct {
+		id   string
+		want bool
+	}{
+		{id: "foo", want: true},
+		{id: "0af7651916cd43dd8448eb211c80319c", want: true},
+		{id: strings.Repeat("a", MaxLength), want: true},
+		{id: "", want: false},
+		{id: "foo bar", want: false},
+		{id: "foo\n", want: false},
+		{id: "zażółć", want: false},
+		{id: strings.Repeat("a", MaxLength+1), want: false},
+	}
+	for n, tt := range tests {
+		t.Run(fmt.Sprintf("case-%d", n+1), func(t *testing.T) {
+			assert.Equal(t, tt.want, Valid(tt.id))
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+	id := New()
+	assert.Len(t, id, 32)
+	assert.True(t, Valid(id))
+	assert.NotEqual(t, id, New())
+}
+
+func TestParseTraceparent(t *testing.T) {
+	tests := []struct {
+		traceparent string
+		want        Trace
+		wantOK      bool
+	}{
+		{
+			traceparent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+			want:        Trace{TraceID: "4bf92f3577b34da6a3ce929d0e0e4736", ParentID: "00f067aa0ba902b7", Flags: 1},
+			wantOK:      true,
+		},
+		{
+			traceparent: "01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00-extra",
+			want:        Trace{TraceID: "4bf92f3577b34da6a3ce929d0e0e4736", ParentID: "00f067aa0ba902b7"},
+			wantOK:      true,
+		},
+		{traceparent: ""},
+		{traceparent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00-extra"},
+		{traceparent: "00-00000000000000000000000000000000-00f067aa0ba902b7-01"},
+		{traceparent: "00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01"},
+		{traceparent: "00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01"},
+		{traceparent: "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"},
+	}
+	for n, tt := range tests {
+		t.Run(fmt.Sprintf("case-%d", n+1), func(t *testing.T) {
+			trace, ok := ParseTraceparent(tt.traceparent)
+			assert.Equal(t, tt.wantOK, ok)
+			assert.Equal(t, tt.want, trace)
+		})
+	}
+}
+
+func TestNewTrace(t *testing.T) {
+	trace := NewTrace()
+	assert.Len(t, trace.TraceID, 32)
+	assert.Len(t, trace.SpanID, 16)
+	assert.Empty(t, trace.ParentID)
+
+	// The traceparent of a new trace can be parsed by downstream services.
+	parsed, ok := ParseTraceparent(trace.Traceparent())
+	assert.True(t, ok)
+	assert.Equal(t, trace.TraceID, parsed.TraceID)
+	assert.Equal(t, trace.SpanID, parsed.ParentID)
+}
+
+func TestContext(t *testing.T) {
+	ctx := context.Background()
+	assert.Empty(t, FromContext(ctx))
+	_, ok := TraceFromContext(ctx)
+	assert.False(t, ok)
+
+	trace := NewTrace()
+	ctx = WithTrace(WithRequestID(ctx, "foo"), trace)
+	assert.Equal(t, "foo", FromContext(ctx))
+	got, ok := TraceFromContext(ctx)
+	assert.True(t, ok)
+	assert.Equal(t, trace, got)
+}
@@ -0,0 +1,139 @@
+//  Copyright (C) 2021-2023 Chronicle Labs, Inc.
+//
+//  This program is free software: you can redistribute it and/or modify
+//  it under the terms of the GNU Affero General Public License as
+//  published by the Free Software Foundation, either version 3 of the
+//  License, or (at your option) any later version.
+//
+//  This program is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of
+//  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU Affero General Public License for more details.
+//
+//  You should have received a copy of the GNU Affero General Public License
+//  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package rpcsplitter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/defiweb/go-eth/rpc"
+
+	"github.com/chronicleprotocol/go-utils/requestid"
+)
+
+// InProcTransport implements the transport.Transport interface from the
+// go-eth package. It calls an RPC-Splitter instance running in the same
+// process directly, without HTTP serialization.
+//
+// The context passed to the Call method is propagated to the endpoints, so
+// when it is canceled, requests to endpoints are canceled as well. The request
+// ID and trace context stored in the context are propagated too, and a new
+// request ID is generated if there is none.
+type InProcTransport struct {
+	methods map[string]inProcMethod
+}
+
+// inProcMethod is an RPC method that can be called directly.
+type inProcMethod struct {
+	fn       reflect.Value  // fn is a method value bound to the API receiver.
+	argTypes []reflect.Type // argTypes are types of arguments, without the context.
+}
+
+// NewInProcTransport returns a new instance of InProcTransport. The options
+// are the same as for NewServer.
+func NewInProcTransport(opts ...Option) (*InProcTransport, error) {
+	s, err := newServer(opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &InProcTransport{methods: inProcMethods(map[string]any{"eth": s.eth, "net": s.net})}, nil
+}
+
+// Call implements the transport.Transport interface.
+func (t *InProcTransport) Call(ctx context.Context, result any, method string, args ...any) error {
+	m, ok := t.methods[method]
+	if !ok {
+		return fmt.Errorf("the method %s does not exist/is not available", method)
+	}
+	if len(args) > len(m.argTypes) {
+		return fmt.Errorf("too many arguments, want at most %d", len(m.argTypes))
+	}
+	if !requestid.Valid(requestid.FromContext(ctx)) {
+		ctx = requestid.WithRequestID(ctx, requestid.New())
+	}
+	in := []reflect.Value{reflect.ValueOf(ctx)}
+	for i, typ := range m.argTypes {
+		if i >= len(args) {
+			// Similarly to the go-ethereum RPC server, only optional (pointer)
+			// arguments may be omitted.
+			if typ.Kind() != reflect.Pointer {
+				return fmt.Errorf("missing value for required argument %d", i)
+			}
+			in = append(in, reflect.Zero(typ))
+			continue
+		}
+		arg := reflect.New(typ)
+		if err := convertValue(args[i], arg.Interface()); err != nil {
+			return fmt.Errorf("invalid argument %d: %w", i, err)
+		}
+		in = append(in, arg.Elem())
+	}
+	out := m.fn.Call(in)
+	if err, _ := out[1].Interface().(error); err != nil {
+		return err
+	}
+	if result == nil {
+		return nil
+	}
+	return convertValue(out[0].Interface(), result)
+}
+
+// Close closes the transport. The transport does not hold any resources, so
+// it does nothing.
+func (t *InProcTransport) Close() {}
+
+// NewClient returns a go-eth RPC client that uses the InProcTransport.
+func NewClient(opts ...Option) (*rpc.Client, error) {
+	t, err := NewInProcTransport(opts...)
+	if err != nil {
+		return nil, err
+	}
+	return rpc.NewClient(rpc.WithTransport(t))
+}
+
+// inProcMethods returns methods of the given API receivers, using the same
+// naming convention as the go-ethereum RPC server, e.g. the GasPrice method
+// of the "eth" receiver is returned as "eth_gasPrice".
+func inProcMethods(receivers map[string]any) map[string]inProcMethod {
+	methods := map[string]inProcMethod{}
+	for namespace, rcvr := range receivers {
+		v := reflect.ValueOf(rcvr)
+		for i := 0; i < v.NumMethod(); i++ {
+			name := v.Type().Method(i).Name
+			fn := v.Method(i)
+			var argTypes []reflect.Type
+			for j := 1; j < fn.Type().NumIn(); j++ { // Skip the context.
+				argTypes = append(argTypes, fn.Type().In(j))
+			}
+			methods[namespace+"_"+strings.ToLower(name[:1])+name[1:]] = inProcMethod{fn: fn, argTypes: argTypes}
+		}
+	}
+	return methods
+}
+
+// convertValue converts src to dst using the JSON encoding, the same way as
+// if the value was sent over the network. It is necessary because the go-eth
+// package and the RPC-Splitter use different types.
+func convertValue(src, dst any) error {
+	b, err := json.Marshal(src)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, dst)
+}
@@ -0,0 +1,117 @@
+//  Copyright (C) 2021-2023 Chronicle Labs, Inc.
+//
+//  This program is free software: you can redistribute it and/or modify
+//  it under the terms of the GNU Affero General Public License as
+//  published by the Free Software Foundation, either version 3 of the
+//  License, or (at your option) any later version.
+//
+//  This program is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of
+//  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU Affero General Public License for more details.
+//
+//  You should have received a copy of the GNU Affero General Public License
+//  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package rpcsplitter
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/chronicleprotocol/go-utils/requestid"
+	"github.com/chronicleprotocol/go-utils/rpcsplitter/types"
+)
+
+// funcCaller is a caller that calls the given function.
+type funcCaller func(ctx context.Context, result any, method string, args ...any) error
+
+func (f funcCaller) CallContext(ctx context.Context, result any, method string, args ...any) error {
+	return f(ctx, result, method, args...)
+}
+
+func TestNewClient(t *testing.T) {
+	rpcMock := &mockClient{t: t}
+	client, err := NewClient(
+		withCallers(map[string]caller{"caller": rpcMock}),
+		WithRequirements(1, 1),
+	)
+	require.NoError(t, err)
+
+	rpcMock.mockCall(`0x1`, "eth_chainId")
+
+	chainID, err := client.ChainID(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), chainID)
+}
+
+func TestInProcTransport_RequestID(t *testing.T) {
+	var ids []string
+	c := funcCaller(func(ctx context.Context, result any, method string, args ...any) error {
+		ids = append(ids, requestid.FromContext(ctx))
+		return json.Unmarshal([]byte(`"0x1"`), result)
+	})
+	tr, err := NewInProcTransport(withCallers(map[string]caller{"caller": c}), WithRequirements(1, 1))
+	require.NoError(t, err)
+	defer tr.Close()
+
+	var res any
+	ctx := requestid.WithRequestID(context.Background(), "foo")
+	require.NoError(t, tr.Call(ctx, &res, "eth_chainId"))
+	require.NoError(t, tr.Call(context.Background(), &res, "eth_chainId"))
+	require.Len(t, ids, 2)
+	assert.Equal(t, "foo", ids[0])
+	assert.Len(t, ids[1], 32)
+}
+
+func TestInProcTransport_Cancel(t *testing.T) {
+	canceled := make(chan struct{})
+	c := funcCaller(func(ctx context.Context, result any, method string, args ...any) error {
+		<-ctx.Done()
+		close(canceled)
+		return ctx.Err()
+	})
+	tr, err := NewInProcTransport(withCallers(map[string]caller{"caller": c}), WithRequirements(1, 1))
+	require.NoError(t, err)
+	defer tr.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	var res any
+	require.Error(t, tr.Call(ctx, &res, "eth_chainId"))
+
+	// The call to the endpoint is canceled together with the caller's
+	// context, not after the total timeout.
+	select {
+	case <-canceled:
+	case <-time.After(time.Second):
+		require.Fail(t, "the endpoint call should be canceled")
+	}
+}
+
+func TestInProcTransport_Arguments(t *testing.T) {
+	var params []any
+	c := funcCaller(func(ctx context.Context, result any, method string, args ...any) error {
+		params = args
+		return json.Unmarshal([]byte(`"0x01"`), result)
+	})
+	tr, err := NewInProcTransport(withCallers(map[string]caller{"caller": c}), WithRequirements(1, 1))
+	require.NoError(t, err)
+	defer tr.Close()
+
+	// The optional overrides argument is omitted.
+	var res string
+	require.NoError(t, tr.Call(context.Background(), &res, "eth_call", map[string]any{"to": "0x00"}, "0x10"))
+	assert.Equal(t, "0x01", res)
+	require.GreaterOrEqual(t, len(params), 2)
+	assert.Equal(t, types.StringToBlockNumber("0x10"), params[1])
+
+	assert.Error(t, tr.Call(context.Background(), &res, "eth_call"))
+	assert.Error(t, tr.Call(context.Background(), &res, "eth_chainId", 1))
+	assert.Error(t, tr.Call(context.Background(), &res, "eth_foo"))
+}
@@ -99,21 +99,35 @@ func addError(err error, errs ...error) error {
 	return errList
 }
 
+// mostCommon returns the most common value in s. If multiple values are
+// equally common, the one that appears first in s is returned.
 func mostCommon[T comparable](s []T) T {
+	v, _ := mostCommonUnique(s)
+	return v
+}
+
+// mostCommonUnique works like mostCommon, but it also reports whether
+// the returned value is more common than any other value.
+func mostCommonUnique[T comparable](s []T) (T, bool) {
 	if len(s) == 0 {
-		return *new(T)
+		return *new(T), false
 	}
 	m := make(map[T]int)
 	for _, v := range s {
 		m[v]++
 	}
-	var max T
-	var maxCount int
-	for k, v := range m {
-		if v > maxCount {
-			maxCount = v
-			max = k
+	var (
+		max      T
+		maxCount int
+		unique   bool
+	)
+	for _, v := range s {
+		switch c := m[v]; {
+		case c > maxCount:
+			max, maxCount, unique = v, c, true
+		case c == maxCount && v != max:
+			unique = false
 		}
 	}
-	return max
+	return max, unique
 }
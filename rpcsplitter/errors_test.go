@@ -61,3 +61,28 @@ func Test_addError(t *testing.T) {
 		})
 	}
 }
+
+func Test_mostCommon(t *testing.T) {
+	tests := []struct {
+		values     []string
+		want       string
+		wantUnique bool
+	}{
+		{values: nil, want: "", wantUnique: false},
+		{values: []string{"a"}, want: "a", wantUnique: true},
+		{values: []string{"a", "b", "b"}, want: "b", wantUnique: true},
+		{values: []string{"a", "b"}, want: "a", wantUnique: false},
+		{values: []string{"b", "a", "a", "b"}, want: "b", wantUnique: false},
+		{values: []string{"c", "a", "a", "b", "b", "b"}, want: "b", wantUnique: true},
+	}
+	for n, tt := range tests {
+		t.Run(fmt.Sprintf("case-%d", n+1), func(t *testing.T) {
+			for i := 0; i < 10; i++ {
+				v, unique := mostCommonUnique(tt.values)
+				assert.Equal(t, tt.want, v)
+				assert.Equal(t, tt.wantUnique, unique)
+				assert.Equal(t, tt.want, mostCommon(tt.values))
+			}
+		})
+	}
+}
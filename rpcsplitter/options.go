@@ -16,6 +16,9 @@
 package rpcsplitter
 
 import (
+	"fmt"
+	"maps"
+	"slices"
 	"time"
 
 	gethRPC "github.com/ethereum/go-ethereum/rpc"
@@ -61,6 +64,73 @@ func WithRequirements(minResponses int, maxBlockBehind int) Option {
 	}
 }
 
+// WithWeights assigns weights to endpoints. The weights are used to find the
+// most common response for methods that require all responses to be the same.
+// Endpoints that are not in the map have the weight of 1. The keys of
+// the map must match the endpoints passed to the WithEndpoints option.
+//
+// quorum - the minimum total weight of the most common response. If zero,
+// the minResponses value from the WithRequirements option is used.
+func WithWeights(weights map[string]int, quorum int) Option {
+	return func(s *server) error {
+		for e, w := range weights {
+			if w < 0 {
+				return fmt.Errorf("weight of the %s endpoint must not be negative", e)
+			}
+		}
+		if quorum < 0 {
+			return fmt.Errorf("quorum must not be negative")
+		}
+		s.weights = maps.Clone(weights)
+		s.quorum = quorum
+		return nil
+	}
+}
+
+// WithConflictPolicy sets a policy used to resolve conflicts when endpoints
+// return different responses for methods that require all responses to be
+// the same. If the policy does not choose any response, or the chosen response
+// does not reach the quorum, the response with the highest total weight is
+// used.
+func WithConflictPolicy(policy ConflictPolicy) Option {
+	return func(s *server) error {
+		s.conflictPolicy = policy
+		return nil
+	}
+}
+
+// WithGasStrategy sets the strategy used to calculate gas values returned by
+// the given methods. Supported methods are "eth_gasPrice", "eth_estimateGas",
+// "eth_maxPriorityFeePerGas" and "eth_feeHistory". If no methods are given,
+// the strategy is used for "eth_gasPrice", "eth_estimateGas" and
+// "eth_maxPriorityFeePerGas".
+//
+// By default, eth_feeHistory requires all responses to be the same. If
+// a strategy is explicitly set for this method, the base fee and reward
+// arrays are aggregated element-wise instead.
+//
+// An error is returned if the strategy parameters are invalid.
+func WithGasStrategy(strategy GasStrategy, methods ...string) Option {
+	return func(s *server) error {
+		if strategy == nil {
+			return fmt.Errorf("gas strategy must not be nil")
+		}
+		if err := validateGasStrategy(strategy); err != nil {
+			return fmt.Errorf("invalid gas strategy: %w", err)
+		}
+		if len(methods) == 0 {
+			methods = defaultGasMethods
+		}
+		for _, m := range methods {
+			if !slices.Contains(gasMethods, m) {
+				return fmt.Errorf("gas strategy is not supported for the %s method", m)
+			}
+			s.gasStrategies[m] = strategy
+		}
+		return nil
+	}
+}
+
 // WithTotalTimeout sets the total timeout for all endpoints. When the timeout
 // is exceeded, RPC-Splitter cancels all requests to the endpoints.
 func WithTotalTimeout(t time.Duration) Option {
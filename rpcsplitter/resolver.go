@@ -19,6 +19,8 @@ import (
 	"errors"
 	"math/big"
 	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/chronicleprotocol/go-utils/rpcsplitter/types"
 )
@@ -27,95 +29,274 @@ var errNotEnoughResponses = errors.New("not enough responses from RPC servers")
 var errDifferentResponses = errors.New("RPC servers returned different responses")
 
 // resolver takes responses from different endpoints and returns a single
-// response.
+// response. The endpoints slice contains the names of the endpoints that
+// returned the responses, in the same order as the responses. It may be nil
+// if the names are not known.
 type resolver interface {
-	resolve([]any) (any, error)
+	resolve(resps []any, endpoints []string) (any, error)
+}
+
+// Vote represents a group of identical responses returned by endpoints.
+type Vote struct {
+	Endpoints []string // Endpoints that returned the response.
+	Weight    int      // Weight is the sum of the weights of the endpoints.
+}
+
+// ConflictPolicy is used by the default resolver to resolve conflicts when
+// endpoints return different responses. Votes are sorted by weight in
+// descending order. The function must return the index of the winning vote
+// or -1 if the conflict should be resolved by the weighted majority. The
+// winning vote must still reach the quorum, otherwise the conflict is
+// resolved by the weighted majority.
+type ConflictPolicy func(votes []Vote) int
+
+// TrustedEndpointPolicy returns a conflict policy that selects the response
+// returned by the trusted endpoint if at least minCorroborating other
+// endpoints returned the same response.
+func TrustedEndpointPolicy(endpoint string, minCorroborating int) ConflictPolicy {
+	return func(votes []Vote) int {
+		for i, v := range votes {
+			for _, e := range v.Endpoints {
+				if e == endpoint && len(v.Endpoints)-1 >= minCorroborating {
+					return i
+				}
+			}
+		}
+		return -1
+	}
 }
 
 // defaultResolver compares responses with each other and returns the most
-// common one. If there are multiple responses with the same number of
-// occurrences but greater than minResponses, an error is returned.
+// common one. Every response is counted with the weight of the endpoint that
+// returned it. If there are multiple responses with the same total weight or
+// the total weight of the most common response is lower than the quorum, an
+// error is returned.
+//
+// If the endpoints returned different responses and the conflict policy is
+// set, the policy is used to choose the response, provided that it reaches
+// the quorum.
 type defaultResolver struct {
-	minResponses int // specifies minimum number of occurrences of the most common response
+	minResponses int            // specifies minimum number of occurrences of the most common response
+	weights      map[string]int // endpoint weights, endpoints not in the map have the weight of 1
+	quorum       int            // minimum total weight of the most common response, if 0, minResponses is used
+	policy       ConflictPolicy // optional policy used to resolve conflicts
 }
 
 // resolve implements resolver interface.
-func (r *defaultResolver) resolve(resps []any) (any, error) {
-	resps, errs := extractErrors(resps)
-	if len(resps) < r.minResponses {
+func (r *defaultResolver) resolve(resps []any, endpoints []string) (any, error) {
+	resps, endpoints, errs := extractEndpointErrors(resps, endpoints)
+	quorum := r.quorum
+	if quorum == 0 {
+		quorum = r.minResponses
+	}
+	total := 0
+	for _, e := range endpoints {
+		total += r.weight(e)
+	}
+	if total < quorum || len(resps) == 0 {
 		return nil, addError(errNotEnoughResponses, errs...)
 	}
 	if len(resps) == 1 {
 		return resps[0], nil
 	}
-	mostCommonResp := resps[0]
-	mostCommonCounter := 0
-	multiple := false
-	for _, a := range resps {
-		counter := 0
-		for _, b := range resps {
+
+	// Group identical responses.
+	var (
+		values []any
+		votes  []Vote
+	)
+	for i, a := range resps {
+		found := false
+		for j, b := range values {
 			if compare(a, b) {
-				counter++
+				votes[j].Endpoints = append(votes[j].Endpoints, endpoints[i])
+				votes[j].Weight += r.weight(endpoints[i])
+				found = true
+				break
 			}
 		}
-		if counter > mostCommonCounter {
-			multiple = false
-			mostCommonResp = a
-			mostCommonCounter = counter
+		if !found {
+			values = append(values, a)
+			votes = append(votes, Vote{
+				Endpoints: []string{endpoints[i]},
+				Weight:    r.weight(endpoints[i]),
+			})
 		}
-		if counter == mostCommonCounter && !compare(mostCommonResp, a) {
-			multiple = true
+	}
+	sort.Stable(votesByWeight{values: values, votes: votes})
+
+	// Use the conflict policy if the responses are different.
+	if len(votes) > 1 && r.policy != nil {
+		if i := r.policy(votes); i >= 0 && i < len(votes) && votes[i].Weight >= quorum {
+			return values[i], nil
 		}
 	}
-	if multiple || mostCommonCounter < r.minResponses {
+	if len(votes) > 1 && votes[0].Weight == votes[1].Weight {
+		return nil, addError(errDifferentResponses, errs...)
+	}
+	if votes[0].Weight < quorum {
 		return nil, addError(errDifferentResponses, errs...)
 	}
-	return mostCommonResp, nil
+	return values[0], nil
+}
+
+// weight returns the weight of the given endpoint.
+func (r *defaultResolver) weight(endpoint string) int {
+	if w, ok := r.weights[endpoint]; ok {
+		return w
+	}
+	return 1
+}
+
+// votesByWeight sorts votes and corresponding values by weight in
+// descending order.
+type votesByWeight struct {
+	values []any
+	votes  []Vote
+}
+
+func (v votesByWeight) Len() int {
+	return len(v.votes)
+}
+
+func (v votesByWeight) Less(i, j int) bool {
+	return v.votes[i].Weight > v.votes[j].Weight
+}
+
+func (v votesByWeight) Swap(i, j int) {
+	v.values[i], v.values[j] = v.values[j], v.values[i]
+	v.votes[i], v.votes[j] = v.votes[j], v.votes[i]
 }
 
 // gasValueResolver is designed to handle responses from methods returning a
-// gas value. The way how the response is calculated depends on the number of
-// responses:
-// * one response: returns value as is
-// * two responses: returns the lowest one
-// * three or more responses: returns the median value
+// gas value. The way how the response is calculated depends on the strategy.
+// If the strategy is not set, MedianStrategy is used.
 type gasValueResolver struct {
-	minResponses int // specifies minimum number of valid responses
+	minResponses int         // specifies minimum number of valid responses
+	strategy     GasStrategy // strategy used to calculate the gas value
 }
 
 // resolve implements resolver interface.
-func (r *gasValueResolver) resolve(resps []any) (any, error) {
+func (r *gasValueResolver) resolve(resps []any, _ []string) (any, error) {
 	resps, errs := extractErrors(resps)
 	ns := filterByNumberType(resps)
-	if len(ns) < r.minResponses {
+	if len(ns) < r.minResponses || len(ns) == 0 {
 		return nil, addError(errNotEnoughResponses, errs...)
 	}
 	if len(ns) == 1 {
-		return resps[0], nil
+		return ns[0], nil
+	}
+	values := make([]*big.Int, len(ns))
+	for i, n := range ns {
+		values[i] = n.Big()
+	}
+	strategy := r.strategy
+	if strategy == nil {
+		strategy = MedianStrategy()
+	}
+	v, err := strategy.GasValue(values)
+	if err != nil {
+		return nil, addError(err, errs...)
+	}
+	return bigToNumberPtr(v), nil
+}
+
+// feeHistoryResolver is designed to handle responses from the eth_feeHistory
+// method. Instead of requiring all responses to be the same, it aggregates
+// the base fee and reward arrays element-wise using the strategy. Only
+// responses that have the same oldest block and the same array lengths as
+// the most common one are used. If there is a tie for the most common one,
+// an error is returned. Gas used ratios are aggregated using
+// the median.
+type feeHistoryResolver struct {
+	minResponses int         // specifies minimum number of valid responses
+	strategy     GasStrategy // strategy used to calculate the gas values
+}
+
+// resolve implements resolver interface.
+func (r *feeHistoryResolver) resolve(resps []any, _ []string) (any, error) {
+	resps, errs := extractErrors(resps)
+	fhs := filterByFeeHistoryType(resps)
+	if len(fhs) < r.minResponses || len(fhs) == 0 {
+		return nil, addError(errNotEnoughResponses, errs...)
 	}
-	if len(ns) == 2 {
-		// With two correct answers, it is safer to return the lower value.
-		// Otherwise, the compromised endpoint may return a very high gas
-		// price. If this price is used to determine transaction fees, it
-		// could cause clients to lose money on transaction fees.
-		a := ns[0].Big()
-		b := ns[1].Big()
-		if a.Cmp(b) > 0 {
-			return bigToNumberPtr(b), nil
+	if len(fhs) == 1 {
+		return fhs[0], nil
+	}
+
+	// Use only responses with the same shape as the most common one.
+	shapes := make([]string, len(fhs))
+	for i, fh := range fhs {
+		shapes[i] = feeHistoryShape(fh)
+	}
+	shape, ok := mostCommonUnique(shapes)
+	if !ok {
+		// There is no single most common shape, so it is not possible
+		// to decide which responses to use.
+		return nil, addError(errDifferentResponses, errs...)
+	}
+	var same []*types.FeeHistory
+	for i, fh := range fhs {
+		if shapes[i] == shape {
+			same = append(same, fh)
 		}
-		return bigToNumberPtr(a), nil
 	}
-	// Calculate the median.
-	sort.Slice(ns, func(i, j int) bool {
-		return ns[i].Big().Cmp(ns[j].Big()) < 0
-	})
-	if len(ns)%2 == 0 {
-		m := len(ns) / 2
-		bx := ns[m-1].Big()
-		by := ns[m].Big()
-		return bigToNumberPtr(new(big.Int).Div(new(big.Int).Add(bx, by), big.NewInt(2))), nil
+	if len(same) < r.minResponses {
+		return nil, addError(errDifferentResponses, errs...)
+	}
+
+	strategy := r.strategy
+	if strategy == nil {
+		strategy = MedianStrategy()
+	}
+	aggregate := func(get func(fh *types.FeeHistory) types.Number) (types.Number, error) {
+		values := make([]*big.Int, len(same))
+		for i, fh := range same {
+			n := get(fh)
+			values[i] = n.Big()
+		}
+		v, err := strategy.GasValue(values)
+		if err != nil {
+			return types.Number{}, err
+		}
+		return types.BigToNumber(v), nil
+	}
+	res := &types.FeeHistory{
+		OldestBlock:   same[0].OldestBlock,
+		BaseFeePerGas: make([]types.Number, len(same[0].BaseFeePerGas)),
+		GasUsedRatio:  make([]float64, len(same[0].GasUsedRatio)),
+		Reward:        make([][]types.Number, len(same[0].Reward)),
+	}
+	for i := range res.BaseFeePerGas {
+		v, err := aggregate(func(fh *types.FeeHistory) types.Number { return fh.BaseFeePerGas[i] })
+		if err != nil {
+			return nil, addError(err, errs...)
+		}
+		res.BaseFeePerGas[i] = v
+	}
+	for i := range res.Reward {
+		res.Reward[i] = make([]types.Number, len(same[0].Reward[i]))
+		for j := range res.Reward[i] {
+			v, err := aggregate(func(fh *types.FeeHistory) types.Number { return fh.Reward[i][j] })
+			if err != nil {
+				return nil, addError(err, errs...)
+			}
+			res.Reward[i][j] = v
+		}
+	}
+	for i := range res.GasUsedRatio {
+		ratios := make([]float64, len(same))
+		for j, fh := range same {
+			ratios[j] = fh.GasUsedRatio[i]
+		}
+		sort.Float64s(ratios)
+		if len(ratios)%2 == 0 {
+			res.GasUsedRatio[i] = (ratios[len(ratios)/2-1] + ratios[len(ratios)/2]) / 2
+		} else {
+			res.GasUsedRatio[i] = ratios[len(ratios)/2]
+		}
 	}
-	return ns[len(ns)/2], nil
+	return res, nil
 }
 
 // blockNumberResolver is designed to handle responses from eth_blockNumber method.
@@ -129,7 +310,7 @@ type blockNumberResolver struct {
 }
 
 // resolve implements resolver interface.
-func (r *blockNumberResolver) resolve(resps []any) (any, error) {
+func (r *blockNumberResolver) resolve(resps []any, _ []string) (any, error) {
 	resps, errs := extractErrors(resps)
 	ns := filterByNumberType(resps)
 	if len(ns) < r.minResponses {
@@ -168,6 +349,25 @@ func extractErrors(resps []any) (filtered []any, errs []error) {
 	return
 }
 
+// extractEndpointErrors works like extractErrors but also removes the names
+// of endpoints that returned errors. If endpoints is nil, the responses are
+// given consecutive numbers as names.
+func extractEndpointErrors(resps []any, endpoints []string) (filtered []any, names []string, errs []error) {
+	for i, r := range resps {
+		name := strconv.Itoa(i)
+		if i < len(endpoints) {
+			name = endpoints[i]
+		}
+		if e, ok := r.(error); ok {
+			errs = append(errs, e)
+		} else {
+			filtered = append(filtered, r)
+			names = append(names, name)
+		}
+	}
+	return
+}
+
 func filterByNumberType(resps []any) (s []*types.Number) {
 	for _, r := range resps {
 		if t, ok := r.(*types.Number); ok {
@@ -177,6 +377,28 @@ func filterByNumberType(resps []any) (s []*types.Number) {
 	return
 }
 
+func filterByFeeHistoryType(resps []any) (s []*types.FeeHistory) {
+	for _, r := range resps {
+		if t, ok := r.(*types.FeeHistory); ok {
+			s = append(s, t)
+		}
+	}
+	return
+}
+
+// feeHistoryShape returns a string that describes the oldest block and
+// the lengths of the arrays in the fee history.
+func feeHistoryShape(fh *types.FeeHistory) string {
+	b := strings.Builder{}
+	b.WriteString(fh.OldestBlock.String())
+	b.WriteString(":" + strconv.Itoa(len(fh.BaseFeePerGas)))
+	b.WriteString(":" + strconv.Itoa(len(fh.GasUsedRatio)))
+	for _, r := range fh.Reward {
+		b.WriteString(":" + strconv.Itoa(len(r)))
+	}
+	return b.String()
+}
+
 func bigToNumberPtr(x *big.Int) *types.Number {
 	n := types.BigToNumber(x)
 	return &n
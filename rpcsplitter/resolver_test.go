@@ -87,7 +87,7 @@ func Test_defaultResolver_resolve(t *testing.T) {
 	for n, tt := range tests {
 		t.Run(fmt.Sprintf("case-%d", n), func(t *testing.T) {
 			r := defaultResolver{minResponses: tt.minResponses}
-			v, err := r.resolve(tt.resps)
+			v, err := r.resolve(tt.resps, nil)
 			if tt.wantErr {
 				require.Error(t, err)
 				return
@@ -97,6 +97,111 @@ func Test_defaultResolver_resolve(t *testing.T) {
 	}
 }
 
+func Test_defaultResolver_resolveWeighted(t *testing.T) {
+	tests := []struct {
+		resps     []any
+		endpoints []string
+		weights   map[string]int
+		quorum    int
+		policy    ConflictPolicy
+		want      any
+		wantErr   bool
+	}{
+		{
+			resps:     []any{newAny(`"a"`), newAny(`"b"`), newAny(`"b"`)},
+			endpoints: []string{"trusted", "public1", "public2"},
+			weights:   map[string]int{"trusted": 3},
+			quorum:    2,
+			want:      newAny(`"a"`),
+		},
+		{
+			resps:     []any{newAny(`"a"`), newAny(`"b"`), newAny(`"b"`), newAny(`"b"`)},
+			endpoints: []string{"trusted", "public1", "public2", "public3"},
+			weights:   map[string]int{"trusted": 3},
+			quorum:    2,
+			wantErr:   true,
+		},
+		{
+			resps:     []any{newAny(`"a"`), newAny(`"b"`)},
+			endpoints: []string{"trusted", "public1"},
+			weights:   map[string]int{"trusted": 3},
+			quorum:    4,
+			wantErr:   true,
+		},
+		{
+			resps:     []any{newAny(`"a"`), errors.New("err"), newAny(`"a"`)},
+			endpoints: []string{"trusted", "public1", "public2"},
+			weights:   map[string]int{"trusted": 3},
+			quorum:    4,
+			want:      newAny(`"a"`),
+		},
+		{
+			resps:     []any{newAny(`"b"`), errors.New("err")},
+			endpoints: []string{"public1", "trusted"},
+			weights:   map[string]int{"trusted": 3},
+			quorum:    2,
+			wantErr:   true,
+		},
+		{
+			resps:     []any{newAny(`"a"`), newAny(`"a"`), newAny(`"b"`), newAny(`"b"`), newAny(`"b"`)},
+			endpoints: []string{"trusted", "public1", "public2", "public3", "public4"},
+			quorum:    2,
+			policy:    TrustedEndpointPolicy("trusted", 1),
+			want:      newAny(`"a"`),
+		},
+		{
+			resps:     []any{newAny(`"a"`), newAny(`"b"`), newAny(`"b"`)},
+			endpoints: []string{"trusted", "public1", "public2"},
+			quorum:    2,
+			policy:    TrustedEndpointPolicy("trusted", 1),
+			want:      newAny(`"b"`),
+		},
+		{
+			resps:     []any{newAny(`"a"`), newAny(`"b"`), newAny(`"c"`)},
+			endpoints: []string{"trusted", "public1", "public2"},
+			quorum:    1,
+			policy:    TrustedEndpointPolicy("trusted", 1),
+			wantErr:   true,
+		},
+		{
+			// The response chosen by the policy must reach the quorum.
+			resps:     []any{newAny(`"a"`), newAny(`"a"`), newAny(`"b"`)},
+			endpoints: []string{"trusted", "public1", "public2"},
+			quorum:    3,
+			policy:    TrustedEndpointPolicy("trusted", 1),
+			wantErr:   true,
+		},
+		{
+			resps:     []any{newAny(`"a"`), newAny(`"a"`), errors.New("err")},
+			endpoints: []string{"trusted", "public1", "public2"},
+			quorum:    3,
+			policy:    TrustedEndpointPolicy("trusted", 1),
+			wantErr:   true,
+		},
+		{
+			// If the response chosen by the policy does not reach the quorum,
+			// the weighted majority is used.
+			resps:     []any{newAny(`"a"`), newAny(`"a"`), newAny(`"b"`), newAny(`"b"`), newAny(`"b"`)},
+			endpoints: []string{"trusted", "public1", "public2", "public3", "public4"},
+			quorum:    3,
+			policy:    TrustedEndpointPolicy("trusted", 1),
+			want:      newAny(`"b"`),
+		},
+	}
+	for n, tt := range tests {
+		t.Run(fmt.Sprintf("case-%d", n), func(t *testing.T) {
+			r := defaultResolver{weights: tt.weights, quorum: tt.quorum, policy: tt.policy}
+			v, err := r.resolve(tt.resps, tt.endpoints)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, v)
+		})
+	}
+}
+
 func Test_gasValueResolver_resolve(t *testing.T) {
 	tests := []struct {
 		resps        []any
@@ -153,7 +258,7 @@ func Test_gasValueResolver_resolve(t *testing.T) {
 	for n, tt := range tests {
 		t.Run(fmt.Sprintf("case-%d", n), func(t *testing.T) {
 			r := gasValueResolver{minResponses: tt.minResponses}
-			v, err := r.resolve(tt.resps)
+			v, err := r.resolve(tt.resps, nil)
 			if tt.wantErr {
 				require.Error(t, err)
 				return
@@ -211,7 +316,7 @@ func Test_blockNumberResolver_resolve(t *testing.T) {
 	for n, tt := range tests {
 		t.Run(fmt.Sprintf("case-%d", n), func(t *testing.T) {
 			r := blockNumberResolver{minResponses: tt.minResponses, maxBlocksBehind: tt.maxBlocksBehind}
-			v, err := r.resolve(tt.resps)
+			v, err := r.resolve(tt.resps, nil)
 			if tt.wantErr {
 				require.Error(t, err)
 				return
@@ -221,6 +326,56 @@ func Test_blockNumberResolver_resolve(t *testing.T) {
 	}
 }
 
+func Test_feeHistoryResolver_resolve(t *testing.T) {
+	fh := func(oldest string, baseFees ...string) *types.FeeHistory {
+		f := &types.FeeHistory{OldestBlock: types.HexToNumber(oldest)}
+		for _, b := range baseFees {
+			f.BaseFeePerGas = append(f.BaseFeePerGas, types.HexToNumber(b))
+			f.GasUsedRatio = append(f.GasUsedRatio, 0.5)
+		}
+		return f
+	}
+	tests := []struct {
+		resps        []any
+		minResponses int
+		want         any
+		wantErr      bool
+	}{
+		{
+			resps:        []any{fh("0x1", "0x1", "0x4"), fh("0x1", "0x2", "0x5"), fh("0x1", "0x3", "0x6")},
+			minResponses: 2,
+			want:         fh("0x1", "0x2", "0x5"),
+		},
+		{
+			resps:        []any{fh("0x1", "0x1", "0x4"), fh("0x1", "0x3", "0x6"), fh("0x2", "0x9", "0x9")},
+			minResponses: 2,
+			want:         fh("0x1", "0x1", "0x4"),
+		},
+		{
+			resps:        []any{fh("0x1", "0x1"), fh("0x1", "0x3", "0x6"), errors.New("err")},
+			minResponses: 2,
+			wantErr:      true,
+		},
+		{
+			resps:        []any{fh("0x1", "0x1"), errors.New("err")},
+			minResponses: 2,
+			wantErr:      true,
+		},
+	}
+	for n, tt := range tests {
+		t.Run(fmt.Sprintf("case-%d", n), func(t *testing.T) {
+			r := feeHistoryResolver{minResponses: tt.minResponses}
+			v, err := r.resolve(tt.resps, nil)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.True(t, compare(tt.want, v))
+		})
+	}
+}
+
 func hexToNumberPtr(hex string) *types.Number {
 	n := types.HexToNumber(hex)
 	return &n
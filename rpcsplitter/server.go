@@ -28,14 +28,25 @@ import (
 
 	"github.com/chronicleprotocol/go-utils/log"
 	"github.com/chronicleprotocol/go-utils/log/null"
+	"github.com/chronicleprotocol/go-utils/requestid"
 	"github.com/chronicleprotocol/go-utils/rpcsplitter/types"
 )
 
 const LoggerTag = "RPCSPLITTER"
 
+// RequestIDHeader is the name of the header that contains the request ID.
+const RequestIDHeader = requestid.Header
+
 const defaultTotalTimeout = 10 * time.Second
 const defaultGracefulTimeout = 1 * time.Second
 
+// gasMethods is a list of methods that support gas strategies.
+var gasMethods = []string{"eth_gasPrice", "eth_estimateGas", "eth_maxPriorityFeePerGas", "eth_feeHistory"}
+
+// defaultGasMethods is a list of methods that use the gas strategy if
+// the WithGasStrategy option is used without methods.
+var defaultGasMethods = []string{"eth_gasPrice", "eth_estimateGas", "eth_maxPriorityFeePerGas"}
+
 type caller interface {
 	CallContext(ctx context.Context, result any, method string, args ...any) error
 }
@@ -55,10 +66,19 @@ type server struct {
 	// if there is enough responses.
 	gracefulTimeout time.Duration
 
+	// Endpoint weights, quorum and conflict policy used by the default resolver.
+	weights        map[string]int
+	quorum         int
+	conflictPolicy ConflictPolicy
+
+	// Gas strategies for methods returning gas values.
+	gasStrategies map[string]GasStrategy
+
 	// Resolvers used to convert multiple responses into a single response:
 	defaultResolver     *defaultResolver
 	gasValueResolver    *gasValueResolver
 	blockNumberResolver *blockNumberResolver
+	methodResolvers     map[string]resolver
 }
 
 type rpcETHAPI struct {
@@ -69,10 +89,17 @@ type rpcNETAPI struct {
 	handler *server
 }
 
+// NewServer returns a new RPC-Splitter server as an http.Handler.
 func NewServer(opts ...Option) (http.Handler, error) {
+	return newServer(opts...)
+}
+
+func newServer(opts ...Option) (*server, error) {
 	h := &server{
-		rpc:     gethRPC.NewServer(),
-		callers: map[string]caller{},
+		rpc:             gethRPC.NewServer(),
+		callers:         map[string]caller{},
+		gasStrategies:   map[string]GasStrategy{},
+		methodResolvers: map[string]resolver{},
 	}
 	eth := &rpcETHAPI{handler: h}
 	net := &rpcNETAPI{handler: h}
@@ -99,6 +126,21 @@ func NewServer(opts ...Option) (http.Handler, error) {
 	if h.defaultResolver == nil || h.gasValueResolver == nil || h.blockNumberResolver == nil {
 		return nil, fmt.Errorf("rpc-splitter error: WithRequirements option is required")
 	}
+	for e := range h.weights {
+		if _, ok := h.callers[e]; !ok {
+			return nil, fmt.Errorf("rpc-splitter error: weight is set for the unknown endpoint %s", e)
+		}
+	}
+	h.defaultResolver.weights = h.weights
+	h.defaultResolver.quorum = h.quorum
+	h.defaultResolver.policy = h.conflictPolicy
+	for m, st := range h.gasStrategies {
+		if m == "eth_feeHistory" {
+			h.methodResolvers[m] = &feeHistoryResolver{minResponses: h.gasValueResolver.minResponses, strategy: st}
+			continue
+		}
+		h.methodResolvers[m] = &gasValueResolver{minResponses: h.gasValueResolver.minResponses, strategy: st}
+	}
 	if h.totalTimeout == 0 {
 		h.totalTimeout = defaultTotalTimeout
 	}
@@ -109,16 +151,34 @@ func NewServer(opts ...Option) (http.Handler, error) {
 	return h, nil
 }
 
+// ServeHTTP implements the http.Handler interface.
+//
+// The request ID assigned by the middleware.RequestID middleware is used if
+// present. Otherwise, it is taken from the RequestIDHeader header or generated
+// if the header is missing or invalid. A valid ID contains only printable
+// ASCII characters and is at most 128 bytes long. It is added to the response
+// headers, logs, and requests sent to endpoints. The trace context, if
+// present, is also propagated to the endpoints. The request context is
+// propagated to the endpoints, so when the client disconnects, all pending
+// calls are canceled.
 func (s *server) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
-	s.rpc.ServeHTTP(rw, req)
+	id := requestid.FromContext(req.Context())
+	if id == "" {
+		id = req.Header.Get(RequestIDHeader)
+	}
+	if !requestid.Valid(id) {
+		id = requestid.New()
+	}
+	rw.Header().Set(RequestIDHeader, id)
+	s.rpc.ServeHTTP(rw, req.WithContext(requestid.WithRequestID(req.Context(), id)))
 }
 
 // BlockNumber implements the "eth_blockNumber" call.
 //
 // It returns the most common response that occurred at least as many times as
 // specified in the minRes method.
-func (r *rpcETHAPI) BlockNumber() (any, error) {
-	ctx, ctxCancel := context.WithTimeout(context.Background(), r.handler.totalTimeout)
+func (r *rpcETHAPI) BlockNumber(ctx context.Context) (any, error) {
+	ctx, ctxCancel := context.WithTimeout(ctx, r.handler.totalTimeout)
 	defer ctxCancel()
 
 	res := &types.Number{}
@@ -131,8 +191,8 @@ func (r *rpcETHAPI) BlockNumber() (any, error) {
 //
 // The number returned by this method is the median of all numbers returned
 // by the endpoints.
-func (r *rpcETHAPI) GetBlockByHash(blockHash types.Hash, obj bool) (any, error) {
-	ctx, ctxCancel := context.WithTimeout(context.Background(), r.handler.totalTimeout)
+func (r *rpcETHAPI) GetBlockByHash(ctx context.Context, blockHash types.Hash, obj bool) (any, error) {
+	ctx, ctxCancel := context.WithTimeout(ctx, r.handler.totalTimeout)
 	defer ctxCancel()
 
 	var res any
@@ -151,8 +211,8 @@ func (r *rpcETHAPI) GetBlockByHash(blockHash types.Hash, obj bool) (any, error)
 //
 // It returns the most common response that occurred at least as many times as
 // specified in the minRes method.
-func (r *rpcETHAPI) GetBlockByNumber(blockNumber types.Number, obj bool) (any, error) {
-	ctx, ctxCancel := context.WithTimeout(context.Background(), r.handler.totalTimeout)
+func (r *rpcETHAPI) GetBlockByNumber(ctx context.Context, blockNumber types.Number, obj bool) (any, error) {
+	ctx, ctxCancel := context.WithTimeout(ctx, r.handler.totalTimeout)
 	defer ctxCancel()
 
 	var res any
@@ -171,8 +231,8 @@ func (r *rpcETHAPI) GetBlockByNumber(blockNumber types.Number, obj bool) (any, e
 //
 // It returns the most common response that occurred at least as many times as
 // specified in the minRes method.
-func (r *rpcETHAPI) GetTransactionByHash(txHash types.Hash) (any, error) {
-	ctx, ctxCancel := context.WithTimeout(context.Background(), r.handler.totalTimeout)
+func (r *rpcETHAPI) GetTransactionByHash(ctx context.Context, txHash types.Hash) (any, error) {
+	ctx, ctxCancel := context.WithTimeout(ctx, r.handler.totalTimeout)
 	defer ctxCancel()
 
 	res := &types.Transaction{}
@@ -189,8 +249,8 @@ func (r *rpcETHAPI) GetTransactionByHash(txHash types.Hash) (any, error) {
 // If the block number is set to "latest" or "pending", it will be replaced by
 // the block number returned by the BlockNumber method. The "earliest" tag is
 // not supported.
-func (r *rpcETHAPI) GetTransactionCount(addr types.Address, blockID types.BlockNumber) (any, error) {
-	ctx, ctxCancel := context.WithTimeout(context.Background(), r.handler.totalTimeout)
+func (r *rpcETHAPI) GetTransactionCount(ctx context.Context, addr types.Address, blockID types.BlockNumber) (any, error) {
+	ctx, ctxCancel := context.WithTimeout(ctx, r.handler.totalTimeout)
 	defer ctxCancel()
 
 	blockNumber, err := r.handler.taggedBlockToNumber(ctx, blockID)
@@ -207,8 +267,8 @@ func (r *rpcETHAPI) GetTransactionCount(addr types.Address, blockID types.BlockN
 //
 // It returns the most common response that occurred at least as many times as
 // specified in the minRes method.
-func (r *rpcETHAPI) GetTransactionReceipt(txHash types.Hash) (any, error) {
-	ctx, ctxCancel := context.WithTimeout(context.Background(), r.handler.totalTimeout)
+func (r *rpcETHAPI) GetTransactionReceipt(ctx context.Context, txHash types.Hash) (any, error) {
+	ctx, ctxCancel := context.WithTimeout(ctx, r.handler.totalTimeout)
 	defer ctxCancel()
 
 	res := &types.TransactionReceiptType{}
@@ -225,8 +285,8 @@ func (r *rpcETHAPI) GetTransactionReceipt(txHash types.Hash) (any, error) {
 // SendRawTransaction implements the "eth_sendRawTransaction" call.
 //
 // It returns the most common response.
-func (r *rpcETHAPI) SendRawTransaction(data types.Bytes) (any, error) {
-	ctx, ctxCancel := context.WithTimeout(context.Background(), r.handler.totalTimeout)
+func (r *rpcETHAPI) SendRawTransaction(ctx context.Context, data types.Bytes) (any, error) {
+	ctx, ctxCancel := context.WithTimeout(ctx, r.handler.totalTimeout)
 	defer ctxCancel()
 
 	res := &types.Hash{}
@@ -243,8 +303,8 @@ func (r *rpcETHAPI) SendRawTransaction(data types.Bytes) (any, error) {
 // If the block number is set to "latest" or "pending", it will be replaced by
 // the block number returned by the BlockNumber method. The "earliest" tag is
 // not supported.
-func (r *rpcETHAPI) GetBalance(addr types.Address, blockID types.BlockNumber) (any, error) {
-	ctx, ctxCancel := context.WithTimeout(context.Background(), r.handler.totalTimeout)
+func (r *rpcETHAPI) GetBalance(ctx context.Context, addr types.Address, blockID types.BlockNumber) (any, error) {
+	ctx, ctxCancel := context.WithTimeout(ctx, r.handler.totalTimeout)
 	defer ctxCancel()
 
 	blockNumber, err := r.handler.taggedBlockToNumber(ctx, blockID)
@@ -265,8 +325,8 @@ func (r *rpcETHAPI) GetBalance(addr types.Address, blockID types.BlockNumber) (a
 // If the block number is set to "latest" or "pending", it will be replaced by
 // the block number returned by the BlockNumber method. The "earliest" tag is
 // not supported.
-func (r *rpcETHAPI) GetCode(addr types.Address, blockID types.BlockNumber) (any, error) {
-	ctx, ctxCancel := context.WithTimeout(context.Background(), r.handler.totalTimeout)
+func (r *rpcETHAPI) GetCode(ctx context.Context, addr types.Address, blockID types.BlockNumber) (any, error) {
+	ctx, ctxCancel := context.WithTimeout(ctx, r.handler.totalTimeout)
 	defer ctxCancel()
 
 	blockNumber, err := r.handler.taggedBlockToNumber(ctx, blockID)
@@ -287,8 +347,8 @@ func (r *rpcETHAPI) GetCode(addr types.Address, blockID types.BlockNumber) (any,
 // If the block number is set to "latest" or "pending", it will be replaced by
 // the block number returned by the BlockNumber method. The "earliest" tag is
 // not supported.
-func (r *rpcETHAPI) GetStorageAt(data types.Address, pos types.Number, blockID types.BlockNumber) (any, error) {
-	ctx, ctxCancel := context.WithTimeout(context.Background(), r.handler.totalTimeout)
+func (r *rpcETHAPI) GetStorageAt(ctx context.Context, data types.Address, pos types.Number, blockID types.BlockNumber) (any, error) {
+	ctx, ctxCancel := context.WithTimeout(ctx, r.handler.totalTimeout)
 	defer ctxCancel()
 
 	blockNumber, err := r.handler.taggedBlockToNumber(ctx, blockID)
@@ -312,8 +372,8 @@ func (r *rpcETHAPI) GetStorageAt(data types.Address, pos types.Number, blockID t
 // If the block number is set to "latest" or "pending", it will be replaced by
 // the block number returned by the BlockNumber method. The "earliest" tag is
 // not supported.
-func (r *rpcETHAPI) Call(args Any, blockID types.BlockNumber, overrides *Any) (any, error) {
-	ctx, ctxCancel := context.WithTimeout(context.Background(), r.handler.totalTimeout)
+func (r *rpcETHAPI) Call(ctx context.Context, args Any, blockID types.BlockNumber, overrides *Any) (any, error) {
+	ctx, ctxCancel := context.WithTimeout(ctx, r.handler.totalTimeout)
 	defer ctxCancel()
 
 	blockNumber, err := r.handler.taggedBlockToNumber(ctx, blockID)
@@ -334,8 +394,8 @@ func (r *rpcETHAPI) Call(args Any, blockID types.BlockNumber, overrides *Any) (a
 // If the block number is set to "latest" or "pending", it will be replaced by
 // the block number returned by the BlockNumber method. The "earliest" tag is
 // not supported.
-func (r *rpcETHAPI) GetLogs(logFilter types.FilterLogsQuery) (any, error) {
-	ctx, ctxCancel := context.WithTimeout(context.Background(), r.handler.totalTimeout)
+func (r *rpcETHAPI) GetLogs(ctx context.Context, logFilter types.FilterLogsQuery) (any, error) {
+	ctx, ctxCancel := context.WithTimeout(ctx, r.handler.totalTimeout)
 	defer ctxCancel()
 
 	if logFilter.FromBlock != nil {
@@ -363,13 +423,13 @@ func (r *rpcETHAPI) GetLogs(logFilter types.FilterLogsQuery) (any, error) {
 // GasPrice implements the "eth_gasPrice" call.
 //
 // The number returned by this method is the median of all numbers returned
-// by the endpoints.
-func (r *rpcETHAPI) GasPrice() (any, error) {
-	ctx, ctxCancel := context.WithTimeout(context.Background(), r.handler.totalTimeout)
+// by the endpoints, unless a different gas strategy is set.
+func (r *rpcETHAPI) GasPrice(ctx context.Context) (any, error) {
+	ctx, ctxCancel := context.WithTimeout(ctx, r.handler.totalTimeout)
 	defer ctxCancel()
 
 	res := &types.Number{}
-	err := r.handler.call(ctx, r.handler.gasValueResolver, res, "eth_gasPrice")
+	err := r.handler.call(ctx, r.handler.resolver("eth_gasPrice", r.handler.gasValueResolver), res, "eth_gasPrice")
 
 	return res, err
 }
@@ -377,13 +437,13 @@ func (r *rpcETHAPI) GasPrice() (any, error) {
 // EstimateGas implements the "eth_estimateGas" call.
 //
 // The number returned by this method is the median of all numbers returned
-// by the endpoints.
+// by the endpoints, unless a different gas strategy is set.
 //
 // If the block number is set to "latest" or "pending", it will be replaced by
 // the block number returned by the BlockNumber method. The "earliest" tag is
 // not supported.
-func (r *rpcETHAPI) EstimateGas(args Any, blockID types.BlockNumber) (any, error) {
-	ctx, ctxCancel := context.WithTimeout(context.Background(), r.handler.totalTimeout)
+func (r *rpcETHAPI) EstimateGas(ctx context.Context, args Any, blockID types.BlockNumber) (any, error) {
+	ctx, ctxCancel := context.WithTimeout(ctx, r.handler.totalTimeout)
 	defer ctxCancel()
 
 	blockNumber, err := r.handler.taggedBlockToNumber(ctx, blockID)
@@ -391,7 +451,7 @@ func (r *rpcETHAPI) EstimateGas(args Any, blockID types.BlockNumber) (any, error
 		return nil, err
 	}
 	res := &types.Number{}
-	err = r.handler.call(ctx, r.handler.gasValueResolver, res, "eth_estimateGas", args, blockNumber)
+	err = r.handler.call(ctx, r.handler.resolver("eth_estimateGas", r.handler.gasValueResolver), res, "eth_estimateGas", args, blockNumber)
 
 	return res, err
 }
@@ -399,9 +459,10 @@ func (r *rpcETHAPI) EstimateGas(args Any, blockID types.BlockNumber) (any, error
 // FeeHistory implements the "eth_feeHistory" call.
 //
 // It returns the most common response that occurred at least as many times as
-// specified in the minRes method.
-func (r *rpcETHAPI) FeeHistory(count types.Number, newestBlockID types.BlockNumber, percentiles Any) (any, error) {
-	ctx, ctxCancel := context.WithTimeout(context.Background(), r.handler.totalTimeout)
+// specified in the minRes method. If a gas strategy is set for this method,
+// the base fee and reward arrays are aggregated using the strategy.
+func (r *rpcETHAPI) FeeHistory(ctx context.Context, count types.Number, newestBlockID types.BlockNumber, percentiles Any) (any, error) {
+	ctx, ctxCancel := context.WithTimeout(ctx, r.handler.totalTimeout)
 	defer ctxCancel()
 
 	blockNumber, err := r.handler.taggedBlockToNumber(ctx, newestBlockID)
@@ -409,7 +470,7 @@ func (r *rpcETHAPI) FeeHistory(count types.Number, newestBlockID types.BlockNumb
 		return nil, err
 	}
 	res := &types.FeeHistory{}
-	err = r.handler.call(ctx, r.handler.defaultResolver, res, "eth_feeHistory", count, blockNumber, percentiles)
+	err = r.handler.call(ctx, r.handler.resolver("eth_feeHistory", r.handler.defaultResolver), res, "eth_feeHistory", count, blockNumber, percentiles)
 
 	return res, err
 }
@@ -417,13 +478,13 @@ func (r *rpcETHAPI) FeeHistory(count types.Number, newestBlockID types.BlockNumb
 // MaxPriorityFeePerGas implements the "eth_maxPriorityFeePerGas" call.
 //
 // The number returned by this method is the median of all numbers returned
-// by the endpoints.
-func (r *rpcETHAPI) MaxPriorityFeePerGas() (any, error) {
-	ctx, ctxCancel := context.WithTimeout(context.Background(), r.handler.totalTimeout)
+// by the endpoints, unless a different gas strategy is set.
+func (r *rpcETHAPI) MaxPriorityFeePerGas(ctx context.Context) (any, error) {
+	ctx, ctxCancel := context.WithTimeout(ctx, r.handler.totalTimeout)
 	defer ctxCancel()
 
 	res := &types.Number{}
-	err := r.handler.call(ctx, r.handler.gasValueResolver, res, "eth_maxPriorityFeePerGas")
+	err := r.handler.call(ctx, r.handler.resolver("eth_maxPriorityFeePerGas", r.handler.gasValueResolver), res, "eth_maxPriorityFeePerGas")
 
 	return res, err
 }
@@ -432,8 +493,8 @@ func (r *rpcETHAPI) MaxPriorityFeePerGas() (any, error) {
 //
 // It returns the most common response that occurred at least as many times as
 // specified in the minRes method.
-func (r *rpcETHAPI) ChainId() (any, error) { //nolint:revive,stylecheck
-	ctx, ctxCancel := context.WithTimeout(context.Background(), r.handler.totalTimeout)
+func (r *rpcETHAPI) ChainId(ctx context.Context) (any, error) { //nolint:revive,stylecheck
+	ctx, ctxCancel := context.WithTimeout(ctx, r.handler.totalTimeout)
 	defer ctxCancel()
 
 	res := &types.Number{}
@@ -457,8 +518,8 @@ func (r *rpcETHAPI) ChainId() (any, error) { //nolint:revive,stylecheck
 //
 // It returns the most common response that occurred at least as many times as
 // specified in the minRes method.
-func (r *rpcNETAPI) Version() (any, error) {
-	ctx, ctxCancel := context.WithTimeout(context.Background(), r.handler.totalTimeout)
+func (r *rpcNETAPI) Version(ctx context.Context) (any, error) {
+	ctx, ctxCancel := context.WithTimeout(ctx, r.handler.totalTimeout)
 	defer ctxCancel()
 
 	res := &Any{}
@@ -467,6 +528,15 @@ func (r *rpcNETAPI) Version() (any, error) {
 	return res, err
 }
 
+// resolver returns a resolver configured for the given method. If there is
+// no such resolver, the def resolver is returned.
+func (s *server) resolver(method string, def resolver) resolver {
+	if r, ok := s.methodResolvers[method]; ok {
+		return r
+	}
+	return def
+}
+
 // taggedBlockToNumber returns a block number for tagged blocks. This is
 // necessary because different RPC endpoints may convert tags to different
 // block numbers.
@@ -508,10 +578,26 @@ func (s *server) call(
 		return fmt.Errorf("call result parameter must be pointer")
 	}
 
+	// Attach the request ID and the trace context to logs and requests sent
+	// to endpoints.
+	logger := s.log
+	headers := http.Header{}
+	if id := requestid.FromContext(ctx); id != "" {
+		logger = logger.WithField("requestID", id)
+		headers.Set(RequestIDHeader, id)
+	}
+	if trace, ok := requestid.TraceFromContext(ctx); ok {
+		logger = logger.WithField("traceID", trace.TraceID)
+		headers.Set(requestid.TraceparentHeader, trace.Traceparent())
+	}
+	if len(headers) > 0 {
+		ctx = gethRPC.NewContextWithHeaders(ctx, headers)
+	}
+
 	// Recover from panics.
 	defer func() {
 		if r := recover(); r != nil {
-			s.log.
+			logger.
 				WithField("method", method).
 				// WithField("args", args).
 				WithError(fmt.Errorf("panic: %s", r)).
@@ -520,7 +606,7 @@ func (s *server) call(
 	}()
 
 	// Send request to all endpoints.
-	ch := make(chan any, len(s.callers))
+	ch := make(chan endpointResponse, len(s.callers))
 	rt := reflect.TypeOf(result).Elem()
 	for n, c := range s.callers {
 		n, c := n, c
@@ -534,22 +620,22 @@ func (s *server) call(
 				}
 				switch {
 				case err != nil:
-					s.log.
+					logger.
 						WithField("name", n).
 						WithField("method", method).
 						WithField("args", args).
 						WithField("duration", time.Since(t)).
 						WithError(err).
 						Debug("Call error")
-					ch <- err
+					ch <- endpointResponse{endpoint: n, response: err}
 				default:
-					s.log.
+					logger.
 						WithField("name", n).
 						WithField("method", method).
 						WithField("args", args).
 						WithField("duration", time.Since(t)).
 						Debug("Call")
-					ch <- res
+					ch <- endpointResponse{endpoint: n, response: res}
 				}
 			}()
 			res = reflect.New(rt).Interface()
@@ -562,12 +648,16 @@ func (s *server) call(
 	// and the response returned.
 	t := time.NewTimer(s.gracefulTimeout)
 	defer t.Stop()
-	var rs []any
+	var (
+		rs []any
+		es []string
+	)
 	for {
 		wait := true
 		select {
 		case r := <-ch:
-			rs = append(rs, r)
+			rs = append(rs, r.response)
+			es = append(es, r.endpoint)
 		case <-t.C:
 			wait = false
 		}
@@ -575,7 +665,7 @@ func (s *server) call(
 			wait = false
 		}
 		if !wait {
-			res, err := resolver.resolve(rs)
+			res, err := resolver.resolve(rs, es)
 			switch {
 			case err == nil:
 				reflect.ValueOf(result).Elem().Set(reflect.ValueOf(res).Elem())
@@ -587,6 +677,12 @@ func (s *server) call(
 	}
 }
 
+// endpointResponse is a response or an error returned by a single endpoint.
+type endpointResponse struct {
+	endpoint string
+	response any
+}
+
 // removeTrailingNilArgs removes trailing nil parameters from the params
 // slice. Some RPC servers do not like null parameters and will return a
 // "bad request" error if they occur.
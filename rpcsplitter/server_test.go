@@ -16,11 +16,23 @@
 package rpcsplitter
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/chronicleprotocol/go-utils/requestid"
 	"github.com/chronicleprotocol/go-utils/rpcsplitter/types"
 )
 
@@ -1214,6 +1226,29 @@ func Test_RPC_FeeHistory(t *testing.T) {
 			expectedError("").
 			test()
 	})
+	t.Run("gas-strategy", func(t *testing.T) {
+		fh := func(baseFee, reward string, ratio float64) json.RawMessage {
+			return json.RawMessage(fmt.Sprintf(
+				`{"oldestBlock":"0x10","baseFeePerGas":["%s"],"gasUsedRatio":[%v],"reward":[["%s"]]}`,
+				baseFee, ratio, reward,
+			))
+		}
+		prepareHandlerTest(t, 3, "eth_feeHistory", blockCount, newestBlock, percentiles).
+			setOptions(WithRequirements(2, 10), WithGasStrategy(MedianStrategy(), "eth_feeHistory")).
+			mockClientCall(0, fh("0x1", "0x4", 0.1), "eth_feeHistory", blockCount, newestBlock, percentiles).
+			mockClientCall(1, fh("0x2", "0x5", 0.2), "eth_feeHistory", blockCount, newestBlock, percentiles).
+			mockClientCall(2, fh("0x3", "0x6", 0.3), "eth_feeHistory", blockCount, newestBlock, percentiles).
+			expectedResult(fh("0x2", "0x5", 0.2)).
+			test()
+	})
+	t.Run("gas-strategy-shape-tie", func(t *testing.T) {
+		prepareHandlerTest(t, 2, "eth_feeHistory", blockCount, newestBlock, percentiles).
+			setOptions(WithRequirements(1, 10), WithGasStrategy(MedianStrategy(), "eth_feeHistory")).
+			mockClientCall(0, json.RawMessage(`{"oldestBlock":"0x10","baseFeePerGas":["0x1"],"gasUsedRatio":[0.1],"reward":[["0x1"]]}`), "eth_feeHistory", blockCount, newestBlock, percentiles).
+			mockClientCall(1, json.RawMessage(`{"oldestBlock":"0x11","baseFeePerGas":["0x1"],"gasUsedRatio":[0.1],"reward":[["0x1"]]}`), "eth_feeHistory", blockCount, newestBlock, percentiles).
+			expectedError("different responses").
+			test()
+	})
 	t.Run("latest-block", func(t *testing.T) {
 		prepareHandlerTest(t, 2, "eth_feeHistory", blockCount, types.StringToBlockNumber("latest"), percentiles).
 			setOptions(WithRequirements(2, 10)).
@@ -1291,6 +1326,44 @@ func Test_RPC_MaxPriorityFeePerGas(t *testing.T) {
 	})
 }
 
+func Test_RPC_GasStrategy(t *testing.T) {
+	t.Run("percentile", func(t *testing.T) {
+		prepareHandlerTest(t, 4, "eth_maxPriorityFeePerGas").
+			setOptions(WithRequirements(3, 10), WithGasStrategy(PercentileStrategy(75), "eth_maxPriorityFeePerGas")).
+			mockClientCall(0, `0x1`, "eth_maxPriorityFeePerGas").
+			mockClientCall(1, `0x5`, "eth_maxPriorityFeePerGas").
+			mockClientCall(2, `0x3`, "eth_maxPriorityFeePerGas").
+			mockClientCall(3, `0x4`, "eth_maxPriorityFeePerGas").
+			expectedResult(`0x4`).
+			test()
+	})
+	t.Run("max-spread-exceeded", func(t *testing.T) {
+		prepareHandlerTest(t, 3, "eth_gasPrice").
+			setOptions(WithRequirements(2, 10), WithGasStrategy(OutlierRejectionStrategy(0.1, nil))).
+			mockClientCall(0, `0x10`, "eth_gasPrice").
+			mockClientCall(1, `0x20`, "eth_gasPrice").
+			mockClientCall(2, `0x40`, "eth_gasPrice").
+			expectedError("differ too much").
+			test()
+	})
+	t.Run("unsupported-method", func(t *testing.T) {
+		_, err := NewServer(withCallers(map[string]caller{}), WithRequirements(1, 1), WithGasStrategy(MedianStrategy(), "eth_chainId"))
+		require.Error(t, err)
+	})
+	t.Run("invalid-strategy", func(t *testing.T) {
+		_, err := NewServer(withCallers(map[string]caller{}), WithRequirements(1, 1), WithGasStrategy(PercentileStrategy(101)))
+		require.ErrorContains(t, err, "invalid percentile")
+	})
+	t.Run("default-methods", func(t *testing.T) {
+		s, err := newServer(withCallers(map[string]caller{}), WithRequirements(1, 1), WithGasStrategy(MedianStrategy()))
+		require.NoError(t, err)
+		assert.Contains(t, s.methodResolvers, "eth_gasPrice")
+		assert.Contains(t, s.methodResolvers, "eth_estimateGas")
+		assert.Contains(t, s.methodResolvers, "eth_maxPriorityFeePerGas")
+		assert.NotContains(t, s.methodResolvers, "eth_feeHistory")
+	})
+}
+
 func Test_RPC_ChainId(t *testing.T) {
 	t.Run("simple", func(t *testing.T) {
 		prepareHandlerTest(t, 3, "eth_chainId").
@@ -1369,6 +1442,48 @@ func Test_RPC_Version(t *testing.T) {
 	})
 }
 
+func Test_RPC_Weights(t *testing.T) {
+	t.Run("trusted-endpoint", func(t *testing.T) {
+		prepareHandlerTest(t, 3, "eth_chainId").
+			setOptions(WithRequirements(2, 10), WithWeights(map[string]int{"0": 3}, 3)).
+			mockClientCall(0, `0x1`, "eth_chainId").
+			mockClientCall(1, `0x2`, "eth_chainId").
+			mockClientCall(2, `0x2`, "eth_chainId").
+			expectedResult(`0x1`).
+			test()
+	})
+	t.Run("conflict-policy", func(t *testing.T) {
+		prepareHandlerTest(t, 4, "eth_chainId").
+			setOptions(WithRequirements(2, 10), WithConflictPolicy(TrustedEndpointPolicy("0", 1))).
+			mockClientCall(0, `0x1`, "eth_chainId").
+			mockClientCall(1, `0x1`, "eth_chainId").
+			mockClientCall(2, `0x2`, "eth_chainId").
+			mockClientCall(3, `0x2`, "eth_chainId").
+			expectedResult(`0x1`).
+			test()
+	})
+	t.Run("not-enough-weight", func(t *testing.T) {
+		prepareHandlerTest(t, 3, "eth_chainId").
+			setOptions(WithRequirements(1, 10), WithWeights(map[string]int{"0": 2}, 4)).
+			mockClientCall(0, `0x1`, "eth_chainId").
+			mockClientCall(1, `0x2`, "eth_chainId").
+			mockClientCall(2, `0x2`, "eth_chainId").
+			expectedError("").
+			test()
+	})
+	t.Run("unknown-endpoint", func(t *testing.T) {
+		_, err := NewServer(withCallers(map[string]caller{"0": nil}), WithRequirements(1, 10), WithWeights(map[string]int{"1": 2}, 2))
+		require.Error(t, err)
+	})
+	t.Run("weights-copied", func(t *testing.T) {
+		weights := map[string]int{"0": 2}
+		s, err := newServer(withCallers(map[string]caller{"0": nil}), WithRequirements(1, 10), WithWeights(weights, 2))
+		require.NoError(t, err)
+		weights["0"] = 5
+		assert.Equal(t, 2, s.defaultResolver.weight("0"))
+	})
+}
+
 func Test_RPC_Timeout(t *testing.T) {
 	t.Run("total-timeout", func(t *testing.T) {
 		prepareHandlerTest(t, 3, "eth_blockNumber").
@@ -1390,6 +1505,92 @@ func Test_RPC_Timeout(t *testing.T) {
 	})
 }
 
+func Test_RPC_RequestID(t *testing.T) {
+	// Upstream server that records the request ID header.
+	var upstreamID, upstreamTraceparent atomic.Value
+	upstream := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		upstreamID.Store(r.Header.Get(RequestIDHeader))
+		upstreamTraceparent.Store(r.Header.Get(requestid.TraceparentHeader))
+		req := &rpcReq{}
+		jsonUnmarshal(t, readAll(t, r.Body), req)
+		rw.Header().Set("Content-Type", "application/json")
+		_, _ = rw.Write(jsonMarshal(t, map[string]any{"jsonrpc": "2.0", "id": req.ID, "result": "0x1"}))
+	}))
+	defer upstream.Close()
+
+	h, err := NewServer(WithEndpoints([]string{upstream.URL}), WithRequirements(1, 1))
+	require.NoError(t, err)
+
+	t.Run("from-header", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(jsonMarshal(t, rpcReq{ID: 1, JSONRPC: "2.0", Method: "eth_chainId"})))
+		r.Header.Set("Content-Type", "application/json")
+		r.Header.Set(RequestIDHeader, "foo")
+		rw := httptest.NewRecorder()
+		h.ServeHTTP(rw, r)
+		assert.Equal(t, "foo", rw.Header().Get(RequestIDHeader))
+		assert.Equal(t, "foo", upstreamID.Load())
+	})
+	t.Run("generated", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(jsonMarshal(t, rpcReq{ID: 1, JSONRPC: "2.0", Method: "eth_chainId"})))
+		r.Header.Set("Content-Type", "application/json")
+		rw := httptest.NewRecorder()
+		h.ServeHTTP(rw, r)
+		assert.NotEmpty(t, rw.Header().Get(RequestIDHeader))
+		assert.Equal(t, rw.Header().Get(RequestIDHeader), upstreamID.Load())
+	})
+	t.Run("invalid-header", func(t *testing.T) {
+		for _, id := range []string{"foo bar", "foo\x00", strings.Repeat("a", 129)} {
+			r := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(jsonMarshal(t, rpcReq{ID: 1, JSONRPC: "2.0", Method: "eth_chainId"})))
+			r.Header.Set("Content-Type", "application/json")
+			r.Header.Set(RequestIDHeader, id)
+			rw := httptest.NewRecorder()
+			h.ServeHTTP(rw, r)
+			assert.Len(t, rw.Header().Get(RequestIDHeader), 32)
+			assert.Equal(t, rw.Header().Get(RequestIDHeader), upstreamID.Load())
+		}
+	})
+	t.Run("context", func(t *testing.T) {
+		trace := requestid.Trace{
+			TraceID:  "4bf92f3577b34da6a3ce929d0e0e4736",
+			ParentID: "00f067aa0ba902b7",
+			SpanID:   "b7ad6b7169203331",
+			Flags:    1,
+		}
+		ctx := requestid.WithTrace(requestid.WithRequestID(context.Background(), "bar"), trace)
+		r := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(jsonMarshal(t, rpcReq{ID: 1, JSONRPC: "2.0", Method: "eth_chainId"}))).WithContext(ctx)
+		r.Header.Set("Content-Type", "application/json")
+		r.Header.Set(RequestIDHeader, "foo")
+		rw := httptest.NewRecorder()
+		h.ServeHTTP(rw, r)
+		assert.Equal(t, "bar", rw.Header().Get(RequestIDHeader))
+		assert.Equal(t, "bar", upstreamID.Load())
+		assert.Equal(t, trace.Traceparent(), upstreamTraceparent.Load())
+	})
+}
+
+func Test_RPC_ClientDisconnect(t *testing.T) {
+	client := &mockClient{t: t}
+	client.mockSlowCall(time.Second, `0x1`, "eth_chainId")
+	h, err := NewServer(withCallers(map[string]caller{"0": client}), WithRequirements(1, 1))
+	require.NoError(t, err)
+
+	ctx, ctxCancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer ctxCancel()
+
+	r := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(jsonMarshal(t, rpcReq{ID: 1, JSONRPC: "2.0", Method: "eth_chainId"})))
+	r.Header.Set("Content-Type", "application/json")
+	rw := httptest.NewRecorder()
+	start := time.Now()
+	h.ServeHTTP(rw, r.WithContext(ctx))
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+}
+
+func readAll(t *testing.T, r io.Reader) []byte {
+	b, err := io.ReadAll(r)
+	require.NoError(t, err)
+	return b
+}
+
 func newAny(j string) *Any {
 	t := &Any{}
 	if err := t.UnmarshalJSON([]byte(j)); err != nil {
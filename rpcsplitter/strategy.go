@@ -0,0 +1,273 @@
+//  Copyright (C) 2021-2023 Chronicle Labs, Inc.
+//
+//  This program is free software: you can redistribute it and/or modify
+//  it under the terms of the GNU Affero General Public License as
+//  published by the Free Software Foundation, either version 3 of the
+//  License, or (at your option) any later version.
+//
+//  This program is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of
+//  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU Affero General Public License for more details.
+//
+//  You should have received a copy of the GNU Affero General Public License
+//  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package rpcsplitter
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"sort"
+)
+
+var errGasSpreadTooHigh = errors.New("gas values returned by RPC servers differ too much")
+
+// GasStrategy calculates a single gas value from the values returned by
+// different endpoints.
+//
+// If the strategy also implements the Validate() error method, it is called
+// by the WithGasStrategy option, so invalid parameters are reported when
+// the server is created.
+type GasStrategy interface {
+	// GasValue returns the gas value. The values slice is never empty and
+	// must not be modified.
+	GasValue(values []*big.Int) (*big.Int, error)
+}
+
+// GasStrategyFunc is an adapter to allow the use of ordinary functions as
+// a GasStrategy.
+type GasStrategyFunc func(values []*big.Int) (*big.Int, error)
+
+// GasValue implements the GasStrategy interface.
+func (f GasStrategyFunc) GasValue(values []*big.Int) (*big.Int, error) {
+	return f(values)
+}
+
+// gasStrategyValidator is implemented by strategies with parameters that
+// may be invalid.
+type gasStrategyValidator interface {
+	Validate() error
+}
+
+// validateGasStrategy validates the strategy if it implements
+// the gasStrategyValidator interface.
+func validateGasStrategy(strategy GasStrategy) error {
+	if v, ok := strategy.(gasStrategyValidator); ok {
+		return v.Validate()
+	}
+	return nil
+}
+
+// MedianStrategy returns a strategy that calculates the gas value depending
+// on the number of values:
+// * one value: returns value as is
+// * two values: returns the lowest one
+// * three or more values: returns the median value
+//
+// This is the default strategy.
+func MedianStrategy() GasStrategy {
+	return medianStrategy{}
+}
+
+type medianStrategy struct{}
+
+func (medianStrategy) GasValue(values []*big.Int) (*big.Int, error) {
+	if len(values) == 2 {
+		// With two correct answers, it is safer to return the lower value.
+		// Otherwise, the compromised endpoint may return a very high gas
+		// price. If this price is used to determine transaction fees, it
+		// could cause clients to lose money on transaction fees.
+		if values[0].Cmp(values[1]) > 0 {
+			return values[1], nil
+		}
+		return values[0], nil
+	}
+	return median(sortedCopy(values)), nil
+}
+
+// TrimmedMeanStrategy returns a strategy that removes the given fraction of
+// the lowest and the highest values and returns the mean of the remaining
+// ones. The fraction must be in the range [0, 0.5).
+func TrimmedMeanStrategy(trim float64) GasStrategy {
+	return trimmedMeanStrategy{trim: trim}
+}
+
+type trimmedMeanStrategy struct {
+	trim float64
+}
+
+func (s trimmedMeanStrategy) Validate() error {
+	if !(s.trim >= 0 && s.trim < 0.5) {
+		return fmt.Errorf("invalid trim fraction: %f", s.trim)
+	}
+	return nil
+}
+
+func (s trimmedMeanStrategy) GasValue(values []*big.Int) (*big.Int, error) {
+	v := sortedCopy(values)
+	n := int(math.Floor(float64(len(v)) * s.trim))
+	v = v[n : len(v)-n]
+	sum := new(big.Int)
+	for _, x := range v {
+		sum.Add(sum, x)
+	}
+	return sum.Div(sum, big.NewInt(int64(len(v)))), nil
+}
+
+// PercentileStrategy returns a strategy that returns the value at the given
+// percentile using the nearest-rank method. The percentile must be in the
+// range [0, 100].
+func PercentileStrategy(p float64) GasStrategy {
+	return percentileStrategy{p: p}
+}
+
+type percentileStrategy struct {
+	p float64
+}
+
+func (s percentileStrategy) Validate() error {
+	if !(s.p >= 0 && s.p <= 100) {
+		return fmt.Errorf("invalid percentile: %f", s.p)
+	}
+	return nil
+}
+
+func (s percentileStrategy) GasValue(values []*big.Int) (*big.Int, error) {
+	v := sortedCopy(values)
+	rank := int(math.Ceil(s.p / 100 * float64(len(v))))
+	if rank < 1 {
+		rank = 1
+	}
+	return v[rank-1], nil
+}
+
+// BoundedMinStrategy returns a strategy that returns the lowest value whose
+// relative deviation from the median does not exceed maxDeviation. For
+// example, 0.1 means that values lower than 90% of the median are ignored.
+// The deviation must not be negative.
+func BoundedMinStrategy(maxDeviation float64) GasStrategy {
+	return boundedStrategy{maxDeviation: maxDeviation}
+}
+
+// BoundedMaxStrategy returns a strategy that returns the highest value whose
+// relative deviation from the median does not exceed maxDeviation. For
+// example, 0.1 means that values higher than 110% of the median are ignored.
+// The deviation must not be negative.
+func BoundedMaxStrategy(maxDeviation float64) GasStrategy {
+	return boundedStrategy{maxDeviation: maxDeviation, max: true}
+}
+
+type boundedStrategy struct {
+	maxDeviation float64
+	max          bool // return the highest value instead of the lowest one
+}
+
+func (s boundedStrategy) Validate() error {
+	return validateDeviation(s.maxDeviation)
+}
+
+func (s boundedStrategy) GasValue(values []*big.Int) (*big.Int, error) {
+	v, err := withinDeviation(values, s.maxDeviation)
+	if err != nil {
+		return nil, err
+	}
+	if s.max {
+		return v[len(v)-1], nil
+	}
+	return v[0], nil
+}
+
+// OutlierRejectionStrategy returns a strategy that rejects values whose
+// relative deviation from the median exceeds maxSpread and calculates the
+// gas value from the remaining values using the next strategy. If the
+// remaining values are not the majority, an error is returned. If next is
+// nil, MedianStrategy is used. The spread must not be negative.
+func OutlierRejectionStrategy(maxSpread float64, next GasStrategy) GasStrategy {
+	if next == nil {
+		next = MedianStrategy()
+	}
+	return outlierRejectionStrategy{maxSpread: maxSpread, next: next}
+}
+
+type outlierRejectionStrategy struct {
+	maxSpread float64
+	next      GasStrategy
+}
+
+func (s outlierRejectionStrategy) Validate() error {
+	if err := validateDeviation(s.maxSpread); err != nil {
+		return err
+	}
+	return validateGasStrategy(s.next)
+}
+
+func (s outlierRejectionStrategy) GasValue(values []*big.Int) (*big.Int, error) {
+	v, err := withinDeviation(values, s.maxSpread)
+	if err != nil {
+		return nil, err
+	}
+	if len(v)*2 <= len(values) {
+		return nil, errGasSpreadTooHigh
+	}
+	return s.next.GasValue(v)
+}
+
+// validateDeviation returns an error if the deviation is negative or NaN.
+func validateDeviation(d float64) error {
+	if !(d >= 0) {
+		return fmt.Errorf("invalid deviation: %f", d)
+	}
+	return nil
+}
+
+// withinDeviation returns sorted values whose relative deviation from the
+// median does not exceed maxDeviation.
+func withinDeviation(values []*big.Int, maxDeviation float64) ([]*big.Int, error) {
+	s := sortedCopy(values)
+	m := median(s)
+	var r []*big.Int
+	for _, v := range s {
+		if deviation(v, m) <= maxDeviation {
+			r = append(r, v)
+		}
+	}
+	if len(r) == 0 {
+		return nil, errGasSpreadTooHigh
+	}
+	return r, nil
+}
+
+// deviation returns the relative deviation of x from m.
+func deviation(x, m *big.Int) float64 {
+	d := new(big.Int).Sub(x, m)
+	if m.Sign() == 0 {
+		if d.Sign() == 0 {
+			return 0
+		}
+		return math.Inf(1)
+	}
+	f, _ := new(big.Float).Quo(new(big.Float).SetInt(d.Abs(d)), new(big.Float).SetInt(m)).Float64()
+	return math.Abs(f)
+}
+
+// median returns the median of sorted values.
+func median(s []*big.Int) *big.Int {
+	if len(s)%2 == 0 {
+		m := len(s) / 2
+		return new(big.Int).Div(new(big.Int).Add(s[m-1], s[m]), big.NewInt(2))
+	}
+	return s[len(s)/2]
+}
+
+// sortedCopy returns a sorted copy of values.
+func sortedCopy(values []*big.Int) []*big.Int {
+	s := make([]*big.Int, len(values))
+	copy(s, values)
+	sort.Slice(s, func(i, j int) bool {
+		return s[i].Cmp(s[j]) < 0
+	})
+	return s
+}
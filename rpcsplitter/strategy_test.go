@@ -0,0 +1,79 @@
+//  Copyright (C) 2021-2023 Chronicle Labs, Inc.
+//
+//  This program is free software: you can redistribute it and/or modify
+//  it under the terms of the GNU Affero General Public License as
+//  published by the Free Software Foundation, either version 3 of the
+//  License, or (at your option) any later version.
+//
+//  This program is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of
+//  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU Affero General Public License for more details.
+//
+//  You should have received a copy of the GNU Affero General Public License
+//  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package rpcsplitter
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGasStrategies(t *testing.T) {
+	tests := []struct {
+		strategy GasStrategy
+		values   []int64
+		want     int64
+		wantErr  bool
+	}{
+		{strategy: MedianStrategy(), values: []int64{1}, want: 1},
+		{strategy: MedianStrategy(), values: []int64{2, 1}, want: 1},
+		{strategy: MedianStrategy(), values: []int64{3, 1, 2}, want: 2},
+		{strategy: MedianStrategy(), values: []int64{2, 2, 4, 4}, want: 3},
+		{strategy: TrimmedMeanStrategy(0), values: []int64{1, 2, 3, 6}, want: 3},
+		{strategy: TrimmedMeanStrategy(0.25), values: []int64{1, 2, 4, 100}, want: 3},
+		{strategy: TrimmedMeanStrategy(0.2), values: []int64{1, 2, 4, 100}, want: 26},
+		{strategy: TrimmedMeanStrategy(0.5), values: []int64{1, 2}, wantErr: true},
+		{strategy: PercentileStrategy(0), values: []int64{3, 1, 2}, want: 1},
+		{strategy: PercentileStrategy(50), values: []int64{4, 1, 3, 2}, want: 2},
+		{strategy: PercentileStrategy(90), values: []int64{4, 1, 3, 2}, want: 4},
+		{strategy: PercentileStrategy(100), values: []int64{4, 1, 3, 2}, want: 4},
+		{strategy: PercentileStrategy(101), values: []int64{1}, wantErr: true},
+		{strategy: BoundedMinStrategy(0.1), values: []int64{50, 95, 100, 105, 200}, want: 95},
+		{strategy: BoundedMaxStrategy(0.1), values: []int64{50, 95, 100, 105, 200}, want: 105},
+		{strategy: BoundedMaxStrategy(0.1), values: []int64{1, 100}, wantErr: true},
+		{strategy: BoundedMinStrategy(-1), values: []int64{1, 2}, wantErr: true},
+		{strategy: OutlierRejectionStrategy(0.1, nil), values: []int64{1, 100, 102, 104, 1000}, want: 102},
+		{strategy: OutlierRejectionStrategy(0.1, BoundedMaxStrategy(1)), values: []int64{1, 100, 102, 104, 1000}, want: 104},
+		{strategy: OutlierRejectionStrategy(0.1, nil), values: []int64{1, 100, 200, 1000}, wantErr: true},
+		{strategy: OutlierRejectionStrategy(0.1, PercentileStrategy(-1)), values: []int64{1}, wantErr: true},
+		{strategy: TrimmedMeanStrategy(math.NaN()), values: []int64{1}, wantErr: true},
+		{strategy: BoundedMaxStrategy(math.NaN()), values: []int64{1}, wantErr: true},
+		{strategy: GasStrategyFunc(func(values []*big.Int) (*big.Int, error) { return values[0], nil }), values: []int64{7, 1}, want: 7},
+	}
+	for n, tt := range tests {
+		t.Run(fmt.Sprintf("case-%d", n), func(t *testing.T) {
+			values := make([]*big.Int, len(tt.values))
+			for i, v := range tt.values {
+				values[i] = big.NewInt(v)
+			}
+			err := validateGasStrategy(tt.strategy)
+			var v *big.Int
+			if err == nil {
+				v, err = tt.strategy.GasValue(values)
+			}
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, v.Int64())
+		})
+	}
+}
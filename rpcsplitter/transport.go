@@ -22,44 +22,52 @@ import (
 	"net/http"
 )
 
-// Transport implements the http.RoundTripper interface. It creates a virtual
-// host with RPC Splitter.
+// Transport implements the http.RoundTripper interface. It creates virtual
+// hosts with RPC Splitter instances. Requests to other hosts are passed to
+// the underlying transport.
 type Transport struct {
 	transport http.RoundTripper
-	server    http.Handler
-	vhost     string
+	servers   map[string]http.Handler
 }
 
-// NewTransport returns a new instance of Transport.
+// NewTransport returns a new instance of Transport with a single virtual host.
 func NewTransport(vhost string, transport http.RoundTripper, opts ...Option) (*Transport, error) {
+	return NewMultiTransport(map[string][]Option{vhost: opts}, transport)
+}
+
+// NewMultiTransport returns a new instance of Transport with multiple virtual
+// hosts. The keys of the vhosts map are virtual host names and values are
+// options for separate RPC Splitter instances, e.g. "mainnet.splitter" and
+// "arbitrum.splitter" may use endpoints of different chains.
+func NewMultiTransport(vhosts map[string][]Option, transport http.RoundTripper) (*Transport, error) {
 	if transport == nil {
 		transport = http.DefaultTransport
 	}
-	rpcServer, err := NewServer(opts...)
-	if err != nil {
-		return nil, err
+	servers := make(map[string]http.Handler, len(vhosts))
+	for vhost, opts := range vhosts {
+		rpcServer, err := NewServer(opts...)
+		if err != nil {
+			return nil, fmt.Errorf("%s virtual host: %w", vhost, err)
+		}
+		servers[vhost] = rpcServer
 	}
 	return &Transport{
 		transport: transport,
-		vhost:     vhost,
-		server:    rpcServer,
+		servers:   servers,
 	}, nil
 }
 
 // RoundTrip implements the http.RoundTripper interface.
 func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
-	if !t.isVirtualHost(req) {
+	server, ok := t.servers[req.Host]
+	if !ok {
 		return t.transport.RoundTrip(req)
 	}
 	rec := newRecorder()
-	t.server.ServeHTTP(rec, req)
+	server.ServeHTTP(rec, req)
 	return t.buildResponse(rec), nil
 }
 
-func (t *Transport) isVirtualHost(req *http.Request) bool {
-	return req.Host == t.vhost
-}
-
 func (t *Transport) buildResponse(res *recorder) *http.Response {
 	return &http.Response{
 		Status:        fmt.Sprintf("%d %s", res.code, http.StatusText(res.code)),
@@ -53,3 +53,40 @@ func TestTransport(t *testing.T) {
 	require.NoError(t, err)
 	require.JSONEq(t, `{"jsonrpc":"2.0","id":1,"result":1}`, string(body))
 }
+
+func TestMultiTransport(t *testing.T) {
+	mainnetMock := &mockClient{t: t}
+	arbitrumMock := &mockClient{t: t}
+	roundTripper, err := NewMultiTransport(
+		map[string][]Option{
+			"mainnet.splitter": {
+				withCallers(map[string]caller{"caller": mainnetMock}),
+				WithRequirements(1, 1),
+			},
+			"arbitrum.splitter": {
+				withCallers(map[string]caller{"caller": arbitrumMock}),
+				WithRequirements(1, 1),
+			},
+		},
+		nil,
+	)
+	require.NoError(t, err)
+	httpClient := http.Client{Transport: roundTripper}
+	msg := jsonMarshal(t, rpcReq{
+		ID:      1,
+		JSONRPC: "2.0",
+		Method:  "eth_chainId",
+		Params:  nil,
+	})
+
+	mainnetMock.mockCall(`0x1`, "eth_chainId")
+	arbitrumMock.mockCall(`0xa4b1`, "eth_chainId")
+
+	for vhost, chainID := range map[string]string{"mainnet.splitter": "0x1", "arbitrum.splitter": "0xa4b1"} {
+		res, err := httpClient.Post("http://"+vhost, "application/json", bytes.NewReader(msg))
+		require.NoError(t, err)
+		body, _ := io.ReadAll(res.Body)
+		_ = res.Body.Close()
+		require.JSONEq(t, `{"jsonrpc":"2.0","id":1,"result":"`+chainID+`"}`, string(body))
+	}
+}
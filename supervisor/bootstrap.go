@@ -0,0 +1,198 @@
+//  Copyright (C) 2021-2023 Chronicle Labs, Inc.
+//
+//  This program is free software: you can redistribute it and/or modify
+//  it under the terms of the GNU Affero General Public License as
+//  published by the Free Software Foundation, either version 3 of the
+//  License, or (at your option) any later version.
+//
+//  This program is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of
+//  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU Affero General Public License for more details.
+//
+//  You should have received a copy of the GNU Affero General Public License
+//  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package supervisor
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/sirupsen/logrus"
+
+	utilHCL "github.com/chronicleprotocol/go-utils/hcl"
+	"github.com/chronicleprotocol/go-utils/hcl/ext/include"
+	"github.com/chronicleprotocol/go-utils/hcl/ext/variables"
+	"github.com/chronicleprotocol/go-utils/log"
+	logrusLogger "github.com/chronicleprotocol/go-utils/log/logrus"
+	"github.com/chronicleprotocol/go-utils/log/logrus/formatter"
+)
+
+// MaxIncludeDepth is the maximum depth of nested includes in configuration
+// files loaded by LoadConfig.
+const MaxIncludeDepth = 10
+
+// LoggerConfig is the configuration of the logger, decoded from the "logger"
+// block:
+//
+//	logger {
+//	  verbosity = "info"
+//	  format    = "text"
+//	}
+type LoggerConfig struct {
+	// Verbosity is the log level: panic, error, warning, info or debug.
+	// The default is info.
+	Verbosity string `hcl:"verbosity,optional"`
+
+	// Format is the log format: text or json. The default is text.
+	Format string `hcl:"format,optional"`
+}
+
+// Logger returns a logger configured using the LoggerConfig. If the receiver
+// is nil, the default configuration is used.
+func (c *LoggerConfig) Logger() (log.Logger, error) {
+	l := logrus.New()
+	l.SetLevel(logrus.InfoLevel)
+	l.SetFormatter(&formatter.FieldSerializerFormatter{
+		Formatter: &formatter.XFilterFormatter{Formatter: &logrus.TextFormatter{}},
+	})
+	if c == nil {
+		return logrusLogger.New(l), nil
+	}
+	if c.Verbosity != "" {
+		lvl, err := logrus.ParseLevel(c.Verbosity)
+		if err != nil {
+			return nil, err
+		}
+		l.SetLevel(lvl)
+	}
+	switch c.Format {
+	case "", "text":
+	case "json":
+		l.SetFormatter(&formatter.FieldSerializerFormatter{
+			Formatter:         &formatter.JSONFormatter{},
+			UseJSONRawMessage: true,
+		})
+	default:
+		return nil, fmt.Errorf("unsupported log format: %s", c.Format)
+	}
+	return logrusLogger.New(l), nil
+}
+
+// bootstrapConfig is used to extract the "logger" block from the
+// configuration before decoding the rest of it into the user config.
+type bootstrapConfig struct {
+	Logger *LoggerConfig `hcl:"logger,block,optional"`
+	Remain hcl.Body      `hcl:",remain"`
+}
+
+// LoadConfig loads the configuration from the given HCL files and decodes it
+// into cfg, which must be a pointer to a struct.
+//
+// Files may use the "include" attribute to include other files relative to
+// their directory and the "variables" block to define variables available
+// as "var.name". The "logger" block is decoded into the returned
+// LoggerConfig, it is nil if the block is not present.
+func LoadConfig(paths []string, cfg any) (*LoggerConfig, hcl.Diagnostics) {
+	loggerCfg, _, diags := loadConfig(paths, cfg)
+	return loggerCfg, diags
+}
+
+// loadConfig works like LoadConfig, but it also returns the paths of all
+// files and directories that were read while loading the configuration,
+// including files that were not found.
+func loadConfig(paths []string, cfg any) (*LoggerConfig, []string, hcl.Diagnostics) {
+	if len(paths) == 0 {
+		return nil, nil, hcl.Diagnostics{{
+			Severity: hcl.DiagError,
+			Summary:  "Missing configuration",
+			Detail:   "At least one configuration file must be provided.",
+		}}
+	}
+	var (
+		bodies []hcl.Body
+		read   []string
+	)
+	for _, path := range paths {
+		read = append(read, path)
+		body, diags := utilHCL.ParseFile(path, nil)
+		if diags.HasErrors() {
+			return nil, read, diags
+		}
+		dir := filepath.Dir(path)
+		fsys := &recordFS{fs: os.DirFS(dir), dir: dir, paths: &read}
+		body, diags = include.Include(&hcl.EvalContext{}, fsys, body, MaxIncludeDepth)
+		if diags.HasErrors() {
+			return nil, read, diags
+		}
+		bodies = append(bodies, body)
+	}
+	ctx := &hcl.EvalContext{}
+	body, diags := variables.Variables(ctx, hcl.MergeBodies(bodies))
+	if diags.HasErrors() {
+		return nil, read, diags
+	}
+	var bc bootstrapConfig
+	if diags := utilHCL.Decode(ctx, body, &bc); diags.HasErrors() {
+		return nil, read, diags
+	}
+	if diags := utilHCL.Decode(ctx, bc.Remain, cfg); diags.HasErrors() {
+		return nil, read, diags
+	}
+	return bc.Logger, read, nil
+}
+
+// recordFS is a fs.FS that records paths of all opened files and
+// directories.
+type recordFS struct {
+	fs    fs.FS
+	dir   string
+	paths *[]string
+}
+
+// Open implements the fs.FS interface.
+func (r *recordFS) Open(name string) (fs.File, error) {
+	*r.paths = append(*r.paths, filepath.Join(r.dir, filepath.FromSlash(name)))
+	return r.fs.Open(name)
+}
+
+// Bootstrap loads the configuration from the given HCL files into cfg
+// using LoadConfig, builds the logger from the "logger" block, creates
+// services using the Config.Services method and runs them using
+// the Supervisor.Run method. It blocks until all services are stopped.
+//
+// It is intended to replace the boilerplate code in the main function
+// of applications:
+//
+//	func main() {
+//		if err := supervisor.Bootstrap(context.Background(), &config{}, "app", "1.0.0", os.Args[1:]...); err != nil {
+//			fmt.Fprintln(os.Stderr, err)
+//			os.Exit(1)
+//		}
+//	}
+func Bootstrap(ctx context.Context, cfg Config, appName, appVersion string, paths ...string) error {
+	loggerCfg, diags := LoadConfig(paths, cfg)
+	if diags.HasErrors() {
+		return diags
+	}
+	logger, err := loggerCfg.Logger()
+	if err != nil {
+		return err
+	}
+	srv, err := cfg.Services(logger, appName, appVersion)
+	if err != nil {
+		return err
+	}
+	sup := New(logger)
+	sup.Watch(srv)
+	logger.
+		WithField("name", appName).
+		WithField("version", appVersion).
+		Info("Starting application")
+	return sup.Run(ctx)
+}
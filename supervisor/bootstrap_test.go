@@ -0,0 +1,161 @@
+//  Copyright (C) 2021-2023 Chronicle Labs, Inc.
+//
+//  This program is free software: you can redistribute it and/or modify
+//  it under the terms of the GNU Affero General Public License as
+//  published by the Free Software Foundation, either version 3 of the
+//  License, or (at your option) any later version.
+//
+//  This program is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of
+//  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU Affero General Public License for more details.
+//
+//  You should have received a copy of the GNU Affero General Public License
+//  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package supervisor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/chronicleprotocol/go-utils/log"
+)
+
+type bootstrapTestConfig struct {
+	Name    string   `hcl:"name"`
+	Servers []string `hcl:"servers,optional"`
+
+	services func(logger log.Logger) (Service, error)
+}
+
+func (c *bootstrapTestConfig) Services(logger log.Logger, _ string, _ string) (Service, error) {
+	return c.services(logger)
+}
+
+// writeConfigFile writes the file atomically, so that the config reloader
+// never reads a partially written file. The temporary file is created
+// outside the config directory to not affect the directory listing.
+func writeConfigFile(t *testing.T, path, content string) {
+	f, err := os.CreateTemp("", "config")
+	require.NoError(t, err)
+	_, err = f.WriteString(content)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	require.NoError(t, os.Rename(f.Name(), path))
+}
+
+func TestLoadConfig(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "conf.d"), 0o700))
+	writeConfigFile(t, filepath.Join(dir, "config.hcl"), `
+		include = ["conf.d/*.hcl"]
+
+		variables {
+			servers = ["a", "b"]
+		}
+
+		name    = "test"
+		servers = var.servers
+	`)
+	writeConfigFile(t, filepath.Join(dir, "conf.d", "logger.hcl"), `
+		logger {
+			verbosity = "debug"
+		}
+	`)
+
+	var cfg bootstrapTestConfig
+	loggerCfg, diags := LoadConfig([]string{filepath.Join(dir, "config.hcl")}, &cfg)
+	require.False(t, diags.HasErrors(), diags.Error())
+	require.NotNil(t, loggerCfg)
+	assert.Equal(t, "debug", loggerCfg.Verbosity)
+	assert.Equal(t, "test", cfg.Name)
+	assert.Equal(t, []string{"a", "b"}, cfg.Servers)
+
+	logger, err := loggerCfg.Logger()
+	require.NoError(t, err)
+	assert.Equal(t, log.Debug, logger.Level())
+}
+
+func TestLoadConfig_Errors(t *testing.T) {
+	dir := t.TempDir()
+	writeConfigFile(t, filepath.Join(dir, "invalid.hcl"), `unknown = 1`)
+
+	tests := []struct {
+		paths []string
+	}{
+		{paths: nil},
+		{paths: []string{filepath.Join(dir, "missing.hcl")}},
+		{paths: []string{filepath.Join(dir, "invalid.hcl")}},
+	}
+	for n, tt := range tests {
+		t.Run(fmt.Sprintf("case-%d", n+1), func(t *testing.T) {
+			var cfg bootstrapTestConfig
+			_, diags := LoadConfig(tt.paths, &cfg)
+			assert.True(t, diags.HasErrors())
+		})
+	}
+}
+
+func TestLoggerConfig_Logger(t *testing.T) {
+	tests := []struct {
+		cfg     *LoggerConfig
+		level   log.Level
+		wantErr bool
+	}{
+		{cfg: nil, level: log.Info},
+		{cfg: &LoggerConfig{Verbosity: "warning", Format: "json"}, level: log.Warn},
+		{cfg: &LoggerConfig{Verbosity: "invalid"}, wantErr: true},
+		{cfg: &LoggerConfig{Format: "invalid"}, wantErr: true},
+	}
+	for n, tt := range tests {
+		t.Run(fmt.Sprintf("case-%d", n+1), func(t *testing.T) {
+			logger, err := tt.cfg.Logger()
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.level, logger.Level())
+		})
+	}
+}
+
+func TestBootstrap(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.hcl")
+	writeConfigFile(t, path, `name = "test"`)
+
+	t.Run("run", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		s := &service{waitCh: make(chan error)}
+		cfg := &bootstrapTestConfig{services: func(log.Logger) (Service, error) {
+			return s, nil
+		}}
+		errCh := make(chan error, 1)
+		go func() { errCh <- Bootstrap(ctx, cfg, "app", "1.0.0", path) }()
+		assert.Eventually(t, s.Started, time.Second, 10*time.Millisecond)
+		assert.Equal(t, "test", cfg.Name)
+		cancel()
+		select {
+		case err := <-errCh:
+			require.NoError(t, err)
+		case <-time.After(time.Second):
+			require.Fail(t, "Bootstrap should return")
+		}
+	})
+
+	t.Run("services-error", func(t *testing.T) {
+		cfg := &bootstrapTestConfig{services: func(log.Logger) (Service, error) {
+			return nil, errors.New("services err")
+		}}
+		require.EqualError(t, Bootstrap(context.Background(), cfg, "app", "1.0.0", path), "services err")
+	})
+}
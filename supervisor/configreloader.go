@@ -0,0 +1,299 @@
+//  Copyright (C) 2021-2023 Chronicle Labs, Inc.
+//
+//  This program is free software: you can redistribute it and/or modify
+//  it under the terms of the GNU Affero General Public License as
+//  published by the Free Software Foundation, either version 3 of the
+//  License, or (at your option) any later version.
+//
+//  This program is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of
+//  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU Affero General Public License for more details.
+//
+//  You should have received a copy of the GNU Affero General Public License
+//  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package supervisor
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/chronicleprotocol/go-utils/log"
+	"github.com/chronicleprotocol/go-utils/log/null"
+)
+
+const ConfigReloaderLoggerTag = "CONFIG_RELOADER"
+
+const (
+	defaultDebounce     = time.Second
+	defaultPollInterval = 5 * time.Second
+)
+
+// fileWatcher reports changes in watched directories.
+type fileWatcher interface {
+	// Events returns a channel that receives a value when a change is
+	// detected. Multiple changes may be reported as a single value.
+	Events() <-chan struct{}
+
+	// Close stops watching.
+	Close() error
+}
+
+// ConfigReloaderConfig is a configuration for the ConfigReloader function.
+type ConfigReloaderConfig struct {
+	// Paths is a list of configuration files. Files are loaded using
+	// the LoadConfig function.
+	Paths []string
+
+	// Config returns a new, empty configuration instance every time
+	// the configuration is loaded.
+	Config func() Config
+
+	// AppName and AppVersion are passed to the Config.Services method.
+	AppName    string
+	AppVersion string
+
+	// Debounce is the time to wait after the last detected change before
+	// the configuration is reloaded. The default is 1 second.
+	Debounce time.Duration
+
+	// PollInterval is the interval at which files are checked for changes
+	// if inotify is not available or if Poll is true. The default is
+	// 5 seconds.
+	PollInterval time.Duration
+
+	// Poll forces polling instead of inotify.
+	Poll bool
+
+	// Logger is a logger instance. It is passed to the Config.Services
+	// method. The "logger" block in the configuration is ignored.
+	Logger log.Logger
+}
+
+// ConfigReloader returns a ReloaderFn that creates a service from
+// the configuration files and creates a new one every time the files,
+// including the ones added by the "include" attribute, are changed.
+//
+// If the initial configuration is invalid, the ReloaderFn returns an error.
+// If the configuration becomes invalid later, errors are logged and the
+// current service keeps running. A reload can also be requested using
+// the Reloader.Reload method.
+func ConfigReloader(cfg ConfigReloaderConfig) ReloaderFn {
+	if cfg.Logger == nil {
+		cfg.Logger = null.New()
+	}
+	if cfg.Debounce <= 0 {
+		cfg.Debounce = defaultDebounce
+	}
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = defaultPollInterval
+	}
+	return func(ctx context.Context, serviceCh chan<- Service) error {
+		if cfg.Config == nil {
+			return errors.New("config reloader: config function must not be nil")
+		}
+		r := &configReloader{
+			cfg: cfg,
+			log: cfg.Logger.WithField("tag", ConfigReloaderLoggerTag),
+		}
+		return r.run(ctx, serviceCh)
+	}
+}
+
+type configReloader struct {
+	cfg      ConfigReloaderConfig
+	log      log.Logger
+	paths    map[string]bool   // Files and directories read while loading the configuration.
+	snapshot map[string][]byte // Checksums of the paths for the last loaded configuration.
+	watcher  fileWatcher
+	dirs     []string
+}
+
+func (r *configReloader) run(ctx context.Context, serviceCh chan<- Service) error {
+	r.paths = make(map[string]bool)
+	defer r.closeWatcher()
+
+	// Initial load.
+	service, err := r.load()
+	if err != nil {
+		return err
+	}
+	select {
+	case serviceCh <- service:
+	case <-ctx.Done():
+		return nil
+	}
+	r.watch()
+
+	var (
+		debounceCh <-chan time.Time
+		debounce   *time.Timer
+	)
+	poll := time.NewTicker(r.cfg.PollInterval)
+	defer poll.Stop()
+	for {
+		// Use either inotify events or polling, depending on whether
+		// the watcher is available.
+		var (
+			eventCh <-chan struct{}
+			pollCh  <-chan time.Time
+		)
+		if r.watcher != nil {
+			eventCh = r.watcher.Events()
+		} else {
+			pollCh = poll.C
+		}
+		force := false
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-eventCh:
+			if debounce == nil {
+				debounce = time.NewTimer(r.cfg.Debounce)
+			} else {
+				debounce.Reset(r.cfg.Debounce)
+			}
+			debounceCh = debounce.C
+			continue
+		case <-pollCh:
+		case <-debounceCh:
+			debounceCh = nil
+		case <-ReloadSignal(ctx):
+			force = true
+		}
+		if !force && !r.changed() {
+			continue
+		}
+		r.log.Info("Configuration changed, reloading")
+		service, err := r.load()
+		r.watch()
+		if err != nil {
+			r.log.
+				WithError(err).
+				WithAdvice("Fix the configuration, the current service will keep running until then").
+				Error("Invalid configuration")
+			continue
+		}
+		select {
+		case serviceCh <- service:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// load loads the configuration and creates a new service. Paths read while
+// loading the configuration are added to the watched paths and
+// the snapshot is updated.
+func (r *configReloader) load() (Service, error) {
+	cfg := r.cfg.Config()
+	_, paths, diags := loadConfig(r.cfg.Paths, cfg)
+	for _, path := range paths {
+		if abs, err := filepath.Abs(path); err == nil {
+			r.paths[abs] = true
+		}
+	}
+	r.snapshot = r.checksums()
+	if diags.HasErrors() {
+		return nil, diags
+	}
+	return cfg.Services(r.cfg.Logger, r.cfg.AppName, r.cfg.AppVersion)
+}
+
+// changed returns true if any of the watched paths changed since
+// the last load.
+func (r *configReloader) changed() bool {
+	current := r.checksums()
+	if len(current) != len(r.snapshot) {
+		return true
+	}
+	for path, sum := range current {
+		if string(r.snapshot[path]) != string(sum) {
+			return true
+		}
+	}
+	return false
+}
+
+// checksums returns checksums of the file contents or, for directories,
+// of the directory listings. Missing paths have a nil checksum.
+func (r *configReloader) checksums() map[string][]byte {
+	sums := make(map[string][]byte, len(r.paths))
+	for path := range r.paths {
+		sums[path] = checksum(path)
+	}
+	return sums
+}
+
+// watch starts watching directories containing the watched paths. If
+// the set of directories has not changed, the current watcher is reused.
+// If inotify is not available, the watcher remains nil and polling is used.
+func (r *configReloader) watch() {
+	if r.cfg.Poll {
+		return
+	}
+	set := make(map[string]bool)
+	for path := range r.paths {
+		set[filepath.Dir(path)] = true
+		if fi, err := os.Stat(path); err == nil && fi.IsDir() {
+			set[path] = true
+		}
+	}
+	dirs := make([]string, 0, len(set))
+	for dir := range set {
+		dirs = append(dirs, dir)
+	}
+	slices.Sort(dirs)
+	if r.watcher != nil && slices.Equal(dirs, r.dirs) {
+		return
+	}
+	r.closeWatcher()
+	w, err := newFileWatcher(dirs)
+	if err != nil {
+		r.log.
+			WithError(err).
+			WithField("interval", r.cfg.PollInterval).
+			Warn("Unable to watch configuration files, falling back to polling")
+		r.cfg.Poll = true
+		return
+	}
+	r.watcher, r.dirs = w, dirs
+}
+
+func (r *configReloader) closeWatcher() {
+	if r.watcher != nil {
+		_ = r.watcher.Close()
+		r.watcher = nil
+	}
+}
+
+func checksum(path string) []byte {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil
+	}
+	h := sha256.New()
+	if fi.IsDir() {
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return nil
+		}
+		for _, e := range entries {
+			h.Write([]byte(e.Name()))
+			h.Write([]byte{0})
+		}
+		return h.Sum(nil)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	h.Write(b)
+	return h.Sum(nil)
+}
@@ -0,0 +1,199 @@
+//  Copyright (C) 2021-2023 Chronicle Labs, Inc.
+//
+//  This program is free software: you can redistribute it and/or modify
+//  it under the terms of the GNU Affero General Public License as
+//  published by the Free Software Foundation, either version 3 of the
+//  License, or (at your option) any later version.
+//
+//  This program is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of
+//  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU Affero General Public License for more details.
+//
+//  You should have received a copy of the GNU Affero General Public License
+//  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package supervisor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/chronicleprotocol/go-utils/log"
+	"github.com/chronicleprotocol/go-utils/log/callback"
+)
+
+type reloadTestConfig struct {
+	Name string `hcl:"name"`
+
+	services *reloadTestServices
+}
+
+func (c *reloadTestConfig) Services(_ log.Logger, _ string, _ string) (Service, error) {
+	return c.services.add(c.Name), nil
+}
+
+type reloadTestServices struct {
+	mu       sync.Mutex
+	names    []string
+	services []*service
+}
+
+func (r *reloadTestServices) add(name string) *service {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s := &service{waitCh: make(chan error)}
+	r.names = append(r.names, name)
+	r.services = append(r.services, s)
+	return s
+}
+
+func (r *reloadTestServices) last() (string, *service) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.services) == 0 {
+		return "", nil
+	}
+	return r.names[len(r.names)-1], r.services[len(r.services)-1]
+}
+
+func (r *reloadTestServices) count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.services)
+}
+
+// running returns the name of the last created service if it is running.
+func (r *reloadTestServices) running() string {
+	name, s := r.last()
+	if s == nil || !s.Started() {
+		return ""
+	}
+	return name
+}
+
+type logRecorder struct {
+	mu   sync.Mutex
+	msgs []string
+}
+
+func (l *logRecorder) logger() log.Logger {
+	return callback.New(log.Debug, func(_ log.Level, _ log.Fields, msg string) {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		l.msgs = append(l.msgs, msg)
+	})
+}
+
+func (l *logRecorder) has(msg string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, m := range l.msgs {
+		if m == msg {
+			return true
+		}
+	}
+	return false
+}
+
+func TestConfigReloader(t *testing.T) {
+	for _, poll := range []bool{false, true} {
+		name := "inotify"
+		if poll {
+			name = "poll"
+		}
+		t.Run(name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			dir := t.TempDir()
+			confDir := filepath.Join(dir, "conf.d")
+			require.NoError(t, os.Mkdir(confDir, 0o700))
+			path := filepath.Join(dir, "config.hcl")
+			writeConfigFile(t, path, `
+				include = ["conf.d/*.hcl"]
+				name    = "v1"
+			`)
+
+			services := &reloadTestServices{}
+			logs := &logRecorder{}
+			r := NewReloader(ReloaderConfig{
+				Factory: ConfigReloader(ConfigReloaderConfig{
+					Paths: []string{path},
+					Config: func() Config {
+						return &reloadTestConfig{services: services}
+					},
+					Debounce:     10 * time.Millisecond,
+					PollInterval: 10 * time.Millisecond,
+					Poll:         poll,
+					Logger:       logs.logger(),
+				}),
+			})
+			require.NoError(t, r.Start(ctx))
+			assert.Eventually(t, func() bool {
+				return services.running() == "v1"
+			}, time.Second, 10*time.Millisecond)
+
+			// Change the main file.
+			writeConfigFile(t, path, `
+				include = ["conf.d/*.hcl"]
+				name    = "v2"
+			`)
+			assert.Eventually(t, func() bool {
+				return services.running() == "v2"
+			}, time.Second, 10*time.Millisecond)
+
+			// Invalid configuration keeps the current service running.
+			writeConfigFile(t, filepath.Join(confDir, "invalid.hcl"), `unknown = true`)
+			assert.Eventually(t, func() bool {
+				return logs.has("Invalid configuration")
+			}, time.Second, 10*time.Millisecond)
+			assert.Equal(t, "v2", services.running())
+
+			// Removing the invalid file, which was added by the glob pattern,
+			// fixes the configuration.
+			require.NoError(t, os.Remove(filepath.Join(confDir, "invalid.hcl")))
+			assert.Eventually(t, func() bool {
+				return services.count() == 3 && services.running() == "v2"
+			}, time.Second, 10*time.Millisecond)
+
+			// Reload requested using the Reload method.
+			r.Reload()
+			assert.Eventually(t, func() bool {
+				return services.count() == 4 && services.running() == "v2"
+			}, time.Second, 10*time.Millisecond)
+			assert.Equal(t, 4, r.Generation())
+		})
+	}
+}
+
+func TestConfigReloader_InvalidInitialConfig(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	path := filepath.Join(t.TempDir(), "config.hcl")
+	writeConfigFile(t, path, `unknown = true`)
+
+	r := NewReloader(ReloaderConfig{
+		Factory: ConfigReloader(ConfigReloaderConfig{
+			Paths: []string{path},
+			Config: func() Config {
+				return &reloadTestConfig{services: &reloadTestServices{}}
+			},
+		}),
+	})
+	require.NoError(t, r.Start(ctx))
+	select {
+	case err := <-r.Wait():
+		require.Error(t, err)
+	case <-time.After(time.Second):
+		require.Fail(t, "Wait() channel should not be blocked")
+	}
+}
@@ -0,0 +1,89 @@
+//  Copyright (C) 2021-2023 Chronicle Labs, Inc.
+//
+//  This program is free software: you can redistribute it and/or modify
+//  it under the terms of the GNU Affero General Public License as
+//  published by the Free Software Foundation, either version 3 of the
+//  License, or (at your option) any later version.
+//
+//  This program is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of
+//  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU Affero General Public License for more details.
+//
+//  You should have received a copy of the GNU Affero General Public License
+//  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+//go:build linux
+
+package supervisor
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+const inotifyMask = syscall.IN_CREATE |
+	syscall.IN_DELETE |
+	syscall.IN_MODIFY |
+	syscall.IN_CLOSE_WRITE |
+	syscall.IN_MOVED_FROM |
+	syscall.IN_MOVED_TO |
+	syscall.IN_ATTRIB |
+	syscall.IN_DELETE_SELF |
+	syscall.IN_MOVE_SELF
+
+// inotifyWatcher reports changes in directories using inotify.
+type inotifyWatcher struct {
+	file    *os.File
+	eventCh chan struct{}
+}
+
+// newFileWatcher returns a watcher that reports changes in the given
+// directories. Directories that do not exist are ignored.
+func newFileWatcher(dirs []string) (fileWatcher, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, os.NewSyscallError("inotify_init1", err)
+	}
+	// Because the file descriptor is non-blocking, the os.File uses
+	// the runtime poller, so Close unblocks pending reads.
+	file := os.NewFile(uintptr(fd), "inotify")
+	for _, dir := range dirs {
+		if _, err := syscall.InotifyAddWatch(fd, dir, inotifyMask); err != nil {
+			if errors.Is(err, syscall.ENOENT) || errors.Is(err, syscall.ENOTDIR) {
+				continue
+			}
+			_ = file.Close()
+			return nil, os.NewSyscallError("inotify_add_watch", err)
+		}
+	}
+	w := &inotifyWatcher{file: file, eventCh: make(chan struct{}, 1)}
+	go w.readRoutine()
+	return w, nil
+}
+
+// Events implements the fileWatcher interface.
+func (w *inotifyWatcher) Events() <-chan struct{} {
+	return w.eventCh
+}
+
+// Close implements the fileWatcher interface.
+func (w *inotifyWatcher) Close() error {
+	return w.file.Close()
+}
+
+func (w *inotifyWatcher) readRoutine() {
+	// Event details are not needed, because the configuration is compared
+	// with the previous one after every change.
+	buf := make([]byte, 64*1024)
+	for {
+		if _, err := w.file.Read(buf); err != nil {
+			return
+		}
+		select {
+		case w.eventCh <- struct{}{}:
+		default:
+		}
+	}
+}
@@ -0,0 +1,28 @@
+//  Copyright (C) 2021-2023 Chronicle Labs, Inc.
+//
+//  This program is free software: you can redistribute it and/or modify
+//  it under the terms of the GNU Affero General Public License as
+//  published by the Free Software Foundation, either version 3 of the
+//  License, or (at your option) any later version.
+//
+//  This program is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of
+//  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU Affero General Public License for more details.
+//
+//  You should have received a copy of the GNU Affero General Public License
+//  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+//go:build !linux
+
+package supervisor
+
+import (
+	"errors"
+)
+
+// newFileWatcher returns an error because inotify is not available on
+// this platform. The polling is used instead.
+func newFileWatcher(_ []string) (fileWatcher, error) {
+	return nil, errors.New("file watching is not supported on this platform")
+}
@@ -0,0 +1,149 @@
+//  Copyright (C) 2021-2023 Chronicle Labs, Inc.
+//
+//  This program is free software: you can redistribute it and/or modify
+//  it under the terms of the GNU Affero General Public License as
+//  published by the Free Software Foundation, either version 3 of the
+//  License, or (at your option) any later version.
+//
+//  This program is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of
+//  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU Affero General Public License for more details.
+//
+//  You should have received a copy of the GNU Affero General Public License
+//  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package supervisor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sync"
+)
+
+// PanicError is an error created from a recovered panic.
+type PanicError struct {
+	// Value is the value passed to panic.
+	Value any
+
+	// Stack is the stack trace of the goroutine that panicked.
+	Stack []byte
+}
+
+// Error implements the error interface. The stack trace is not included
+// in the message, it is available in the Stack field.
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.Value)
+}
+
+// Unwrap returns the panic value if it is an error.
+func (e *PanicError) Unwrap() error {
+	if err, ok := e.Value.(error); ok {
+		return err
+	}
+	return nil
+}
+
+// Group runs goroutines on behalf of a service. Panics in these goroutines
+// are recovered and converted into PanicError, so they are reported by the
+// Supervisor as a service crash instead of terminating the process.
+//
+// The channel returned by the Wait method follows the Service interface
+// contract, so it can be returned directly from the Wait method of
+// the service:
+//
+//	func (s *MyService) Start(ctx context.Context) error {
+//		s.group = supervisor.NewGroup(ctx)
+//		s.group.Go(s.worker)
+//		return nil
+//	}
+//
+//	func (s *MyService) Wait() <-chan error {
+//		return s.group.Wait()
+//	}
+type Group struct {
+	ctx       context.Context
+	ctxCancel context.CancelFunc
+	mu        sync.Mutex
+	wg        sync.WaitGroup
+	errCh     chan error
+	waitCh    chan error
+}
+
+// NewGroup returns a new Group. The context should be the one passed to
+// the Start method of the service. Goroutines receive a context that is
+// canceled when the parent context is canceled or when any goroutine
+// returns an error or panics.
+func NewGroup(ctx context.Context) *Group {
+	ctx, ctxCancel := context.WithCancel(ctx)
+	g := &Group{
+		ctx:       ctx,
+		ctxCancel: ctxCancel,
+		errCh:     make(chan error),
+		waitCh:    make(chan error),
+	}
+	go g.waitRoutine(ctx)
+	return g
+}
+
+// Go runs fn in a new goroutine. If fn returns an error other than
+// context.Canceled or panics, the error is sent to the Wait channel.
+// If the context is already canceled, fn is not called.
+func (g *Group) Go(fn func(ctx context.Context) error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.ctx.Err() != nil {
+		return
+	}
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		if err := g.run(fn); err != nil {
+			g.ctxCancel()
+			g.errCh <- err
+		}
+	}()
+}
+
+// Wait returns a channel that is closed when the context is canceled and all
+// goroutines have returned. Errors returned by goroutines are sent to
+// the channel before it is closed.
+func (g *Group) Wait() <-chan error {
+	return g.waitCh
+}
+
+func (g *Group) run(fn func(ctx context.Context) error) (err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			err = &PanicError{Value: v, Stack: debug.Stack()}
+		}
+	}()
+	if err := fn(g.ctx); err != nil && !errors.Is(err, context.Canceled) {
+		return err
+	}
+	return nil
+}
+
+func (g *Group) waitRoutine(ctx context.Context) {
+	defer close(g.waitCh)
+	doneCh := make(chan struct{})
+	go func() {
+		<-ctx.Done()
+		// Go checks the context under the lock, so after the lock is
+		// acquired here, no new goroutine can be added to the wait group.
+		g.mu.Lock()
+		g.mu.Unlock() //nolint:staticcheck // empty critical section is intended
+		g.wg.Wait()
+		close(doneCh)
+	}()
+	for {
+		select {
+		case err := <-g.errCh:
+			g.waitCh <- err
+		case <-doneCh:
+			return
+		}
+	}
+}
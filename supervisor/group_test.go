@@ -0,0 +1,172 @@
+//  Copyright (C) 2021-2023 Chronicle Labs, Inc.
+//
+//  This program is free software: you can redistribute it and/or modify
+//  it under the terms of the GNU Affero General Public License as
+//  published by the Free Software Foundation, either version 3 of the
+//  License, or (at your option) any later version.
+//
+//  This program is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of
+//  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU Affero General Public License for more details.
+//
+//  You should have received a copy of the GNU Affero General Public License
+//  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package supervisor
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/chronicleprotocol/go-utils/log"
+	"github.com/chronicleprotocol/go-utils/log/callback"
+)
+
+// groupService is a service that runs its goroutines using the Group.
+type groupService struct {
+	group *Group
+	fn    func(ctx context.Context) error
+}
+
+func (s *groupService) Start(ctx context.Context) error {
+	s.group = NewGroup(ctx)
+	s.group.Go(s.fn)
+	return nil
+}
+
+func (s *groupService) Wait() <-chan error {
+	return s.group.Wait()
+}
+
+func TestGroup(t *testing.T) {
+	t.Run("cancel", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		g := NewGroup(ctx)
+		g.Go(func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		})
+		cancel()
+		select {
+		case err, ok := <-g.Wait():
+			assert.NoError(t, err)
+			assert.False(t, ok)
+		case <-time.After(time.Second):
+			require.Fail(t, "Wait() channel should be closed")
+		}
+
+		// Goroutines are not started after the context is canceled.
+		g.Go(func(ctx context.Context) error {
+			panic("should not be called")
+		})
+	})
+
+	t.Run("canceled-before-go", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		g := NewGroup(ctx)
+		cancel()
+
+		// The context is checked synchronously, so fn is not called even
+		// if the group has not noticed the cancellation yet.
+		g.Go(func(ctx context.Context) error {
+			panic("should not be called")
+		})
+		_, ok := <-g.Wait()
+		assert.False(t, ok)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		g := NewGroup(ctx)
+		var sibling sync.WaitGroup
+		sibling.Add(1)
+		g.Go(func(ctx context.Context) error {
+			defer sibling.Done()
+			<-ctx.Done()
+			return nil
+		})
+		g.Go(func(ctx context.Context) error {
+			return errors.New("err")
+		})
+		require.EqualError(t, <-g.Wait(), "err")
+
+		// Other goroutines are canceled.
+		sibling.Wait()
+		cancel()
+		_, ok := <-g.Wait()
+		assert.False(t, ok)
+	})
+
+	t.Run("panic", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		g := NewGroup(ctx)
+		g.Go(func(ctx context.Context) error {
+			panic("boom")
+		})
+		err := <-g.Wait()
+		var panicErr *PanicError
+		require.True(t, errors.As(err, &panicErr))
+		assert.Equal(t, "panic: boom", err.Error())
+		assert.Equal(t, "boom", panicErr.Value)
+		assert.Contains(t, string(panicErr.Stack), "group_test.go")
+	})
+
+	t.Run("panic with error", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		g := NewGroup(ctx)
+		errBoom := errors.New("boom")
+		g.Go(func(ctx context.Context) error {
+			panic(errBoom)
+		})
+		assert.ErrorIs(t, <-g.Wait(), errBoom)
+	})
+}
+
+func TestSupervisor_ServicePanic(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var (
+		mu     sync.Mutex
+		fields log.Fields
+	)
+	l := callback.New(log.Error, func(level log.Level, f log.Fields, msg string) {
+		if msg == "Service crashed" {
+			mu.Lock()
+			fields = f
+			mu.Unlock()
+		}
+	})
+
+	s1 := &service{waitCh: make(chan error)}
+	s2 := &groupService{fn: func(ctx context.Context) error {
+		panic("boom")
+	}}
+	s := New(l)
+	s.Watch(s1, s2)
+	require.NoError(t, s.Start(ctx))
+
+	select {
+	case err := <-s.Wait():
+		require.EqualError(t, err, "panic: boom")
+	case <-time.After(time.Second):
+		require.Fail(t, "Wait() channel should not be blocked")
+	}
+	assert.False(t, s1.Started())
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, "groupService", fields["service"])
+	assert.Contains(t, fields["stack"], "group_test.go")
+	assert.NotEmpty(t, fields["advice"])
+}
@@ -20,6 +20,7 @@ import (
 	"errors"
 	"fmt"
 	"sync"
+	"time"
 
 	"github.com/chronicleprotocol/go-utils/errutil"
 	"github.com/chronicleprotocol/go-utils/log"
@@ -35,16 +36,27 @@ type Reloader struct {
 	waitCh chan error
 	log    log.Logger
 
-	factoryCtx    context.Context
-	factoryCancel context.CancelFunc
-	serviceCtx    context.Context
-	serviceCancel context.CancelFunc
-	serviceErr    error
-	service       Service
-	factory       ReloaderFn
-	factoryCh     chan Service
-	serviceWaitCh <-chan error
+	factoryCtx      context.Context
+	factoryCancel   context.CancelFunc
+	serviceCtx      context.Context
+	serviceCancel   context.CancelFunc
+	serviceErr      error
+	service         Service
+	factory         ReloaderFn
+	factoryCh       chan Service
+	serviceWaitCh   <-chan error
+	reloadCh        chan struct{}
+	generation      int
+	overlap         bool
+	readyTimeout    time.Duration
+	shutdownTimeout time.Duration
 }
+
+type reloadCtxKey struct{}
+
+// ReloaderFn is a function that sends new service instances to the
+// serviceCh channel. The function may use the ReloadSignal function
+// to get notified when a reload is requested using the Reload method.
 type ReloaderFn func(ctx context.Context, serviceCh chan<- Service) error
 
 // ReloaderConfig is a configuration for the Reloader service.
@@ -57,6 +69,27 @@ type ReloaderConfig struct {
 	// factory function returns an error.
 	Factory ReloaderFn
 
+	// Overlap enables the blue-green reload. Instead of stopping the old
+	// service before starting the new one, the new service is started first
+	// and, if it implements the Ready interface, awaited until it is ready.
+	// Only then the old service is stopped. If the new service fails to start
+	// or does not become ready, it is stopped and the old service keeps
+	// running.
+	//
+	// Both instances run concurrently for a while, so they must not conflict
+	// with each other. HTTP servers that listen on the same address must be
+	// created with the httpserver.WithReusePort option.
+	Overlap bool
+
+	// ReadyTimeout is the maximum time to wait for the new service to become
+	// ready in the overlap mode. The default is 30 seconds.
+	ReadyTimeout time.Duration
+
+	// ShutdownTimeout is the maximum time to wait for the replaced service
+	// to stop in the overlap mode. If it does not stop in time, an error is
+	// logged and the reload continues. The default is 30 seconds.
+	ShutdownTimeout time.Duration
+
 	// Logger is a logger instance.
 	Logger log.Logger
 }
@@ -66,12 +99,22 @@ func NewReloader(cfg ReloaderConfig) *Reloader {
 	if cfg.Logger == nil {
 		cfg.Logger = null.New()
 	}
+	if cfg.ReadyTimeout <= 0 {
+		cfg.ReadyTimeout = defaultReadyTimeout
+	}
+	if cfg.ShutdownTimeout <= 0 {
+		cfg.ShutdownTimeout = defaultShutdownTimeout
+	}
 	return &Reloader{
-		waitCh:        make(chan error),
-		log:           cfg.Logger.WithField("tag", ReloaderLoggerTag),
-		factory:       cfg.Factory,
-		factoryCh:     make(chan Service),
-		serviceWaitCh: make(chan error),
+		waitCh:          make(chan error),
+		log:             cfg.Logger.WithField("tag", ReloaderLoggerTag),
+		factory:         cfg.Factory,
+		factoryCh:       make(chan Service),
+		serviceWaitCh:   make(chan error),
+		reloadCh:        make(chan struct{}, 1),
+		overlap:         cfg.Overlap,
+		readyTimeout:    cfg.ReadyTimeout,
+		shutdownTimeout: cfg.ShutdownTimeout,
 	}
 }
 
@@ -109,7 +152,35 @@ func (r *Reloader) ServiceName() string {
 	return fmt.Sprintf("Reloader(%s)", ServiceName(r.service))
 }
 
+// Generation returns the number of service instances that have been
+// started by the Reloader.
+func (r *Reloader) Generation() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.generation
+}
+
+// Reload implements the Reloadable interface. It notifies the factory
+// function that a reload was requested. It does not block.
+func (r *Reloader) Reload() {
+	select {
+	case r.reloadCh <- struct{}{}:
+	default:
+	}
+}
+
+// ReloadSignal returns a channel that receives a value every time a reload
+// is requested using the Reloader.Reload method. The context must be
+// the one passed to the ReloaderFn function, otherwise nil is returned.
+func ReloadSignal(ctx context.Context) <-chan struct{} {
+	ch, _ := ctx.Value(reloadCtxKey{}).(chan struct{})
+	return ch
+}
+
 func (r *Reloader) reloadService(service Service) (err error) {
+	if r.overlap && r.generation > 0 {
+		return r.overlapReloadService(service)
+	}
 	if r.serviceCancel != nil {
 		r.log.
 			WithField("service", ServiceName(r.service)).
@@ -133,6 +204,68 @@ func (r *Reloader) reloadService(service Service) (err error) {
 	if err := r.service.Start(r.serviceCtx); err != nil {
 		return fmt.Errorf("service reloader: failed to start service: %w", err)
 	}
+	r.mu.Lock()
+	r.generation++
+	r.mu.Unlock()
+
+	r.log.
+		WithField("service", ServiceName(r.service)).
+		Info("Service reloaded")
+
+	return nil
+}
+
+// overlapReloadService starts the new service while the old one is still
+// running. The old service is stopped only after the new one is ready.
+// If the new service fails, it is stopped and the old one keeps running.
+func (r *Reloader) overlapReloadService(service Service) error {
+	r.log.
+		WithField("service", ServiceName(r.service)).
+		Info("Reloading service with overlap")
+
+	// Start the new service and wait until it is ready.
+	ctx, ctxCancel := context.WithCancel(r.ctx)
+	err := service.Start(ctx)
+	if err == nil {
+		err = waitReady(ctx, service, r.readyTimeout)
+		if err != nil {
+			ctxCancel()
+			if err := drainService(service, r.shutdownTimeout); errors.Is(err, errDrainTimeout) {
+				r.log.
+					WithField("service", ServiceName(service)).
+					WithField("timeout", r.shutdownTimeout).
+					Error("New service instance failed to stop in time")
+			}
+		}
+	}
+	if err != nil {
+		ctxCancel()
+		if r.ctx.Err() != nil {
+			return nil
+		}
+		r.log.
+			WithError(err).
+			WithField("service", ServiceName(service)).
+			WithAdvice("The previous service instance is still running").
+			Error("Failed to start new service, rolling back")
+		return nil
+	}
+
+	// Replace the old service with the new one and drain the old one.
+	r.mu.Lock()
+	oldService, oldCancel := r.service, r.serviceCancel
+	r.serviceCtx, r.serviceCancel = ctx, ctxCancel
+	r.service = service
+	r.serviceWaitCh = service.Wait()
+	r.generation++
+	r.mu.Unlock()
+	oldCancel()
+	if err := drainService(oldService, r.shutdownTimeout); err != nil {
+		r.log.
+			WithError(err).
+			WithField("service", ServiceName(oldService)).
+			Warn("Previous service instance stopped with an error")
+	}
 
 	r.log.
 		WithField("service", ServiceName(r.service)).
@@ -141,9 +274,33 @@ func (r *Reloader) reloadService(service Service) (err error) {
 	return nil
 }
 
+// errDrainTimeout is returned by drainService if the service does not stop
+// within the timeout.
+var errDrainTimeout = errors.New("service failed to stop within the shutdown timeout")
+
+// drainService waits until the service stops and returns the errors sent
+// to its wait channel. It returns errDrainTimeout if the service does not
+// stop within the timeout.
+func drainService(service Service, timeout time.Duration) (err error) {
+	t := time.NewTimer(timeout)
+	defer t.Stop()
+	waitCh := service.Wait()
+	for {
+		select {
+		case e, ok := <-waitCh:
+			if !ok {
+				return err
+			}
+			err = errutil.Append(err, e)
+		case <-t.C:
+			return errutil.Append(err, errDrainTimeout)
+		}
+	}
+}
+
 func (r *Reloader) serviceFactoryRoutine() {
 	r.mu.Lock()
-	r.factoryCtx, r.factoryCancel = context.WithCancel(r.ctx)
+	r.factoryCtx, r.factoryCancel = context.WithCancel(context.WithValue(r.ctx, reloadCtxKey{}, r.reloadCh))
 	r.mu.Unlock()
 	if err := r.factory(r.factoryCtx, r.factoryCh); err != nil {
 		r.mu.Lock()
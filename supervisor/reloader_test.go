@@ -164,4 +164,119 @@ func TestReloader(t *testing.T) {
 		require.NoError(t, r.Start(ctx))
 		require.Error(t, <-r.Wait())
 	})
+	t.Run("overlap reload", func(t *testing.T) {
+		e := &event{}
+		s1 := readyService{newOrderedService("s1", e)}
+		s2 := readyService{newOrderedService("s2", e)}
+		close(s1.readyCh)
+		c := make(chan struct{})
+		r := NewReloader(ReloaderConfig{
+			Factory: func(ctx context.Context, serviceCh chan<- Service) error {
+				serviceCh <- s1
+				serviceCh <- s2
+				c <- struct{}{}
+				return nil
+			},
+			Overlap: true,
+		})
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		require.NoError(t, r.Start(ctx))
+		assert.Eventually(t, func() bool {
+			return len(e.get()) == 2
+		}, 100*time.Millisecond, 10*time.Millisecond)
+
+		// The old service must be running until the new one is ready.
+		time.Sleep(50 * time.Millisecond)
+		assert.Equal(t, []string{"start s1", "start s2"}, e.get())
+		close(s2.readyCh)
+		<-c
+		assert.Eventually(t, func() bool {
+			return len(e.get()) == 3
+		}, 100*time.Millisecond, 10*time.Millisecond)
+		assert.Equal(t, []string{"start s1", "start s2", "stop s1"}, e.get())
+		assert.Equal(t, "Reloader(s2)", r.ServiceName())
+		assert.Equal(t, 2, r.Generation())
+	})
+
+	t.Run("overlap rollback", func(t *testing.T) {
+		s1 := &service{waitCh: make(chan error)}
+		s2 := &service{waitCh: make(chan error), failOnStart: true}
+		c := make(chan struct{})
+		r := NewReloader(ReloaderConfig{
+			Factory: func(ctx context.Context, serviceCh chan<- Service) error {
+				serviceCh <- s1
+				serviceCh <- s2
+				c <- struct{}{}
+				return nil
+			},
+			Overlap: true,
+		})
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		require.NoError(t, r.Start(ctx))
+		<-c
+		time.Sleep(20 * time.Millisecond)
+		assert.True(t, s1.Started())
+		assert.Equal(t, 1, r.Generation())
+		cancel()
+		require.NoError(t, <-r.Wait())
+	})
+
+	t.Run("overlap ready timeout", func(t *testing.T) {
+		e := &event{}
+		s1 := newOrderedService("s1", e)
+		s2 := readyService{newOrderedService("s2", e)}
+		c := make(chan struct{})
+		r := NewReloader(ReloaderConfig{
+			Factory: func(ctx context.Context, serviceCh chan<- Service) error {
+				serviceCh <- s1
+				serviceCh <- s2
+				c <- struct{}{}
+				return nil
+			},
+			Overlap:      true,
+			ReadyTimeout: 20 * time.Millisecond,
+		})
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		require.NoError(t, r.Start(ctx))
+		<-c
+		assert.Eventually(t, func() bool {
+			return len(e.get()) == 3
+		}, 100*time.Millisecond, 10*time.Millisecond)
+		assert.Equal(t, []string{"start s1", "start s2", "stop s2"}, e.get())
+		assert.Equal(t, "Reloader(s1)", r.ServiceName())
+	})
+
+	t.Run("overlap shutdown timeout", func(t *testing.T) {
+		s1 := &stuckService{waitCh: make(chan error)}
+		s2 := &service{waitCh: make(chan error)}
+		s3 := &service{waitCh: make(chan error)}
+		c := make(chan struct{})
+		r := NewReloader(ReloaderConfig{
+			Factory: func(ctx context.Context, serviceCh chan<- Service) error {
+				serviceCh <- s1
+				serviceCh <- s2
+				// The third service is received only after the reload
+				// of the second one has finished.
+				serviceCh <- s3
+				c <- struct{}{}
+				return nil
+			},
+			Overlap:         true,
+			ShutdownTimeout: 20 * time.Millisecond,
+		})
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		require.NoError(t, r.Start(ctx))
+		select {
+		case <-c:
+		case <-time.After(time.Second):
+			require.Fail(t, "reload should not wait for the stuck service")
+		}
+		assert.Eventually(t, func() bool {
+			return r.Generation() == 3
+		}, 100*time.Millisecond, 10*time.Millisecond)
+	})
 }
@@ -0,0 +1,290 @@
+//  Copyright (C) 2021-2023 Chronicle Labs, Inc.
+//
+//  This program is free software: you can redistribute it and/or modify
+//  it under the terms of the GNU Affero General Public License as
+//  published by the Free Software Foundation, either version 3 of the
+//  License, or (at your option) any later version.
+//
+//  This program is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of
+//  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU Affero General Public License for more details.
+//
+//  You should have received a copy of the GNU Affero General Public License
+//  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package supervisor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/chronicleprotocol/go-utils/log"
+	"github.com/chronicleprotocol/go-utils/log/null"
+)
+
+const RestarterLoggerTag = "RESTARTER"
+
+const (
+	defaultInitialBackoff = time.Second
+	defaultMaxBackoff     = time.Minute
+)
+
+// RestartPolicy defines when a service is restarted by the Restarter.
+type RestartPolicy int
+
+const (
+	// RestartNever never restarts the service. Errors are passed to the
+	// supervisor as is.
+	RestartNever RestartPolicy = iota
+
+	// RestartOnFailure restarts the service only if it fails with an error.
+	RestartOnFailure
+
+	// RestartAlways restarts the service every time it stops, unless the
+	// context is canceled.
+	RestartAlways
+)
+
+// String implements the fmt.Stringer interface.
+func (p RestartPolicy) String() string {
+	switch p {
+	case RestartNever:
+		return "never"
+	case RestartOnFailure:
+		return "on-failure"
+	case RestartAlways:
+		return "always"
+	}
+	return "unknown"
+}
+
+// ServiceFactory creates a new service instance. Because services can be
+// started only once, a new instance is needed for every restart.
+type ServiceFactory func() (Service, error)
+
+// RestarterConfig is a configuration for the Restarter service.
+type RestarterConfig struct {
+	// Factory is a function that creates a new service instance.
+	Factory ServiceFactory
+
+	// Policy defines when the service is restarted.
+	Policy RestartPolicy
+
+	// MaxRestarts is the maximum number of restarts. If the limit is
+	// exceeded, the last error is returned from the Wait channel, which
+	// causes the supervisor to stop all services. Zero means no limit.
+	MaxRestarts int
+
+	// InitialBackoff is the delay before the first restart. The delay is
+	// doubled after every restart up to MaxBackoff. If the service runs
+	// longer than MaxBackoff, the delay is reset. Default values are
+	// 1 second and 1 minute.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+
+	// Logger is a logger instance.
+	Logger log.Logger
+}
+
+// Restarter is a service that restarts a wrapped service according to
+// the restart policy. It allows non-critical services to fail without
+// stopping all other services managed by the supervisor.
+type Restarter struct {
+	mu     sync.Mutex
+	ctx    context.Context
+	waitCh chan error
+	log    log.Logger
+
+	factory        ServiceFactory
+	policy         RestartPolicy
+	maxRestarts    int
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+
+	service       Service
+	serviceCancel context.CancelFunc
+	restarts      int
+	lastErr       error
+}
+
+// NewRestarter returns a new Restarter instance.
+func NewRestarter(cfg RestarterConfig) *Restarter {
+	if cfg.Logger == nil {
+		cfg.Logger = null.New()
+	}
+	if cfg.InitialBackoff <= 0 {
+		cfg.InitialBackoff = defaultInitialBackoff
+	}
+	if cfg.MaxBackoff < cfg.InitialBackoff {
+		cfg.MaxBackoff = defaultMaxBackoff
+		if cfg.MaxBackoff < cfg.InitialBackoff {
+			cfg.MaxBackoff = cfg.InitialBackoff
+		}
+	}
+	return &Restarter{
+		waitCh:         make(chan error),
+		log:            cfg.Logger.WithField("tag", RestarterLoggerTag),
+		factory:        cfg.Factory,
+		policy:         cfg.Policy,
+		maxRestarts:    cfg.MaxRestarts,
+		initialBackoff: cfg.InitialBackoff,
+		maxBackoff:     cfg.MaxBackoff,
+	}
+}
+
+// Start implements the Service interface. If the first instance of
+// the service cannot be created or started, an error is returned.
+func (r *Restarter) Start(ctx context.Context) error {
+	if r.ctx != nil {
+		return errors.New("service can be started only once")
+	}
+	if ctx == nil {
+		return errors.New("context must not be nil")
+	}
+	if r.factory == nil {
+		return errors.New("service factory must not be nil")
+	}
+	r.ctx = ctx
+	if err := r.startService(); err != nil {
+		return err
+	}
+	go r.restarterRoutine()
+	return nil
+}
+
+// Wait implements the Service interface.
+func (r *Restarter) Wait() <-chan error {
+	return r.waitCh
+}
+
+// ServiceName implements the supervisor.WithName interface.
+func (r *Restarter) ServiceName() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.service == nil {
+		return "Restarter(uninitialized)"
+	}
+	return fmt.Sprintf("Restarter(%s)", ServiceName(r.service))
+}
+
+// RestartCount returns the number of times the service has been restarted.
+func (r *Restarter) RestartCount() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.restarts
+}
+
+// LastError returns the last error reported by the service or returned
+// while creating or starting it.
+func (r *Restarter) LastError() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.lastErr
+}
+
+// startService creates and starts a new service instance.
+func (r *Restarter) startService() error {
+	service, err := r.factory()
+	if err != nil {
+		return fmt.Errorf("service restarter: failed to create service: %w", err)
+	}
+	ctx, ctxCancel := context.WithCancel(r.ctx)
+	r.mu.Lock()
+	r.service = service
+	r.serviceCancel = ctxCancel
+	r.mu.Unlock()
+	if err := service.Start(ctx); err != nil {
+		ctxCancel()
+		return fmt.Errorf("service restarter: failed to start service: %w", err)
+	}
+	return nil
+}
+
+// waitService waits until the service stops and returns the first error
+// reported by the service. If the service reports an error or a nil value,
+// its context is canceled to make sure that it stops.
+func (r *Restarter) waitService() error {
+	r.mu.Lock()
+	service, serviceCancel := r.service, r.serviceCancel
+	r.mu.Unlock()
+	defer serviceCancel()
+	err, ok := <-service.Wait()
+	if ok {
+		serviceCancel()
+		for range service.Wait() { //nolint:revive
+		}
+	}
+	return err
+}
+
+func (r *Restarter) restarterRoutine() {
+	defer close(r.waitCh)
+	backoff := r.initialBackoff
+	for {
+		started := time.Now()
+		err := r.waitService()
+		for {
+			if err != nil {
+				r.mu.Lock()
+				r.lastErr = err
+				r.mu.Unlock()
+			}
+			if r.ctx.Err() != nil {
+				if err != nil && !errors.Is(err, context.Canceled) {
+					r.waitCh <- err
+				}
+				return
+			}
+			if r.policy == RestartNever || (r.policy == RestartOnFailure && err == nil) {
+				if err != nil {
+					r.waitCh <- err
+				}
+				return
+			}
+			if r.maxRestarts > 0 && r.RestartCount() >= r.maxRestarts {
+				if err == nil {
+					err = errors.New("service stopped")
+				}
+				r.log.
+					WithError(err).
+					WithField("service", ServiceName(r.service)).
+					WithField("restarts", r.RestartCount()).
+					Error("Restart limit exceeded")
+				r.waitCh <- fmt.Errorf("service restarter: restart limit exceeded: %w", err)
+				return
+			}
+			if time.Since(started) > r.maxBackoff {
+				backoff = r.initialBackoff
+			}
+			r.log.
+				WithError(err).
+				WithField("service", ServiceName(r.service)).
+				WithField("policy", r.policy.String()).
+				WithField("backoff", backoff).
+				Warn("Restarting service")
+			select {
+			case <-r.ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+			if backoff > r.maxBackoff {
+				backoff = r.maxBackoff
+			}
+			r.mu.Lock()
+			r.restarts++
+			r.mu.Unlock()
+			started = time.Now()
+			if err = r.startService(); err != nil {
+				// Creating or starting the service failed, try again
+				// according to the policy.
+				continue
+			}
+			break
+		}
+	}
+}
@@ -0,0 +1,215 @@
+//  Copyright (C) 2021-2023 Chronicle Labs, Inc.
+//
+//  This program is free software: you can redistribute it and/or modify
+//  it under the terms of the GNU Affero General Public License as
+//  published by the Free Software Foundation, either version 3 of the
+//  License, or (at your option) any later version.
+//
+//  This program is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of
+//  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU Affero General Public License for more details.
+//
+//  You should have received a copy of the GNU Affero General Public License
+//  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package supervisor
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type serviceFactory struct {
+	mu       sync.Mutex
+	services []*service
+	fail     bool
+}
+
+func (f *serviceFactory) New() (Service, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.fail {
+		return nil, errors.New("factory err")
+	}
+	s := &service{waitCh: make(chan error)}
+	f.services = append(f.services, s)
+	return s, nil
+}
+
+func (f *serviceFactory) Last() *service {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.services[len(f.services)-1]
+}
+
+func (f *serviceFactory) Count() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.services)
+}
+
+func TestRestarter(t *testing.T) {
+	t.Run("never", func(t *testing.T) {
+		f := &serviceFactory{}
+		r := NewRestarter(RestarterConfig{Factory: f.New, Policy: RestartNever})
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		require.NoError(t, r.Start(ctx))
+		f.Last().Errors(1)
+		select {
+		case err := <-r.Wait():
+			require.EqualError(t, err, "err")
+		case <-time.After(time.Second):
+			require.Fail(t, "Wait() channel should not be blocked")
+		}
+		assert.Equal(t, 1, f.Count())
+		assert.Equal(t, 0, r.RestartCount())
+	})
+
+	t.Run("on-failure", func(t *testing.T) {
+		f := &serviceFactory{}
+		r := NewRestarter(RestarterConfig{
+			Factory:        f.New,
+			Policy:         RestartOnFailure,
+			InitialBackoff: time.Millisecond,
+		})
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		require.NoError(t, r.Start(ctx))
+		s1 := f.Last()
+		s1.Errors(1)
+		assert.Eventually(t, func() bool {
+			return f.Count() == 2 && f.Last().Started()
+		}, time.Second, 10*time.Millisecond)
+		assert.False(t, s1.Started())
+		assert.Equal(t, 1, r.RestartCount())
+
+		// A service that stops without an error is not restarted.
+		f.Last().waitCh <- nil
+		select {
+		case err, ok := <-r.Wait():
+			require.NoError(t, err)
+			require.False(t, ok)
+		case <-time.After(time.Second):
+			require.Fail(t, "Wait() channel should be closed")
+		}
+		assert.Equal(t, 2, f.Count())
+	})
+
+	t.Run("always", func(t *testing.T) {
+		f := &serviceFactory{}
+		r := NewRestarter(RestarterConfig{
+			Factory:        f.New,
+			Policy:         RestartAlways,
+			InitialBackoff: time.Millisecond,
+		})
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		require.NoError(t, r.Start(ctx))
+		f.Last().waitCh <- nil
+		assert.Eventually(t, func() bool {
+			return f.Count() == 2 && f.Last().Started()
+		}, time.Second, 10*time.Millisecond)
+		cancel()
+		select {
+		case err, ok := <-r.Wait():
+			require.NoError(t, err)
+			require.False(t, ok)
+		case <-time.After(time.Second):
+			require.Fail(t, "Wait() channel should be closed")
+		}
+		assert.False(t, f.Last().Started())
+		assert.Equal(t, 2, f.Count())
+	})
+
+	t.Run("max-restarts", func(t *testing.T) {
+		f := &serviceFactory{}
+		r := NewRestarter(RestarterConfig{
+			Factory:        f.New,
+			Policy:         RestartOnFailure,
+			MaxRestarts:    2,
+			InitialBackoff: time.Millisecond,
+		})
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		require.NoError(t, r.Start(ctx))
+		for i := 0; i < 2; i++ {
+			f.Last().Errors(1)
+			n := i + 2
+			assert.Eventually(t, func() bool {
+				return f.Count() == n && f.Last().Started()
+			}, time.Second, 10*time.Millisecond)
+		}
+		f.Last().Errors(1)
+		select {
+		case err := <-r.Wait():
+			require.ErrorContains(t, err, "restart limit exceeded")
+		case <-time.After(time.Second):
+			require.Fail(t, "Wait() channel should not be blocked")
+		}
+		assert.Equal(t, 3, f.Count())
+		assert.Equal(t, 2, r.RestartCount())
+	})
+
+	t.Run("factory-failure", func(t *testing.T) {
+		f := &serviceFactory{}
+		r := NewRestarter(RestarterConfig{
+			Factory:        f.New,
+			Policy:         RestartOnFailure,
+			MaxRestarts:    3,
+			InitialBackoff: time.Millisecond,
+		})
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		require.NoError(t, r.Start(ctx))
+		f.mu.Lock()
+		f.fail = true
+		f.mu.Unlock()
+		f.Last().Errors(1)
+		select {
+		case err := <-r.Wait():
+			require.ErrorContains(t, err, "factory err")
+		case <-time.After(time.Second):
+			require.Fail(t, "Wait() channel should not be blocked")
+		}
+		assert.Equal(t, 3, r.RestartCount())
+	})
+
+	t.Run("escalate-to-supervisor", func(t *testing.T) {
+		f := &serviceFactory{}
+		r := NewRestarter(RestarterConfig{
+			Factory:        f.New,
+			Policy:         RestartOnFailure,
+			MaxRestarts:    1,
+			InitialBackoff: time.Millisecond,
+		})
+		s1 := &service{waitCh: make(chan error)}
+		s := New(nil)
+		s.Watch(s1, r)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		require.NoError(t, s.Start(ctx))
+		f.Last().Errors(1)
+		assert.Eventually(t, func() bool {
+			return f.Count() == 2 && f.Last().Started()
+		}, time.Second, 10*time.Millisecond)
+		assert.True(t, s1.Started())
+		f.Last().Errors(1)
+		select {
+		case err := <-s.Wait():
+			require.ErrorContains(t, err, "restart limit exceeded")
+		case <-time.After(time.Second):
+			require.Fail(t, "Wait() channel should not be blocked")
+		}
+		assert.Eventually(t, func() bool {
+			return !s1.Started()
+		}, time.Second, 10*time.Millisecond)
+	})
+}
@@ -0,0 +1,152 @@
+//  Copyright (C) 2021-2023 Chronicle Labs, Inc.
+//
+//  This program is free software: you can redistribute it and/or modify
+//  it under the terms of the GNU Affero General Public License as
+//  published by the Free Software Foundation, either version 3 of the
+//  License, or (at your option) any later version.
+//
+//  This program is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of
+//  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU Affero General Public License for more details.
+//
+//  You should have received a copy of the GNU Affero General Public License
+//  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package supervisor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ErrShutdownTimeout is returned by the Run method when services do not
+// stop within the shutdown timeout.
+var ErrShutdownTimeout = errors.New("services failed to stop within the shutdown timeout")
+
+// Reloadable is an optional interface that can be implemented by a service
+// to support reloading on the SIGHUP signal when the supervisor is started
+// using the Run method.
+type Reloadable interface {
+	Service
+
+	// Reload requests the service to reload. It must not block.
+	Reload()
+}
+
+// SetShutdownTimeout sets the maximum time the Run method waits for all
+// services to stop once shutdown has begun. If the timeout is zero or
+// negative, Run waits indefinitely. The default is 30 seconds.
+func (s *Supervisor) SetShutdownTimeout(timeout time.Duration) {
+	s.shutdownTimeout = timeout
+}
+
+// Run starts all watched services and blocks until they stop.
+//
+// Services are stopped when the context is canceled, when the process
+// receives the SIGINT or SIGTERM signal, or when any of the services fails.
+// The SIGHUP signal reloads all services that implement the Reloadable
+// interface.
+//
+// If services do not stop within the shutdown timeout, services that are
+// still running are logged and an error wrapping ErrShutdownTimeout is
+// returned. Otherwise, the first service error is returned, if any. If a
+// service fails to start, Run waits for the services that have already
+// started to stop before returning the start error.
+func (s *Supervisor) Run(ctx context.Context) error {
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+	defer signal.Stop(sigCh)
+	return s.run(ctx, sigCh)
+}
+
+// run implements the Run method. Signals are read from the given channel,
+// so tests can deliver them without signaling the process.
+func (s *Supervisor) run(ctx context.Context, sigCh <-chan os.Signal) error {
+	if ctx == nil {
+		return errors.New("context must not be nil")
+	}
+	if s.ctx != nil {
+		return errors.New("service can be started only once")
+	}
+	ctx, ctxCancel := context.WithCancel(ctx)
+	defer ctxCancel()
+
+	if err := s.Start(ctx); err != nil {
+		// Services started before the failure are already being stopped.
+		if waitErr := s.waitShutdown(); errors.Is(waitErr, ErrShutdownTimeout) {
+			return errors.Join(err, waitErr)
+		}
+		return err
+	}
+wait:
+	for {
+		select {
+		case sig := <-sigCh:
+			if sig == syscall.SIGHUP {
+				s.reload()
+				continue
+			}
+			s.log.WithField("signal", sig.String()).Info("Received signal")
+			ctxCancel()
+		case err := <-s.Wait():
+			// All services stopped on their own.
+			return err
+		case <-s.shutdownCh:
+			break wait
+		}
+	}
+	s.log.Info("Shutting down")
+	return s.waitShutdown()
+}
+
+// waitShutdown waits until all services stop, but no longer than the
+// shutdown timeout.
+func (s *Supervisor) waitShutdown() error {
+	if s.shutdownTimeout <= 0 {
+		return <-s.Wait()
+	}
+	t := time.NewTimer(s.shutdownTimeout)
+	defer t.Stop()
+	select {
+	case err := <-s.Wait():
+		return err
+	case <-t.C:
+		names := s.runningServiceNames()
+		s.log.
+			WithField("services", names).
+			WithField("timeout", s.shutdownTimeout).
+			WithAdvice("Make sure that the services close their Wait channel when the context is canceled").
+			Error("Services failed to stop in time")
+		return fmt.Errorf("%w: %s", ErrShutdownTimeout, strings.Join(names, ", "))
+	}
+}
+
+// reload reloads all services that implement the Reloadable interface.
+func (s *Supervisor) reload() {
+	for _, srv := range s.services {
+		if r, ok := srv.(Reloadable); ok {
+			s.log.
+				WithField("service", ServiceName(srv)).
+				Info("Reloading service")
+			r.Reload()
+		}
+	}
+}
+
+// runningServiceNames returns the names of services that are still running.
+func (s *Supervisor) runningServiceNames() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	names := make([]string, len(s.running))
+	for i, ss := range s.running {
+		names[i] = ServiceName(ss.service)
+	}
+	return names
+}
@@ -0,0 +1,171 @@
+//  Copyright (C) 2021-2023 Chronicle Labs, Inc.
+//
+//  This program is free software: you can redistribute it and/or modify
+//  it under the terms of the GNU Affero General Public License as
+//  published by the Free Software Foundation, either version 3 of the
+//  License, or (at your option) any later version.
+//
+//  This program is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of
+//  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU Affero General Public License for more details.
+//
+//  You should have received a copy of the GNU Affero General Public License
+//  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package supervisor
+
+import (
+	"context"
+	"errors"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stuckService is a service that never stops.
+type stuckService struct {
+	waitCh chan error
+}
+
+func (s *stuckService) Start(_ context.Context) error {
+	return nil
+}
+
+func (s *stuckService) Wait() <-chan error {
+	return s.waitCh
+}
+
+func TestSupervisor_Run(t *testing.T) {
+	t.Run("cancel-context", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		s := New(nil)
+		s1 := &service{waitCh: make(chan error)}
+		s.Watch(s1)
+		errCh := make(chan error, 1)
+		go func() { errCh <- s.Run(ctx) }()
+		assert.Eventually(t, s1.Started, time.Second, 10*time.Millisecond)
+		cancel()
+		select {
+		case err := <-errCh:
+			require.NoError(t, err)
+		case <-time.After(time.Second):
+			require.Fail(t, "Run should return")
+		}
+		assert.False(t, s1.Started())
+	})
+
+	t.Run("sigterm", func(t *testing.T) {
+		s := New(nil)
+		s1 := &service{waitCh: make(chan error)}
+		s.Watch(s1)
+		sigCh := make(chan os.Signal, 1)
+		errCh := make(chan error, 1)
+		go func() { errCh <- s.run(context.Background(), sigCh) }()
+		assert.Eventually(t, s1.Started, time.Second, 10*time.Millisecond)
+		sigCh <- syscall.SIGTERM
+		select {
+		case err := <-errCh:
+			require.NoError(t, err)
+		case <-time.After(time.Second):
+			require.Fail(t, "Run should return")
+		}
+	})
+
+	t.Run("service-error", func(t *testing.T) {
+		s := New(nil)
+		s1 := &service{waitCh: make(chan error)}
+		s.Watch(s1)
+		errCh := make(chan error, 1)
+		go func() { errCh <- s.Run(context.Background()) }()
+		assert.Eventually(t, s1.Started, time.Second, 10*time.Millisecond)
+		s1.Errors(1)
+		select {
+		case err := <-errCh:
+			require.EqualError(t, err, "err")
+		case <-time.After(time.Second):
+			require.Fail(t, "Run should return")
+		}
+	})
+
+	t.Run("start-error", func(t *testing.T) {
+		s := New(nil)
+		s1 := &service{waitCh: make(chan error)}
+		s2 := &service{waitCh: make(chan error), failOnStart: true}
+		s.Watch(s1, s2)
+		require.EqualError(t, s.Run(context.Background()), "err")
+		select {
+		case <-s1.waitCh:
+		default:
+			require.Fail(t, "Run should wait for started services to stop")
+		}
+	})
+
+	t.Run("start-error-shutdown-timeout", func(t *testing.T) {
+		s := New(nil)
+		s.SetShutdownTimeout(50 * time.Millisecond)
+		s1 := &stuckService{waitCh: make(chan error)}
+		s2 := &service{waitCh: make(chan error), failOnStart: true}
+		s.Watch(s1, s2)
+		err := s.Run(context.Background())
+		require.True(t, errors.Is(err, ErrShutdownTimeout))
+		assert.Contains(t, err.Error(), "err")
+	})
+
+	t.Run("shutdown-timeout", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		s := New(nil)
+		s.SetShutdownTimeout(50 * time.Millisecond)
+		s1 := &stuckService{waitCh: make(chan error)}
+		s.Watch(s1)
+		errCh := make(chan error, 1)
+		go func() { errCh <- s.Run(ctx) }()
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+		select {
+		case err := <-errCh:
+			require.True(t, errors.Is(err, ErrShutdownTimeout))
+			assert.Contains(t, err.Error(), "stuckService")
+		case <-time.After(time.Second):
+			require.Fail(t, "Run should return")
+		}
+	})
+
+	t.Run("sighup", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		s1 := &service{waitCh: make(chan error)}
+		s2 := &service{waitCh: make(chan error)}
+		r := NewReloader(ReloaderConfig{
+			Factory: func(ctx context.Context, serviceCh chan<- Service) error {
+				serviceCh <- s1
+				select {
+				case <-ReloadSignal(ctx):
+					serviceCh <- s2
+				case <-ctx.Done():
+				}
+				return nil
+			},
+		})
+		s := New(nil)
+		s.Watch(r)
+		sigCh := make(chan os.Signal, 1)
+		errCh := make(chan error, 1)
+		go func() { errCh <- s.run(ctx, sigCh) }()
+		assert.Eventually(t, s1.Started, time.Second, 10*time.Millisecond)
+		sigCh <- syscall.SIGHUP
+		assert.Eventually(t, s2.Started, time.Second, 10*time.Millisecond)
+		assert.False(t, s1.Started())
+		cancel()
+		select {
+		case err := <-errCh:
+			require.NoError(t, err)
+		case <-time.After(time.Second):
+			require.Fail(t, "Run should return")
+		}
+	})
+}
@@ -0,0 +1,141 @@
+//  Copyright (C) 2021-2023 Chronicle Labs, Inc.
+//
+//  This program is free software: you can redistribute it and/or modify
+//  it under the terms of the GNU Affero General Public License as
+//  published by the Free Software Foundation, either version 3 of the
+//  License, or (at your option) any later version.
+//
+//  This program is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of
+//  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU Affero General Public License for more details.
+//
+//  You should have received a copy of the GNU Affero General Public License
+//  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package supervisor
+
+import (
+	"time"
+)
+
+// ServiceState describes the state of a service managed by the Supervisor.
+type ServiceState string
+
+const (
+	StatePending  ServiceState = "pending"
+	StateStarting ServiceState = "starting"
+	StateRunning  ServiceState = "running"
+	StateStopping ServiceState = "stopping"
+	StateStopped  ServiceState = "stopped"
+	StateFailed   ServiceState = "failed"
+)
+
+// ServiceStatus is a snapshot of the status of a single service.
+type ServiceStatus struct {
+	// Name is the name of the service returned by the ServiceName function.
+	Name string `json:"name"`
+
+	// State is the current state of the service.
+	State ServiceState `json:"state"`
+
+	// StartedAt is the time when the service was started. It is zero if
+	// the service has not been started yet.
+	StartedAt time.Time `json:"startedAt"`
+
+	// Restarts is the number of restarts for services wrapped in
+	// the Restarter.
+	Restarts int `json:"restarts"`
+
+	// Generation is the number of times the service was loaded for services
+	// wrapped in the Reloader.
+	Generation int `json:"generation,omitempty"`
+
+	// LastError is the last error reported by the service.
+	LastError string `json:"lastError,omitempty"`
+}
+
+// Status is a snapshot of the status of all services managed by
+// the Supervisor.
+type Status struct {
+	// Ready is true if all services are running and the supervisor is not
+	// shutting down. A service that has stopped, even without an error, makes
+	// the status not ready. The system monitor added by the Watch method is
+	// not taken into account, because it stops immediately if its logs are
+	// disabled.
+	Ready bool `json:"ready"`
+
+	// Services contains the status of every service in the order in which
+	// they were added.
+	Services []ServiceStatus `json:"services"`
+}
+
+// serviceStatus holds the mutable part of the ServiceStatus.
+type serviceStatus struct {
+	state     ServiceState
+	startedAt time.Time
+	lastErr   error
+	internal  bool // added by the supervisor, does not affect readiness
+}
+
+// Optional interfaces used to provide additional information about
+// a service.
+type (
+	withRestartCount interface{ RestartCount() int }
+	withGeneration   interface{ Generation() int }
+	withLastError    interface{ LastError() error }
+)
+
+// Status returns a snapshot of the status of all services. It is safe to
+// call it concurrently with other methods.
+func (s *Supervisor) Status() Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st := Status{
+		Ready:    !s.shutdown,
+		Services: make([]ServiceStatus, 0, len(s.services)),
+	}
+	for idx, srv := range s.services {
+		v := s.status[idx]
+		ss := ServiceStatus{Name: ServiceName(srv), State: v.state, StartedAt: v.startedAt}
+		if v.lastErr != nil {
+			ss.LastError = v.lastErr.Error()
+		}
+		if v, ok := srv.(withRestartCount); ok {
+			ss.Restarts = v.RestartCount()
+		}
+		if v, ok := srv.(withGeneration); ok {
+			ss.Generation = v.Generation()
+		}
+		if v, ok := srv.(withLastError); ok && ss.LastError == "" {
+			if err := v.LastError(); err != nil {
+				ss.LastError = err.Error()
+			}
+		}
+		if ss.State != StateRunning && !v.internal {
+			st.Ready = false
+		}
+		st.Services = append(st.Services, ss)
+	}
+	return st
+}
+
+// setState updates the state of the service with the given index in
+// s.services. The error is recorded only
+// if it is not nil.
+func (s *Supervisor) setState(idx int, state ServiceState, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v := s.status[idx]
+	if v.state == StateFailed && state != StateFailed {
+		// Keep the failed state, so it is visible after the service stops.
+		return
+	}
+	if state == StateRunning {
+		v.startedAt = time.Now()
+	}
+	v.state = state
+	if err != nil {
+		v.lastErr = err
+	}
+}
@@ -0,0 +1,100 @@
+//  Copyright (C) 2021-2023 Chronicle Labs, Inc.
+//
+//  This program is free software: you can redistribute it and/or modify
+//  it under the terms of the GNU Affero General Public License as
+//  published by the Free Software Foundation, either version 3 of the
+//  License, or (at your option) any later version.
+//
+//  This program is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of
+//  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU Affero General Public License for more details.
+//
+//  You should have received a copy of the GNU Affero General Public License
+//  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package supervisor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSupervisor_Status(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	f := &serviceFactory{}
+	r := NewRestarter(RestarterConfig{
+		Factory:        f.New,
+		Policy:         RestartOnFailure,
+		InitialBackoff: time.Millisecond,
+	})
+	s1 := &service{waitCh: make(chan error)}
+	s := New(nil)
+	s.Watch(s1, r)
+
+	st := s.Status()
+	assert.False(t, st.Ready)
+	require.Len(t, st.Services, 3)
+	assert.Equal(t, StatePending, st.Services[0].State)
+
+	require.NoError(t, s.Start(ctx))
+	st = s.Status()
+	assert.True(t, st.Ready)
+	assert.Equal(t, "service", st.Services[0].Name)
+	assert.Equal(t, StateRunning, st.Services[0].State)
+	assert.False(t, st.Services[0].StartedAt.IsZero())
+	assert.Equal(t, "Restarter(service)", st.Services[1].Name)
+
+	f.Last().Errors(1)
+	assert.Eventually(t, func() bool {
+		return s.Status().Services[1].Restarts == 1
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, "err", s.Status().Services[1].LastError)
+
+	s1.Errors(1)
+	<-s.Wait()
+	st = s.Status()
+	assert.False(t, st.Ready)
+	assert.Equal(t, StateFailed, st.Services[0].State)
+	assert.Equal(t, "err", st.Services[0].LastError)
+	assert.Equal(t, StateStopped, st.Services[1].State)
+}
+
+// doneService is a service that stops immediately after start.
+type doneService struct {
+	waitCh chan error
+}
+
+func (s *doneService) Start(_ context.Context) error {
+	close(s.waitCh)
+	return nil
+}
+
+func (s *doneService) Wait() <-chan error {
+	return s.waitCh
+}
+
+func TestSupervisor_StatusStoppedService(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	s1 := &service{waitCh: make(chan error)}
+	s2 := &doneService{waitCh: make(chan error)}
+	s := New(nil)
+	s.Watch(s1, s2)
+	require.NoError(t, s.Start(ctx))
+
+	// A service that stopped without an error is not ready, but the system
+	// monitor, which stops immediately with the null logger, is ignored.
+	assert.Eventually(t, func() bool {
+		st := s.Status()
+		return st.Services[1].State == StateStopped && st.Services[2].State == StateStopped
+	}, time.Second, 10*time.Millisecond)
+	assert.False(t, s.Status().Ready)
+}
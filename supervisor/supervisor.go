@@ -18,7 +18,9 @@ package supervisor
 import (
 	"context"
 	"errors"
+	"fmt"
 	"reflect"
+	"sync"
 	"time"
 
 	"github.com/chronicleprotocol/go-utils/errutil"
@@ -52,15 +54,63 @@ type WithName interface {
 	ServiceName() string
 }
 
+// Ready is an optional interface that can be implemented by a service
+// to report when it is ready to be used by other services. The Supervisor
+// waits for the channel returned by the Ready method to be closed before
+// starting the next service.
+type Ready interface {
+	Service
+
+	// Ready returns a channel that is closed when the service is ready.
+	Ready() <-chan struct{}
+}
+
+const (
+	defaultReadyTimeout    = 30 * time.Second
+	defaultShutdownTimeout = 30 * time.Second
+)
+
 // Supervisor manages long-running services that implement the Service
 // interface. If any of the managed services fail, all other services are
 // stopped. This ensures that all services are running or none.
+//
+// Services are started in the order they were added, but a service is
+// always started after the services it depends on (see DependsOn). During
+// shutdown, a service is stopped only after all services that depend on it
+// have stopped.
 type Supervisor struct {
-	ctx       context.Context
-	ctxCancel context.CancelFunc
-	waitCh    chan error
-	services  []Service
-	log       log.Logger
+	ctx             context.Context
+	waitCh          chan error
+	services        []Service
+	deps            []dependency
+	readyTimeout    time.Duration
+	shutdownTimeout time.Duration
+	log             log.Logger
+
+	// Fields used by the service monitor:
+	mu         sync.Mutex
+	running    []*supervisedService
+	status     []*serviceStatus // status of services, indexed as s.services
+	shutdown   bool
+	shutdownCh chan struct{}
+}
+
+// dependency is a list of dependencies declared with DependsOn.
+//
+// Services are identified by their index in the Supervisor.services slice
+// rather than used as map keys, because a service may be of a type that is
+// not comparable.
+type dependency struct {
+	service Service
+	deps    []Service
+}
+
+type supervisedService struct {
+	idx        int // idx is the index of the service in Supervisor.services
+	service    Service
+	ctxCancel  context.CancelFunc
+	canceled   bool
+	dependents []*supervisedService
 }
 
 // New returns a new instance of *Supervisor.
@@ -69,8 +119,13 @@ func New(logger log.Logger) *Supervisor {
 		logger = null.New()
 	}
 	return &Supervisor{
-		waitCh: make(chan error),
-		log:    logger.WithField("tag", LoggerTag),
+		// The channel is buffered, so the service monitor does not block
+		// if no one reads the error, e.g. after Start has failed.
+		waitCh:          make(chan error, 1),
+		readyTimeout:    defaultReadyTimeout,
+		shutdownTimeout: defaultShutdownTimeout,
+		shutdownCh:      make(chan struct{}),
+		log:             logger.WithField("tag", LoggerTag),
 	}
 }
 
@@ -82,6 +137,33 @@ func (s *Supervisor) Watch(services ...Service) {
 	}
 	services = append(services, sysmon.New(time.Minute, s.log))
 	s.services = append(s.services, services...)
+	s.mu.Lock()
+	for i := range services {
+		s.status = append(s.status, &serviceStatus{
+			state:    StatePending,
+			internal: i == len(services)-1,
+		})
+	}
+	s.mu.Unlock()
+}
+
+// DependsOn declares that the service depends on the given services. The
+// service is started after its dependencies are started and ready, and
+// it is stopped before its dependencies are stopped. All services must be
+// added using the Watch method. Dependencies must be declared before
+// invoking the Start method, otherwise it panics.
+func (s *Supervisor) DependsOn(service Service, deps ...Service) {
+	if s.ctx != nil {
+		s.log.Panic("supervisor was already started")
+	}
+	s.deps = append(s.deps, dependency{service: service, deps: deps})
+}
+
+// SetReadyTimeout sets the maximum time the Start method waits for
+// a service that implements the Ready interface to become ready.
+// The default is 30 seconds.
+func (s *Supervisor) SetReadyTimeout(timeout time.Duration) {
+	s.readyTimeout = timeout
 }
 
 // Start starts all watched services. It can be invoked only once, otherwise
@@ -93,14 +175,43 @@ func (s *Supervisor) Start(ctx context.Context) error {
 	if ctx == nil {
 		return errors.New("context must not be nil")
 	}
-	s.ctx, s.ctxCancel = context.WithCancel(ctx)
-	for _, srv := range s.services {
+	s.ctx = ctx
+	order, deps, err := s.startOrder()
+	if err != nil {
+		close(s.waitCh)
+		return err
+	}
+	started := make([]*supervisedService, len(s.services))
+	for _, idx := range order {
+		srv := s.services[idx]
+		// Every service has its own context, so services can be stopped
+		// in the reverse dependency order. Cancellation of the parent
+		// context is handled by the service monitor.
+		srvCtx, srvCtxCancel := context.WithCancel(context.WithoutCancel(ctx))
+		ss := &supervisedService{idx: idx, service: srv, ctxCancel: srvCtxCancel}
 		s.log.
 			WithField("service", ServiceName(srv)).
 			Debug("Starting service")
-		if err := srv.Start(s.ctx); err != nil {
-			s.ctxCancel()
-			close(s.waitCh)
+		s.setState(idx, StateStarting, nil)
+		err := srv.Start(srvCtx)
+		if err == nil {
+			s.mu.Lock()
+			s.running = append(s.running, ss)
+			s.mu.Unlock()
+			for _, dep := range deps[idx] {
+				started[dep].dependents = append(started[dep].dependents, ss)
+			}
+			started[idx] = ss
+			if err = waitReady(s.ctx, srv, s.readyTimeout); err == nil {
+				s.setState(idx, StateRunning, nil)
+			}
+		} else {
+			srvCtxCancel()
+		}
+		if err != nil {
+			s.setState(idx, StateFailed, err)
+			s.beginShutdown()
+			go s.serviceMonitor()
 			return err
 		}
 	}
@@ -117,34 +228,143 @@ func (s *Supervisor) Wait() <-chan error {
 	return s.waitCh
 }
 
+// startOrder returns the indices of services sorted in such a way that
+// every service is placed after its dependencies. Apart from that, the order
+// in which services were added is preserved. It also returns the indices of
+// dependencies of every service.
+func (s *Supervisor) startOrder() ([]int, [][]int, error) {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	deps := make([][]int, len(s.services))
+	for _, d := range s.deps {
+		idx, err := s.indexOf(d.service)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, dep := range d.deps {
+			depIdx, err := s.indexOf(dep)
+			if err != nil {
+				return nil, nil, fmt.Errorf("service %s depends on a service that cannot be used: %w", ServiceName(d.service), err)
+			}
+			deps[idx] = append(deps[idx], depIdx)
+		}
+	}
+	var (
+		order []int
+		state = make([]int, len(s.services))
+		visit func(idx int) error
+	)
+	visit = func(idx int) error {
+		switch state[idx] {
+		case visiting:
+			return fmt.Errorf("circular dependency detected for service %s", ServiceName(s.services[idx]))
+		case visited:
+			return nil
+		}
+		state[idx] = visiting
+		for _, dep := range deps[idx] {
+			if err := visit(dep); err != nil {
+				return err
+			}
+		}
+		state[idx] = visited
+		order = append(order, idx)
+		return nil
+	}
+	for idx := range s.services {
+		if err := visit(idx); err != nil {
+			return nil, nil, err
+		}
+	}
+	return order, deps, nil
+}
+
+// indexOf returns the index of the service in s.services. Services used in
+// DependsOn must be of a comparable type, such as a pointer.
+func (s *Supervisor) indexOf(srv Service) (int, error) {
+	if srv == nil {
+		return 0, errors.New("service must not be nil")
+	}
+	if !reflect.TypeOf(srv).Comparable() {
+		return 0, fmt.Errorf("service %s cannot be used in DependsOn because its type is not comparable", ServiceName(srv))
+	}
+	for i, w := range s.services {
+		// The comparison does not panic even if w is not comparable,
+		// because values of different dynamic types are never equal.
+		if w == srv {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("service %s is not watched by the supervisor", ServiceName(srv))
+}
+
+// waitReady waits until the service is ready if it implements the Ready
+// interface.
+func waitReady(ctx context.Context, srv Service, timeout time.Duration) error {
+	r, ok := srv.(Ready)
+	if !ok {
+		return nil
+	}
+	t := time.NewTimer(timeout)
+	defer t.Stop()
+	select {
+	case <-r.Ready():
+		return nil
+	case err, ok := <-srv.Wait():
+		if ok && err != nil {
+			return err
+		}
+		return fmt.Errorf("service %s stopped before it was ready", ServiceName(srv))
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return fmt.Errorf("service %s was not ready within %s", ServiceName(srv), timeout)
+	}
+}
+
 func (s *Supervisor) serviceMonitor() {
 	var err error
 	// In this loop, a select is created (using reflection) that waits until
 	// at least one service has completed its work. This is reported by
 	// closing the channel returned by the Wait() or returning an error from
 	// the same channel (see the Service interface). The service is then
-	// removed from the s.service list and the loop is executed again until
+	// removed from the s.running list and the loop is executed again until
 	// no service remains.
-	for len(s.services) > 0 {
-		// Wait for first stopped service:
-		c := make([]reflect.SelectCase, len(s.services))
-		for i, srv := range s.services {
-			c[i] = reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(srv.Wait())}
+	for len(s.running) > 0 {
+		// Wait for first stopped service or the parent context cancellation:
+		c := make([]reflect.SelectCase, len(s.running), len(s.running)+1)
+		for i, ss := range s.running {
+			c[i] = reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ss.service.Wait())}
+		}
+		if !s.shutdown {
+			c = append(c, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(s.ctx.Done())})
 		}
 		n, v, ok := reflect.Select(c)
-		name := ServiceName(s.services[n])
+		if n == len(s.running) {
+			s.beginShutdown()
+			continue
+		}
+		name := ServiceName(s.running[n].service)
 
-		// If service failed, cancel the context to stop the others:
+		// If service failed, stop the others:
 		if !v.IsNil() {
-			s.log.
+			logger := s.log.
 				WithError(v.Interface().(error)).
 				WithField("service", name).
-				WithAdvice("This is a critical bug and must be investigated").
-				Error("Service crashed")
+				WithAdvice("This is a critical bug and must be investigated")
+			var panicErr *PanicError
+			if errors.As(v.Interface().(error), &panicErr) {
+				logger = logger.WithField("stack", string(panicErr.Stack))
+			}
+			logger.Error("Service crashed")
 			if err == nil {
 				err = errutil.Append(err, v.Interface().(error))
 			}
-			s.ctxCancel()
+			s.setState(s.running[n].idx, StateFailed, v.Interface().(error))
+			s.beginShutdown()
 			continue
 		}
 
@@ -154,7 +374,14 @@ func (s *Supervisor) serviceMonitor() {
 
 		// Remove service from list if channel is closed:
 		if !ok {
-			s.services = append(s.services[:n], s.services[n+1:]...)
+			s.running[n].ctxCancel()
+			s.setState(s.running[n].idx, StateStopped, nil)
+			s.mu.Lock()
+			s.running = append(s.running[:n], s.running[n+1:]...)
+			s.mu.Unlock()
+			if s.shutdown {
+				s.stopServices()
+			}
 		}
 	}
 	if err != nil {
@@ -163,6 +390,46 @@ func (s *Supervisor) serviceMonitor() {
 	close(s.waitCh)
 }
 
+// beginShutdown starts stopping all running services.
+func (s *Supervisor) beginShutdown() {
+	if s.shutdown {
+		return
+	}
+	s.mu.Lock()
+	s.shutdown = true
+	s.mu.Unlock()
+	close(s.shutdownCh)
+	s.stopServices()
+}
+
+// stopServices cancels the context of every running service that has no
+// running dependents. It is called repeatedly during shutdown, so services
+// are stopped in the reverse dependency order.
+func (s *Supervisor) stopServices() {
+	for _, ss := range s.running {
+		if ss.canceled || s.hasRunningDependents(ss) {
+			continue
+		}
+		s.log.
+			WithField("service", ServiceName(ss.service)).
+			Debug("Stopping service")
+		ss.canceled = true
+		ss.ctxCancel()
+		s.setState(ss.idx, StateStopping, nil)
+	}
+}
+
+func (s *Supervisor) hasRunningDependents(ss *supervisedService) bool {
+	for _, d := range ss.dependents {
+		for _, r := range s.running {
+			if r == d {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 // ServiceName returns the name of the service. If the service implements
 // the WithName interface, the ServiceName method is used. Otherwise, the
 // name of the type is returned.
@@ -185,3 +185,232 @@ func TestSupervisor_MultipleErrors(t *testing.T) {
 	assert.False(t, s2.Started())
 	assert.False(t, s3.Started())
 }
+
+type event struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (e *event) add(s string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.events = append(e.events, s)
+}
+
+func (e *event) get() []string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]string(nil), e.events...)
+}
+
+type orderedService struct {
+	name    string
+	events  *event
+	readyCh chan struct{}
+	waitCh  chan error
+}
+
+func newOrderedService(name string, events *event) *orderedService {
+	return &orderedService{
+		name:    name,
+		events:  events,
+		readyCh: make(chan struct{}),
+		waitCh:  make(chan error),
+	}
+}
+
+func (s *orderedService) Start(ctx context.Context) error {
+	s.events.add("start " + s.name)
+	go func() {
+		<-ctx.Done()
+		// Give the supervisor a chance to stop other services too early.
+		time.Sleep(10 * time.Millisecond)
+		s.events.add("stop " + s.name)
+		close(s.waitCh)
+	}()
+	return nil
+}
+
+func (s *orderedService) Wait() <-chan error {
+	return s.waitCh
+}
+
+func (s *orderedService) ServiceName() string {
+	return s.name
+}
+
+type readyService struct {
+	*orderedService
+}
+
+func (s readyService) Ready() <-chan struct{} {
+	return s.readyCh
+}
+
+func TestSupervisor_Dependencies(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	e := &event{}
+	http := newOrderedService("http", e)
+	db := newOrderedService("db", e)
+	cfg := newOrderedService("config", e)
+
+	s := New(nil)
+	s.Watch(http, db, cfg)
+	s.DependsOn(http, db)
+	s.DependsOn(db, cfg)
+
+	require.NoError(t, s.Start(ctx))
+	assert.Equal(t, []string{"start config", "start db", "start http"}, e.get())
+
+	cancel()
+	select {
+	case <-s.Wait():
+	case <-time.After(time.Second):
+		require.Fail(t, "Wait() channel should be closed")
+	}
+	assert.Equal(t, []string{
+		"start config", "start db", "start http",
+		"stop http", "stop db", "stop config",
+	}, e.get())
+}
+
+func TestSupervisor_DependenciesOnFailure(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	e := &event{}
+	http := newOrderedService("http", e)
+	db := newOrderedService("db", e)
+	s1 := &service{waitCh: make(chan error)}
+
+	s := New(nil)
+	s.Watch(http, db, s1)
+	s.DependsOn(http, db)
+
+	require.NoError(t, s.Start(ctx))
+	s1.Errors(1)
+	select {
+	case err := <-s.Wait():
+		require.EqualError(t, err, "err")
+	case <-time.After(time.Second):
+		require.Fail(t, "Wait() channel should not be blocked")
+	}
+	assert.Equal(t, []string{"start http", "stop http", "stop db"}, e.get()[1:])
+}
+
+func TestSupervisor_InvalidDependencies(t *testing.T) {
+	e := &event{}
+	t.Run("circular", func(t *testing.T) {
+		a := newOrderedService("a", e)
+		b := newOrderedService("b", e)
+		s := New(nil)
+		s.Watch(a, b)
+		s.DependsOn(a, b)
+		s.DependsOn(b, a)
+		require.ErrorContains(t, s.Start(context.Background()), "circular dependency")
+	})
+	t.Run("not-watched", func(t *testing.T) {
+		a := newOrderedService("a", e)
+		b := newOrderedService("b", e)
+		s := New(nil)
+		s.Watch(a)
+		s.DependsOn(a, b)
+		require.ErrorContains(t, s.Start(context.Background()), "not watched")
+	})
+}
+
+// uncomparableService is a service of a type that cannot be used as a map
+// key or compared using the == operator.
+type uncomparableService struct {
+	waitCh chan error
+	tags   []string
+}
+
+func (s uncomparableService) Start(ctx context.Context) error {
+	go func() {
+		<-ctx.Done()
+		close(s.waitCh)
+	}()
+	return nil
+}
+
+func (s uncomparableService) Wait() <-chan error {
+	return s.waitCh
+}
+
+func TestSupervisor_UncomparableService(t *testing.T) {
+	t.Run("watch", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		s := New(nil)
+		u := uncomparableService{waitCh: make(chan error), tags: []string{"a"}}
+		s1 := &service{waitCh: make(chan error)}
+		s.Watch(u, s1)
+		s.DependsOn(s1)
+
+		require.NoError(t, s.Start(ctx))
+		assert.Equal(t, StateRunning, s.Status().Services[0].State)
+
+		cancel()
+		assert.Eventually(t, func() bool {
+			select {
+			case _, ok := <-s.Wait():
+				return !ok
+			default:
+				return false
+			}
+		}, time.Second, 10*time.Millisecond)
+	})
+	t.Run("depends-on", func(t *testing.T) {
+		s := New(nil)
+		u := uncomparableService{waitCh: make(chan error), tags: []string{"a"}}
+		s1 := &service{waitCh: make(chan error)}
+		s.Watch(u, s1)
+		s.DependsOn(s1, u)
+		require.ErrorContains(t, s.Start(context.Background()), "not comparable")
+	})
+}
+
+func TestSupervisor_Ready(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	e := &event{}
+	db := readyService{newOrderedService("db", e)}
+	http := newOrderedService("http", e)
+
+	s := New(nil)
+	s.Watch(http, db)
+	s.DependsOn(http, db)
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		e.add("ready db")
+		close(db.readyCh)
+	}()
+	require.NoError(t, s.Start(ctx))
+	assert.Equal(t, []string{"start db", "ready db", "start http"}, e.get())
+}
+
+func TestSupervisor_ReadyTimeout(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	e := &event{}
+	db := readyService{newOrderedService("db", e)}
+	http := newOrderedService("http", e)
+
+	s := New(nil)
+	s.SetReadyTimeout(50 * time.Millisecond)
+	s.Watch(http, db)
+	s.DependsOn(http, db)
+
+	require.ErrorContains(t, s.Start(ctx), "was not ready")
+	select {
+	case <-s.Wait():
+	case <-time.After(time.Second):
+		require.Fail(t, "Wait() channel should be closed")
+	}
+	assert.Equal(t, []string{"start db", "stop db"}, e.get())
+}
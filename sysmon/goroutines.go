@@ -0,0 +1,194 @@
+//  Copyright (C) 2021-2023 Chronicle Labs, Inc.
+//
+//  This program is free software: you can redistribute it and/or modify
+//  it under the terms of the GNU Affero General Public License as
+//  published by the Free Software Foundation, either version 3 of the
+//  License, or (at your option) any later version.
+//
+//  This program is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of
+//  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU Affero General Public License for more details.
+//
+//  You should have received a copy of the GNU Affero General Public License
+//  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package sysmon
+
+import (
+	"bufio"
+	"bytes"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// GoroutineGroup is a group of goroutines created at the same location.
+type GoroutineGroup struct {
+	// Creator is the function and location where the goroutines were
+	// created, or "main" for the main goroutine.
+	Creator string
+
+	// Count is the number of goroutines in the group.
+	Count int
+}
+
+// GoroutinesByCreator returns live goroutines grouped by the location where
+// they were created, sorted by the number of goroutines in descending order.
+func GoroutinesByCreator() []GoroutineGroup {
+	return groupByCreator(parseGoroutines(dumpGoroutines()))
+}
+
+// goroutine describes a single goroutine parsed from a stack dump.
+type goroutine struct {
+	state    string        // state, e.g. "chan receive"
+	waiting  time.Duration // time the goroutine has been blocked, minute resolution
+	location string        // first frame outside the runtime package
+	creator  string        // function and location that created the goroutine
+}
+
+// blocked reports whether the goroutine is blocked on a channel operation.
+func (g goroutine) blocked() bool {
+	return strings.HasPrefix(g.state, "chan ") || strings.HasPrefix(g.state, "select")
+}
+
+// dumpGoroutines returns stack traces of all goroutines.
+func dumpGoroutines() []byte {
+	buf := make([]byte, 1<<16)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			return buf[:n]
+		}
+		buf = make([]byte, len(buf)*2)
+	}
+}
+
+// parseGoroutines parses stack traces in the format returned by
+// runtime.Stack.
+func parseGoroutines(dump []byte) []goroutine {
+	var (
+		gs []goroutine
+		g  *goroutine
+		fn string
+		sc = bufio.NewScanner(bytes.NewReader(dump))
+	)
+	sc.Buffer(nil, len(dump)+1)
+	for sc.Scan() {
+		line := sc.Text()
+		switch {
+		case strings.HasPrefix(line, "goroutine "):
+			gs = append(gs, parseGoroutineHeader(line))
+			g = &gs[len(gs)-1]
+			fn = ""
+		case g == nil || line == "":
+			continue
+		case strings.HasPrefix(line, "\t"):
+			// File and line of the previous function.
+			pos := strings.TrimSpace(line)
+			if i := strings.LastIndex(pos, " +0x"); i >= 0 {
+				pos = pos[:i]
+			}
+			switch {
+			case strings.HasPrefix(fn, "created by "):
+				g.creator = strings.TrimPrefix(fn, "created by ") + " " + pos
+			case g.location == "" && !strings.HasPrefix(fn, "runtime."):
+				g.location = fn + " " + pos
+			}
+		default:
+			fn = line
+			if strings.HasPrefix(fn, "created by ") {
+				if i := strings.Index(fn, " in goroutine "); i >= 0 {
+					fn = fn[:i]
+				}
+			} else if i := strings.LastIndex(fn, "("); i >= 0 {
+				fn = fn[:i]
+			}
+		}
+	}
+	for i := range gs {
+		if gs[i].creator == "" {
+			gs[i].creator = "main"
+		}
+	}
+	return gs
+}
+
+// parseGoroutineHeader parses a header line, e.g.:
+// "goroutine 18 [chan receive, 2 minutes]:".
+func parseGoroutineHeader(line string) goroutine {
+	var g goroutine
+	start := strings.Index(line, "[")
+	end := strings.LastIndex(line, "]")
+	if start < 0 || end < start {
+		return g
+	}
+	for i, part := range strings.Split(line[start+1:end], ", ") {
+		if i == 0 {
+			g.state = part
+			continue
+		}
+		if strings.HasSuffix(part, " minutes") || strings.HasSuffix(part, " minute") {
+			n, err := strconv.Atoi(strings.Fields(part)[0])
+			if err == nil {
+				g.waiting = time.Duration(n) * time.Minute
+			}
+		}
+	}
+	return g
+}
+
+// groupByCreator groups goroutines by the creator and sorts groups by
+// the number of goroutines in descending order.
+func groupByCreator(gs []goroutine) []GoroutineGroup {
+	counts := map[string]int{}
+	for _, g := range gs {
+		counts[g.creator]++
+	}
+	groups := make([]GoroutineGroup, 0, len(counts))
+	for c, n := range counts {
+		groups = append(groups, GoroutineGroup{Creator: c, Count: n})
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].Count == groups[j].Count {
+			return groups[i].Creator < groups[j].Creator
+		}
+		return groups[i].Count > groups[j].Count
+	})
+	return groups
+}
+
+// blockedGroup is a group of goroutines blocked on the same channel
+// operation.
+type blockedGroup struct {
+	state    string
+	location string
+	count    int
+	waiting  time.Duration // the longest waiting time in the group
+}
+
+// groupBlocked groups goroutines that have been blocked on a channel
+// operation for at least the given duration by the state and location.
+func groupBlocked(gs []goroutine, threshold time.Duration) []blockedGroup {
+	var groups []blockedGroup
+	idx := map[string]int{}
+	for _, g := range gs {
+		if !g.blocked() || g.waiting < threshold {
+			continue
+		}
+		key := g.state + "|" + g.location
+		i, ok := idx[key]
+		if !ok {
+			i = len(groups)
+			idx[key] = i
+			groups = append(groups, blockedGroup{state: g.state, location: g.location})
+		}
+		groups[i].count++
+		if g.waiting > groups[i].waiting {
+			groups[i].waiting = g.waiting
+		}
+	}
+	return groups
+}
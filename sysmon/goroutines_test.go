@@ -0,0 +1,106 @@
+//  Copyright (C) 2021-2023 Chronicle Labs, Inc.
+//
+//  This program is free software: you can redistribute it and/or modify
+//  it under the terms of the GNU Affero General Public License as
+//  published by the Free Software Foundation, either version 3 of the
+//  License, or (at your option) any later version.
+//
+//  This program is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of
+//  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU Affero General Public License for more details.
+//
+//  You should have received a copy of the GNU Affero General Public License
+//  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package sysmon
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testDump = `goroutine 1 [running]:
+main.main()
+	/app/main.go:17 +0xce
+
+goroutine 7 [chan receive, 5 minutes]:
+runtime.gopark(0x0?, 0x0?, 0x0?, 0x0?, 0x0?)
+	/usr/local/go/src/runtime/proc.go:398 +0xce
+main.worker(0xc000010000)
+	/app/worker.go:12 +0x19
+created by main.main in goroutine 1
+	/app/main.go:12 +0x37
+
+goroutine 8 [chan receive, 3 minutes]:
+main.worker(0xc000010000)
+	/app/worker.go:12 +0x19
+created by main.main in goroutine 1
+	/app/main.go:12 +0x37
+
+goroutine 9 [select]:
+main.loop()
+	/app/loop.go:14 +0xf
+created by main.start in goroutine 1
+	/app/main.go:20 +0x9b
+`
+
+func TestParseGoroutines(t *testing.T) {
+	gs := parseGoroutines([]byte(testDump))
+	require.Len(t, gs, 4)
+
+	assert.Equal(t, "running", gs[0].state)
+	assert.Equal(t, "main.main /app/main.go:17", gs[0].location)
+	assert.Equal(t, "main", gs[0].creator)
+
+	assert.Equal(t, "chan receive", gs[1].state)
+	assert.Equal(t, 5*time.Minute, gs[1].waiting)
+	assert.Equal(t, "main.worker /app/worker.go:12", gs[1].location)
+	assert.Equal(t, "main.main /app/main.go:12", gs[1].creator)
+	assert.True(t, gs[1].blocked())
+
+	assert.Equal(t, "select", gs[3].state)
+	assert.Equal(t, time.Duration(0), gs[3].waiting)
+	assert.Equal(t, "main.start /app/main.go:20", gs[3].creator)
+}
+
+func TestGroupByCreator(t *testing.T) {
+	groups := groupByCreator(parseGoroutines([]byte(testDump)))
+	assert.Equal(t, []GoroutineGroup{
+		{Creator: "main.main /app/main.go:12", Count: 2},
+		{Creator: "main", Count: 1},
+		{Creator: "main.start /app/main.go:20", Count: 1},
+	}, groups)
+}
+
+func TestGroupBlocked(t *testing.T) {
+	gs := parseGoroutines([]byte(testDump))
+	assert.Equal(t, []blockedGroup{{
+		state:    "chan receive",
+		location: "main.worker /app/worker.go:12",
+		count:    2,
+		waiting:  5 * time.Minute,
+	}}, groupBlocked(gs, 2*time.Minute))
+	assert.Len(t, groupBlocked(gs, 4*time.Minute), 1)
+	assert.Empty(t, groupBlocked(gs, 10*time.Minute))
+}
+
+func TestGoroutinesByCreator(t *testing.T) {
+	ch := make(chan struct{})
+	defer close(ch)
+	for i := 0; i < 5; i++ {
+		go func() { <-ch }()
+	}
+	found := false
+	for _, g := range GoroutinesByCreator() {
+		if strings.Contains(g.Creator, "TestGoroutinesByCreator") {
+			assert.Equal(t, 5, g.Count)
+			found = true
+		}
+	}
+	assert.True(t, found)
+}
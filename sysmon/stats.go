@@ -0,0 +1,143 @@
+//  Copyright (C) 2021-2023 Chronicle Labs, Inc.
+//
+//  This program is free software: you can redistribute it and/or modify
+//  it under the terms of the GNU Affero General Public License as
+//  published by the Free Software Foundation, either version 3 of the
+//  License, or (at your option) any later version.
+//
+//  This program is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of
+//  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU Affero General Public License for more details.
+//
+//  You should have received a copy of the GNU Affero General Public License
+//  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package sysmon
+
+import (
+	"bytes"
+	"os"
+	"runtime/metrics"
+	"strconv"
+	"time"
+)
+
+// Stats is a snapshot of the process resource usage. Durations are encoded
+// in JSON as nanoseconds.
+type Stats struct {
+	// Time is the time when the snapshot was taken.
+	Time time.Time `json:"time"`
+
+	// Goroutines is the number of live goroutines.
+	Goroutines uint64 `json:"goroutines"`
+
+	// HeapAlloc is the number of bytes occupied by live and not yet
+	// collected heap objects.
+	HeapAlloc uint64 `json:"heapAlloc"`
+
+	// HeapObjects is the number of live and not yet collected heap objects.
+	HeapObjects uint64 `json:"heapObjects"`
+
+	// HeapGoal is the heap size target for the end of the current GC cycle.
+	HeapGoal uint64 `json:"heapGoal"`
+
+	// StackInUse is the number of bytes used by goroutine stacks.
+	StackInUse uint64 `json:"stackInUse"`
+
+	// TotalMemory is the number of bytes of memory mapped by the Go runtime.
+	TotalMemory uint64 `json:"totalMemory"`
+
+	// GCCycles is the number of completed GC cycles.
+	GCCycles uint64 `json:"gcCycles"`
+
+	// CPUUser is the estimated CPU time spent running user Go code.
+	CPUUser time.Duration `json:"cpuUser"`
+
+	// CPUTotal is the estimated total CPU time spent by the process.
+	CPUTotal time.Duration `json:"cpuTotal"`
+
+	// OpenFDs is the number of open file descriptors. It is -1 if the value
+	// cannot be determined on the current platform.
+	OpenFDs int `json:"openFDs"`
+
+	// RSS is the resident set size in bytes. It is zero if the value cannot
+	// be determined on the current platform.
+	RSS uint64 `json:"rss"`
+}
+
+var metricNames = []string{
+	"/sched/goroutines:goroutines",
+	"/memory/classes/heap/objects:bytes",
+	"/gc/heap/objects:objects",
+	"/gc/heap/goal:bytes",
+	"/memory/classes/heap/stacks:bytes",
+	"/memory/classes/total:bytes",
+	"/gc/cycles/total:gc-cycles",
+	"/cpu/classes/user:cpu-seconds",
+	"/cpu/classes/total:cpu-seconds",
+}
+
+// ReadStats returns the current resource usage of the process.
+func ReadStats() Stats {
+	samples := make([]metrics.Sample, len(metricNames))
+	for i, name := range metricNames {
+		samples[i].Name = name
+	}
+	metrics.Read(samples)
+	return Stats{
+		Time:        time.Now(),
+		Goroutines:  uint64Value(samples[0].Value),
+		HeapAlloc:   uint64Value(samples[1].Value),
+		HeapObjects: uint64Value(samples[2].Value),
+		HeapGoal:    uint64Value(samples[3].Value),
+		StackInUse:  uint64Value(samples[4].Value),
+		TotalMemory: uint64Value(samples[5].Value),
+		GCCycles:    uint64Value(samples[6].Value),
+		CPUUser:     secondsValue(samples[7].Value),
+		CPUTotal:    secondsValue(samples[8].Value),
+		OpenFDs:     openFDs(),
+		RSS:         rss(),
+	}
+}
+
+func uint64Value(v metrics.Value) uint64 {
+	if v.Kind() != metrics.KindUint64 {
+		return 0
+	}
+	return v.Uint64()
+}
+
+func secondsValue(v metrics.Value) time.Duration {
+	if v.Kind() != metrics.KindFloat64 {
+		return 0
+	}
+	return time.Duration(v.Float64() * float64(time.Second))
+}
+
+// openFDs returns the number of open file descriptors using /proc/self/fd.
+func openFDs() int {
+	entries, err := os.ReadDir("/proc/self/fd")
+	if err != nil {
+		return -1
+	}
+	// One of the entries is the descriptor used to read the directory.
+	return len(entries) - 1
+}
+
+// rss returns the resident set size using /proc/self/statm.
+func rss() uint64 {
+	b, err := os.ReadFile("/proc/self/statm")
+	if err != nil {
+		return 0
+	}
+	fields := bytes.Fields(b)
+	if len(fields) < 2 {
+		return 0
+	}
+	pages, err := strconv.ParseUint(string(fields[1]), 10, 64)
+	if err != nil {
+		return 0
+	}
+	return pages * uint64(os.Getpagesize())
+}
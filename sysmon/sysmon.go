@@ -0,0 +1,362 @@
+//  Copyright (C) 2021-2023 Chronicle Labs, Inc.
+//
+//  This program is free software: you can redistribute it and/or modify
+//  it under the terms of the GNU Affero General Public License as
+//  published by the Free Software Foundation, either version 3 of the
+//  License, or (at your option) any later version.
+//
+//  This program is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of
+//  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU Affero General Public License for more details.
+//
+//  You should have received a copy of the GNU Affero General Public License
+//  along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package sysmon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/chronicleprotocol/go-utils/log"
+	"github.com/chronicleprotocol/go-utils/log/null"
+)
+
+const LoggerTag = "SYSMON"
+
+const defaultBlockScanInterval = time.Minute
+
+// Thresholds defines resource usage limits. If any of the limits is
+// exceeded, a warning is logged. Zero values disable the corresponding
+// check.
+type Thresholds struct {
+	// Goroutines is the maximum number of goroutines.
+	Goroutines uint64
+
+	// HeapAlloc is the maximum number of bytes allocated on the heap.
+	HeapAlloc uint64
+
+	// OpenFDs is the maximum number of open file descriptors.
+	OpenFDs int
+
+	// RSS is the maximum resident set size in bytes.
+	RSS uint64
+}
+
+// LeakDetection configures detection of goroutine leaks.
+type LeakDetection struct {
+	// Windows is the number of consecutive intervals in which the number of
+	// goroutines must not decrease to consider it a leak. Zero disables the
+	// detection.
+	Windows int
+
+	// Limit is the minimum growth of the number of goroutines within
+	// the windows to consider it a leak.
+	Limit uint64
+
+	// Top is the number of the largest goroutine groups to log. If zero,
+	// 10 groups are logged.
+	Top int
+}
+
+// BlockDetection configures detection of goroutines blocked on channel
+// operations.
+//
+// Detection requires stack traces of all goroutines, which briefly stops
+// the world, so it is disabled by default and the stack traces are collected
+// at most once per ScanInterval.
+type BlockDetection struct {
+	// Threshold is the minimum time a goroutine must be blocked to be
+	// reported. The Go runtime reports the blocking time with a minute
+	// resolution and only after a goroutine has been blocked for at least
+	// one minute, so thresholds below one minute are treated as one minute.
+	// Zero disables the detection.
+	Threshold time.Duration
+
+	// MinGoroutines is the minimum number of goroutines blocked on the same
+	// operation to be reported. If zero, every blocked goroutine is reported.
+	MinGoroutines int
+
+	// ScanInterval is the minimum time between two collections of stack
+	// traces. If zero, one minute is used.
+	ScanInterval time.Duration
+}
+
+// Config is a configuration for the Sysmon service.
+type Config struct {
+	// Interval is the time between reports.
+	Interval time.Duration
+
+	// Thresholds defines limits above which warnings are logged.
+	Thresholds Thresholds
+
+	// Leaks configures detection of goroutine leaks.
+	Leaks LeakDetection
+
+	// Blocks configures detection of goroutines blocked on channel
+	// operations.
+	Blocks BlockDetection
+
+	// Logger is a logger instance.
+	Logger log.Logger
+}
+
+// Sysmon periodically reports resource usage of the process.
+//
+// The stats are logged with the debug level. If thresholds are defined,
+// exceeded limits are logged with the warning level. The same applies to
+// suspected goroutine leaks and goroutines blocked on channel operations,
+// if their detection is enabled. If the logger does not show any of these
+// logs, the service stops immediately after start.
+type Sysmon struct {
+	mu     sync.Mutex
+	ctx    context.Context
+	waitCh chan error
+	log    log.Logger
+
+	interval   time.Duration
+	thresholds Thresholds
+	leaks      LeakDetection
+	blocks     BlockDetection
+	stats      *Stats
+	running    bool
+	history    []uint64 // number of goroutines in the recent intervals
+
+	lastBlockScan time.Time       // time of the last scan for blocked goroutines
+	blocked       map[string]bool // blocked groups that have been reported
+	dump          func() []byte   // returns stack traces of all goroutines
+	now           func() time.Time
+}
+
+// New returns a new instance of Sysmon that logs stats every interval.
+func New(interval time.Duration, logger log.Logger) *Sysmon {
+	return NewWithConfig(Config{Interval: interval, Logger: logger})
+}
+
+// NewWithConfig returns a new instance of Sysmon.
+func NewWithConfig(cfg Config) *Sysmon {
+	if cfg.Logger == nil {
+		cfg.Logger = null.New()
+	}
+	return &Sysmon{
+		waitCh:     make(chan error),
+		log:        cfg.Logger.WithField("tag", LoggerTag),
+		interval:   cfg.Interval,
+		thresholds: cfg.Thresholds,
+		leaks:      cfg.Leaks,
+		blocks:     cfg.Blocks,
+		blocked:    map[string]bool{},
+		dump:       dumpGoroutines,
+		now:        time.Now,
+	}
+}
+
+// Start implements the supervisor.Service interface.
+func (s *Sysmon) Start(ctx context.Context) error {
+	if s.ctx != nil {
+		return errors.New("service can be started only once")
+	}
+	if ctx == nil {
+		return errors.New("context must not be nil")
+	}
+	if s.interval <= 0 {
+		return errors.New("interval must be greater than zero")
+	}
+	s.ctx = ctx
+	if !s.enabled() {
+		close(s.waitCh)
+		return nil
+	}
+	s.mu.Lock()
+	s.running = true
+	s.mu.Unlock()
+	go s.monitorRoutine()
+	return nil
+}
+
+// Wait implements the supervisor.Service interface.
+func (s *Sysmon) Wait() <-chan error {
+	return s.waitCh
+}
+
+// Stats returns the most recent snapshot. If the monitor loop is not running,
+// or no snapshot has been taken yet, a new snapshot is taken.
+func (s *Sysmon) Stats() Stats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.running || s.stats == nil {
+		return ReadStats()
+	}
+	return *s.stats
+}
+
+// enabled reports whether any of the logs produced by the service would be
+// shown by the logger.
+func (s *Sysmon) enabled() bool {
+	if log.IsLevel(s.log, log.Debug) {
+		return true
+	}
+	if !log.IsLevel(s.log, log.Warn) {
+		return false
+	}
+	return s.thresholds != (Thresholds{}) || s.leaks.Windows > 0 || s.blocks.Threshold > 0
+}
+
+func (s *Sysmon) monitorRoutine() {
+	defer close(s.waitCh)
+	defer func() {
+		s.mu.Lock()
+		s.running = false
+		s.mu.Unlock()
+	}()
+	t := time.NewTicker(s.interval)
+	defer t.Stop()
+	s.report()
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-t.C:
+			s.report()
+		}
+	}
+}
+
+func (s *Sysmon) report() {
+	stats := ReadStats()
+	s.mu.Lock()
+	s.stats = &stats
+	s.mu.Unlock()
+
+	fields := log.Fields{
+		"goroutines":  stats.Goroutines,
+		"heapAlloc":   stats.HeapAlloc,
+		"heapObjects": stats.HeapObjects,
+		"heapGoal":    stats.HeapGoal,
+		"stackInUse":  stats.StackInUse,
+		"totalMemory": stats.TotalMemory,
+		"gcCycles":    stats.GCCycles,
+		"cpuUser":     stats.CPUUser,
+		"cpuTotal":    stats.CPUTotal,
+		"openFDs":     stats.OpenFDs,
+		"rss":         stats.RSS,
+	}
+	s.log.WithFields(fields).Debug("Status")
+
+	var exceeded []string
+	th := s.thresholds
+	if th.Goroutines > 0 && stats.Goroutines > th.Goroutines {
+		exceeded = append(exceeded, "goroutines")
+	}
+	if th.HeapAlloc > 0 && stats.HeapAlloc > th.HeapAlloc {
+		exceeded = append(exceeded, "heapAlloc")
+	}
+	if th.OpenFDs > 0 && stats.OpenFDs > th.OpenFDs {
+		exceeded = append(exceeded, "openFDs")
+	}
+	if th.RSS > 0 && stats.RSS > th.RSS {
+		exceeded = append(exceeded, "rss")
+	}
+	if len(exceeded) > 0 {
+		s.log.
+			WithFields(fields).
+			WithField("exceeded", exceeded).
+			WithAdvice("Resource usage is higher than expected, it may indicate a leak").
+			Warn("Resource usage above threshold")
+	}
+
+	s.detectLeaks(stats.Goroutines)
+	s.detectBlocked()
+}
+
+// detectLeaks logs the largest goroutine groups if the number of goroutines
+// has not decreased for the configured number of intervals and the growth
+// exceeds the limit.
+func (s *Sysmon) detectLeaks(goroutines uint64) {
+	if s.leaks.Windows <= 0 {
+		return
+	}
+	s.history = append(s.history, goroutines)
+	if len(s.history) <= s.leaks.Windows {
+		return
+	}
+	s.history = s.history[len(s.history)-s.leaks.Windows-1:]
+	for i := 1; i < len(s.history); i++ {
+		if s.history[i] < s.history[i-1] {
+			return
+		}
+	}
+	growth := s.history[len(s.history)-1] - s.history[0]
+	if growth <= s.leaks.Limit {
+		return
+	}
+	top := s.leaks.Top
+	if top <= 0 {
+		top = 10
+	}
+	groups := GoroutinesByCreator()
+	if len(groups) > top {
+		groups = groups[:top]
+	}
+	offenders := make([]string, len(groups))
+	for i, g := range groups {
+		offenders[i] = fmt.Sprintf("%d goroutines created by %s", g.Count, g.Creator)
+	}
+	s.log.
+		WithFields(log.Fields{
+			"goroutines": goroutines,
+			"growth":     growth,
+			"windows":    s.leaks.Windows,
+			"offenders":  offenders,
+		}).
+		WithAdvice("The number of goroutines is constantly growing, check the offenders for a leak").
+		Warn("Goroutine leak suspected")
+
+	// Start a new observation to avoid reporting the same leak every interval.
+	s.history = s.history[len(s.history)-1:]
+}
+
+// detectBlocked logs goroutines that have been blocked on the same channel
+// operation for longer than the configured threshold. Each group is reported
+// only once, until none of its goroutines are blocked for longer than
+// the threshold.
+func (s *Sysmon) detectBlocked() {
+	if s.blocks.Threshold <= 0 {
+		return
+	}
+	scanInterval := s.blocks.ScanInterval
+	if scanInterval <= 0 {
+		scanInterval = defaultBlockScanInterval
+	}
+	now := s.now()
+	if !s.lastBlockScan.IsZero() && now.Sub(s.lastBlockScan) < scanInterval {
+		return
+	}
+	s.lastBlockScan = now
+	threshold := max(s.blocks.Threshold, time.Minute)
+	blocked := map[string]bool{}
+	for _, g := range groupBlocked(parseGoroutines(s.dump()), threshold) {
+		if g.count < s.blocks.MinGoroutines {
+			continue
+		}
+		key := g.state + "|" + g.location
+		blocked[key] = true
+		if s.blocked[key] {
+			continue
+		}
+		s.log.
+			WithFields(log.Fields{
+				"state":      g.state,
+				"location":   g.location,
+				"goroutines": g.count,
+				"waiting":    g.waiting,
+			}).
+			WithAdvice("Goroutines blocked for a long time may indicate a deadlock").
+			Warn("Goroutines blocked on channel operation")
+	}
+	s.blocked = blocked
+}
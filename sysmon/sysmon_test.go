@@ -75,3 +75,141 @@ func TestSysmon_RunWithDebugLevelOnly(t *testing.T) {
 		require.Fail(t, "sysmon should not start with verbosity other than debug")
 	}
 }
+
+func TestSysmon_Thresholds(t *testing.T) {
+	ctx, ctxCancel := context.WithCancel(context.Background())
+	defer ctxCancel()
+
+	f := int32(0)
+	l := callback.New(log.Warn, func(level log.Level, fields log.Fields, msg string) {
+		if msg != "Resource usage above threshold" {
+			return
+		}
+		assert.Equal(t, log.Warn, level)
+		assert.Contains(t, fields["exceeded"], "goroutines")
+		atomic.StoreInt32(&f, 1)
+	})
+
+	s := NewWithConfig(Config{
+		Interval:   time.Second,
+		Thresholds: Thresholds{Goroutines: 1},
+		Logger:     l,
+	})
+	require.NoError(t, s.Start(ctx))
+
+	// wait for log
+	for i := 0; i < 10 && atomic.LoadInt32(&f) == 0; i++ {
+		time.Sleep(100 * time.Millisecond)
+	}
+	require.Equal(t, int32(1), atomic.LoadInt32(&f), "log message has not been sent")
+
+	ctxCancel()
+	require.NoError(t, <-s.Wait())
+}
+
+func TestSysmon_Stats(t *testing.T) {
+	s := New(time.Second, null.New())
+	stats := s.Stats()
+	assert.NotZero(t, stats.Goroutines)
+	assert.NotZero(t, stats.HeapAlloc)
+	assert.False(t, stats.Time.IsZero())
+}
+
+func TestSysmon_StatsAfterStop(t *testing.T) {
+	ctx, ctxCancel := context.WithCancel(context.Background())
+	s := New(time.Hour, callback.New(log.Debug, func(log.Level, log.Fields, string) {}))
+	require.NoError(t, s.Start(ctx))
+	assert.Eventually(t, func() bool {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return s.stats != nil
+	}, time.Second, 10*time.Millisecond)
+	ctxCancel()
+	require.NoError(t, <-s.Wait())
+
+	// After the service stops, the snapshot is taken on every call.
+	stopped := time.Now()
+	assert.False(t, s.Stats().Time.Before(stopped))
+}
+
+func TestSysmon_LeakDetection(t *testing.T) {
+	ctx, ctxCancel := context.WithCancel(context.Background())
+	defer ctxCancel()
+
+	f := int32(0)
+	l := callback.New(log.Warn, func(level log.Level, fields log.Fields, msg string) {
+		if msg != "Goroutine leak suspected" {
+			return
+		}
+		assert.NotEmpty(t, fields["offenders"])
+		atomic.StoreInt32(&f, 1)
+	})
+
+	s := NewWithConfig(Config{
+		Interval: 50 * time.Millisecond,
+		Leaks:    LeakDetection{Windows: 2, Limit: 10},
+		Logger:   l,
+	})
+	require.NoError(t, s.Start(ctx))
+
+	// leak goroutines until the leak is detected
+	leakCh := make(chan struct{})
+	defer close(leakCh)
+	for i := 0; i < 20 && atomic.LoadInt32(&f) == 0; i++ {
+		for j := 0; j < 10; j++ {
+			go func() { <-leakCh }()
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	require.Equal(t, int32(1), atomic.LoadInt32(&f), "log message has not been sent")
+
+	ctxCancel()
+	require.NoError(t, <-s.Wait())
+}
+
+func TestSysmon_BlockDetection(t *testing.T) {
+	var reports []log.Fields
+	l := callback.New(log.Warn, func(level log.Level, fields log.Fields, msg string) {
+		if msg == "Goroutines blocked on channel operation" {
+			reports = append(reports, fields)
+		}
+	})
+	s := NewWithConfig(Config{
+		Interval: time.Second,
+		Blocks:   BlockDetection{Threshold: time.Second, ScanInterval: time.Minute},
+		Logger:   l,
+	})
+	dumps := 0
+	dump := testDump
+	s.dump = func() []byte {
+		dumps++
+		return []byte(dump)
+	}
+	now := time.Unix(0, 0)
+	s.now = func() time.Time { return now }
+
+	s.detectBlocked()
+	require.Len(t, reports, 1)
+	assert.Equal(t, "main.worker /app/worker.go:12", reports[0]["location"])
+	assert.Equal(t, 2, reports[0]["goroutines"])
+
+	// Stack traces are not collected more often than the scan interval.
+	now = now.Add(30 * time.Second)
+	s.detectBlocked()
+	assert.Equal(t, 1, dumps)
+
+	// The same group is not reported again.
+	now = now.Add(time.Minute)
+	s.detectBlocked()
+	assert.Equal(t, 2, dumps)
+	assert.Len(t, reports, 1)
+
+	// The group is reported again after it was unblocked.
+	dump = "goroutine 1 [running]:\nmain.main()\n\t/app/main.go:17 +0xce\n"
+	now = now.Add(time.Minute)
+	s.detectBlocked()
+	dump = testDump
+	now = now.Add(time.Minute)
+	s.detectBlocked()
+	assert.Len(t, reports, 2)
+}